@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"os/signal"
@@ -13,32 +14,38 @@ import (
 
 	"github.com/DominicWuest/Alphie/bot/commands"
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 
 	discord "github.com/bwmarrin/discordgo"
 )
 
 // Initialising constants
-var prefixes []string = []string{":) ", "(: ", ": ) ", "al ", "🙂 "}
-
 var COMMANDS map[string]constants.Command = make(map[string]constants.Command)
 
+// Rate limits of the commands, by default the ones declared by the commands themselves
+var rateLimits map[string]constants.RateLimit = make(map[string]constants.RateLimit)
+
+var limiter *ratelimit.Limiter = ratelimit.New(nil)
+
 func main() {
 	// Set new seed for math/rand
 	rand.Seed(time.Now().UnixNano())
 
+	bot, err := discord.New("Bot " + os.Getenv("API_TOKEN"))
+	if err != nil {
+		log.Fatalln(constants.Red, "Error initializing Discord bot:", err)
+	}
+
 	// Initialising all commands
 	COMMANDS["ping"] = commands.Ping{}.Init()
 	COMMANDS["blackjack"] = commands.Blackjack{}.Init()
-	COMMANDS["todo"] = commands.Todo{}.Init()
+	COMMANDS["todo"] = commands.Todo{}.Init(bot)
 	COMMANDS["image"] = commands.ImageGeneration{}.Init()
 	COMMANDS["clip"] = commands.Clip{}.Init()
 
 	COMMANDS["help"] = commands.Help{}.Init(&COMMANDS)
 
-	bot, err := discord.New("Bot " + os.Getenv("API_TOKEN"))
-	if err != nil {
-		log.Fatalln(constants.Red, "Error initializing Discord bot:", err)
-	}
+	initialiseRateLimits()
 
 	bot.AddHandler(ready)
 	bot.AddHandler(messageCreate)
@@ -64,6 +71,27 @@ func main() {
 	log.Println(constants.Green, "Stopped Bot.")
 }
 
+// Initialises the rate limits of all commands
+// They can be overwritten by setting RATE_LIMIT_<COMMAND>_USER or RATE_LIMIT_<COMMAND>_CHANNEL to burst/interval, e.g. 3/1m
+func initialiseRateLimits() {
+	for name, command := range COMMANDS {
+		limit := command.RateLimit()
+		for scope, target := range map[string]*ratelimit.Limit{"USER": &limit.User, "CHANNEL": &limit.Channel} {
+			env := "RATE_LIMIT_" + strings.ToUpper(name) + "_" + scope
+			raw := os.Getenv(env)
+			if raw == "" {
+				continue
+			}
+			parsed, err := ratelimit.ParseLimit(raw)
+			if err != nil {
+				log.Fatalln(constants.Red, "Error parsing", env, ":", err)
+			}
+			*target = parsed
+		}
+		rateLimits[name] = limit
+	}
+}
+
 // Set activity to "Watching the Pikmin bloom"
 func ready(bot *discord.Session, event *discord.Ready) {
 	constants.InitialiseConstants(bot)
@@ -75,6 +103,19 @@ func ready(bot *discord.Session, event *discord.Ready) {
 			Type: 3, // Type = Watching
 		}},
 	})
+
+	// Register all commands as application commands, overwriting outdated ones
+	applicationCommands := []*discord.ApplicationCommand{}
+	for name, command := range COMMANDS {
+		applicationCommands = append(applicationCommands, &discord.ApplicationCommand{
+			Name:        name,
+			Description: command.Desc(),
+			Options:     command.Options(),
+		})
+	}
+	if _, err := bot.ApplicationCommandBulkOverwrite(bot.State.User.ID, "", applicationCommands); err != nil {
+		log.Println(constants.Red, "Couldn't register application commands:", err)
+	}
 }
 
 func messageCreate(bot *discord.Session, ctx *discord.MessageCreate) {
@@ -103,7 +144,7 @@ func messageCreate(bot *discord.Session, ctx *discord.MessageCreate) {
 
 	// Ignore messages without the correct prefix
 	hasPrefix := false
-	for _, prefix := range prefixes {
+	for _, prefix := range constants.Prefixes {
 		if strings.HasPrefix(ctx.Content, prefix) {
 			ctx.Content = ctx.Content[len(prefix):]
 			hasPrefix = true
@@ -115,46 +156,173 @@ func messageCreate(bot *discord.Session, ctx *discord.MessageCreate) {
 		return
 	}
 
-	command := strings.Split(ctx.Content, " ")
+	go dispatch(bot, ctx, strings.Split(ctx.Content, " "))
+}
+
+// Calls the command named by the first argument, used for prefix commands
+func dispatch(bot *discord.Session, ctx *discord.MessageCreate, command []string) {
 	parsedCommand, found := COMMANDS[command[0]]
 	if found {
 		log.Println(constants.Yellow, ctx.Author.Username, "used command", ctx.Content)
-		// Call the command
-		go func() {
-			if err := parsedCommand.HandleCommand(bot, ctx, command); err != nil {
-				// If command failed
-				log.Println(constants.Red, "Error while calling ", command, " : ", err)
-				bot.ChannelMessageSend(ctx.ChannelID, "An unexpected error occurred while handling your command. Please try again later. If the issue persists, please contact my owner.")
-			}
-		}()
+		if reason, rejected := rejectCommand(bot, parsedCommand, command, ctx.GuildID, ctx.ChannelID, ctx.Author, ctx.Member); rejected {
+			bot.ChannelMessageSendReply(ctx.ChannelID, reason, ctx.Reference())
+			return
+		}
+		runCommand(bot, ctx, parsedCommand, command)
 	} else {
 		log.Println(constants.Yellow, ctx.Author.Username, "used unknown command", ctx.Content)
 	}
 }
 
+// Checks that the user may call the command and isn't spamming it, returns the reply telling them why not otherwise
+// The member is nil if the command wasn't called in a guild
+func rejectCommand(bot *discord.Session, parsedCommand constants.Command, command []string, guildId, channelId string, user *discord.User, member *discord.Member) (string, bool) {
+	// Check that the user may call the command
+	permission := parsedCommand.Permission(command)
+	if !permission.Allows(bot, guildId, user, member) {
+		log.Println(constants.Yellow, user.Username, "lacks the permission to use command", strings.Join(command, " "))
+		return "Sorry, you are not allowed to use this command. " + permission.Requirement(), true
+	}
+	// Check that the user isn't spamming the command
+	limit := rateLimits[command[0]]
+	if allowed, wait := limiter.Allow(
+		ratelimit.Request{Key: "user:" + user.ID + ":" + command[0], Limit: limit.User},
+		ratelimit.Request{Key: "channel:" + channelId + ":" + command[0], Limit: limit.Channel},
+	); !allowed {
+		log.Println(constants.Yellow, user.Username, "got rate limited using command", strings.Join(command, " "))
+		seconds := int(math.Ceil(wait.Seconds()))
+		return fmt.Sprintf("Slow down! Please try again in %ds.", seconds), true
+	}
+	return "", false
+}
+
+// Calls the command, which passed the checks of rejectCommand
+func runCommand(bot *discord.Session, ctx *discord.MessageCreate, parsedCommand constants.Command, command []string) {
+	if err := parsedCommand.HandleCommand(bot, ctx, command); err != nil {
+		// If command failed
+		log.Println(constants.Red, "Error while calling ", command, " : ", err)
+		bot.ChannelMessageSend(ctx.ChannelID, "An unexpected error occurred while handling your command. Please try again later. If the issue persists, please contact my owner.")
+	}
+}
+
 func interactionCreate(bot *discord.Session, interaction *discord.InteractionCreate) {
-	var handler (func(*discord.Interaction) error)
+	var handler constants.Handler
 	var found bool
+	var id string
 	switch interaction.Data.Type() {
+	case discord.InteractionApplicationCommand:
+		go handleApplicationCommand(bot, interaction.Interaction)
+		return
 	case discord.InteractionMessageComponent:
-		id := interaction.MessageComponentData().CustomID
-		handler, found = constants.Handlers.MessageComponents[id]
+		id = interaction.MessageComponentData().CustomID
+		handler, found = constants.Handlers.MessageComponents.Lookup(id)
 	case discord.InteractionModalSubmit:
-		id := interaction.ModalSubmitData().CustomID
-		handler, found = constants.Handlers.ModalSubmit[id]
+		id = interaction.ModalSubmitData().CustomID
+		handler, found = constants.Handlers.ModalSubmit.Lookup(id)
 	default:
 		log.Println(constants.Red, "Couldn't associate Interaction to any known type", interaction.Data.Type().String())
+		return
 	}
 
 	if found {
 		go func() {
-			if err := handler(interaction.Interaction); err != nil {
+			if err := handler(bot, interaction.Interaction); err != nil {
 				// If command failed
 				log.Println(constants.Red, "Error while handling interaction", interaction, " : ", err)
 				bot.ChannelMessageSend(interaction.ChannelID, "An unexpected error occurred while handling your interaction. Please try again later. If the issue persists, please contact my owner.")
 			}
 		}()
 	} else {
-		log.Println(constants.Red, "Interaction created but ID not found", interaction.ID)
+		// The handler expired or didn't survive a restart of the bot
+		log.Println(constants.Yellow, "Interaction created for expired ID", id)
+		constants.RespondExpired(bot, interaction.Interaction)
+	}
+}
+
+// Turns an application command into the arguments of its prefix form and runs it, if the user may call it
+// The response to the interaction stands in for the message the user would have sent
+func handleApplicationCommand(bot *discord.Session, interaction *discord.Interaction) {
+	data := interaction.ApplicationCommandData()
+	command, found := COMMANDS[data.Name]
+	if !found {
+		log.Println(constants.Red, "Application command created but not found", data.Name)
+		return
+	}
+
+	args := append([]string{data.Name}, optionsToArgs(command.Options(), data.Options)...)
+
+	user := interaction.User
+	if user == nil {
+		user = interaction.Member.User
+	}
+	log.Println(constants.Yellow, user.Username, "used command", strings.Join(args, " "))
+	// Check before echoing the arguments publicly, rejections are only shown to the user
+	if reason, rejected := rejectCommand(bot, command, args, interaction.GuildID, interaction.ChannelID, user, interaction.Member); rejected {
+		if err := bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: reason,
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		}); err != nil {
+			log.Println(constants.Red, "Couldn't respond to application command", data.Name, ":", err)
+		}
+		return
+	}
+
+	if err := bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Content: "`" + strings.Join(args, " ") + "`",
+		},
+	}); err != nil {
+		log.Println(constants.Red, "Couldn't respond to application command", data.Name, ":", err)
+		return
+	}
+	msg, err := bot.InteractionResponse(interaction)
+	if err != nil {
+		log.Println(constants.Red, "Couldn't get response to application command", data.Name, ":", err)
+		return
+	}
+
+	msg.Author = user
+	msg.Member = interaction.Member
+	msg.GuildID = interaction.GuildID
+	msg.Content = strings.Join(args, " ")
+	// Attached files are passed as attachments of the message
+	if data.Resolved != nil {
+		for _, attachment := range data.Resolved.Attachments {
+			msg.Attachments = append(msg.Attachments, attachment)
+		}
+	}
+
+	runCommand(bot, &discord.MessageCreate{Message: msg}, command, args)
+}
+
+// Flattens the options of an application command into the arguments the prefix parser would produce
+// Subcommands are inserted by name, the values of all other options except attachments in the order they were declared in
+func optionsToArgs(declared []*discord.ApplicationCommandOption, given []*discord.ApplicationCommandInteractionDataOption) []string {
+	args := []string{}
+	for _, option := range declared {
+		for _, value := range given {
+			if value.Name != option.Name {
+				continue
+			}
+			switch value.Type {
+			case discord.ApplicationCommandOptionSubCommand, discord.ApplicationCommandOptionSubCommandGroup:
+				args = append(args, value.Name)
+				args = append(args, optionsToArgs(option.Options, value.Options)...)
+			case discord.ApplicationCommandOptionString:
+				args = append(args, strings.Fields(value.StringValue())...)
+			case discord.ApplicationCommandOptionUser:
+				// Passed the way the prefix parser sees mentions
+				args = append(args, "<@"+fmt.Sprint(value.Value)+">")
+			case discord.ApplicationCommandOptionAttachment:
+				// Passed as attachments of the message instead
+			default:
+				args = append(args, fmt.Sprint(value.Value))
+			}
+		}
 	}
+	return args
 }
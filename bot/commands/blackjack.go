@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 
 	discord "github.com/bwmarrin/discordgo"
 )
@@ -36,6 +37,8 @@ const dealingDelay = 250 * time.Millisecond
 
 const timeoutDelay = 15 * time.Second
 
+const restartTimeout = time.Minute // How long the game can be restarted after it ended
+
 const embedColor = 0xC27C0E
 
 // Starts a game of blackjack
@@ -75,6 +78,21 @@ func (s Blackjack) Help() string {
 	return "The command does not take any additional arguments, simply invoke the command and play some blackjack!"
 }
 
+func (s Blackjack) Options() []*discord.ApplicationCommandOption {
+	return []*discord.ApplicationCommandOption{}
+}
+
+func (s Blackjack) Permission(args []string) constants.Permission {
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s Blackjack) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 3, Interval: 30 * time.Second},
+		Channel: ratelimit.Limit{Burst: 6, Interval: 30 * time.Second},
+	}
+}
+
 func (s Blackjack) Init(args ...interface{}) constants.Command {
 	return &s
 }
@@ -138,9 +156,10 @@ func (s *Blackjack) startNewGame(bot *discord.Session, ctx *discord.MessageCreat
 		Channel:    ctx.ChannelID,
 	})
 
-	constants.Handlers.MessageComponents["blackjack_hit"] = s.handleHit
-	constants.Handlers.MessageComponents["blackjack_stand"] = s.handleStand
-	constants.Handlers.MessageComponents["blackjack_exit"] = s.handleExit
+	// Inactive games are stopped by the timeout timer, which unregisters the handlers
+	constants.Handlers.MessageComponents.Register("blackjack_hit", s.handleHit, 0, nil)
+	constants.Handlers.MessageComponents.Register("blackjack_stand", s.handleStand, 0, nil)
+	constants.Handlers.MessageComponents.Register("blackjack_exit", s.handleExit, 0, nil)
 
 	// Start the initial deal
 	go func() {
@@ -304,7 +323,7 @@ func (s *Blackjack) deal(player bool) {
 	*totals = newTotals
 }
 
-func (s *Blackjack) handleHit(interaction *discord.Interaction) error {
+func (s *Blackjack) handleHit(bot *discord.Session, interaction *discord.Interaction) error {
 	// ACK interaction
 	s.bot.InteractionRespond(interaction, &discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredMessageUpdate,
@@ -353,7 +372,7 @@ func (s *Blackjack) handleHit(interaction *discord.Interaction) error {
 	return nil
 }
 
-func (s *Blackjack) handleStand(interaction *discord.Interaction) error {
+func (s *Blackjack) handleStand(bot *discord.Session, interaction *discord.Interaction) error {
 	// ACK interaction
 	s.bot.InteractionRespond(interaction, &discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredMessageUpdate,
@@ -391,7 +410,7 @@ func (s *Blackjack) handleStand(interaction *discord.Interaction) error {
 	return nil
 }
 
-func (s *Blackjack) handleExit(interaction *discord.Interaction) error {
+func (s *Blackjack) handleExit(bot *discord.Session, interaction *discord.Interaction) error {
 	// ACK interaction
 	s.bot.InteractionRespond(interaction, &discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredMessageUpdate,
@@ -411,17 +430,12 @@ func (s *Blackjack) handleExit(interaction *discord.Interaction) error {
 	s.bot.Lock()
 	defer s.bot.Unlock()
 
-	delete(constants.Handlers.MessageComponents, "blackjack_hit")
-	delete(constants.Handlers.MessageComponents, "blackjack_stand")
-	delete(constants.Handlers.MessageComponents, "blackjack_exit")
-	delete(constants.Handlers.MessageComponents, "blackjack_restart")
-
 	s.exit()
 
 	return nil
 }
 
-func (s *Blackjack) handleRestart(interaction *discord.Interaction) error {
+func (s *Blackjack) handleRestart(bot *discord.Session, interaction *discord.Interaction) error {
 	// ACK interaction
 	s.bot.InteractionRespond(interaction, &discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredMessageUpdate,
@@ -441,7 +455,7 @@ func (s *Blackjack) handleRestart(interaction *discord.Interaction) error {
 	s.bot.Lock()
 	defer s.bot.Unlock()
 
-	delete(constants.Handlers.MessageComponents, "blackjack_restart")
+	constants.Handlers.MessageComponents.Unregister("blackjack_restart")
 
 	s.startNewGame(s.bot, s.ctx)
 
@@ -482,9 +496,17 @@ func (s *Blackjack) endGame(playerWinner bool) {
 		ID:      s.message.ID,
 		Channel: s.message.ChannelID,
 	})
-	delete(constants.Handlers.MessageComponents, "blackjack_hit")
-	delete(constants.Handlers.MessageComponents, "blackjack_stand")
-	constants.Handlers.MessageComponents["blackjack_restart"] = s.handleRestart
+	constants.Handlers.MessageComponents.Unregister("blackjack_hit", "blackjack_stand")
+	// Stop the game if nobody wants to play again
+	constants.Handlers.MessageComponents.Register("blackjack_exit", s.handleExit, restartTimeout, nil)
+	constants.Handlers.MessageComponents.Register("blackjack_restart", s.handleRestart, restartTimeout, func() {
+		s.bot.Lock()
+		defer s.bot.Unlock()
+
+		if s.state == over {
+			s.exit()
+		}
+	})
 }
 
 func (s *Blackjack) exit() {
@@ -514,13 +536,7 @@ func (s *Blackjack) exit() {
 		Channel:    s.message.ChannelID,
 	})
 
-	delete(constants.Handlers.MessageComponents, "blackjack_exit")
-	if s.state == over {
-		delete(constants.Handlers.MessageComponents, "blackjack_hit")
-		delete(constants.Handlers.MessageComponents, "blackjack_stand")
-	} else {
-		delete(constants.Handlers.MessageComponents, "blackjack_restart")
-	}
+	constants.Handlers.MessageComponents.Unregister("blackjack_hit", "blackjack_stand", "blackjack_exit", "blackjack_restart")
 
 	// Reset blackjack struct
 	*s = Blackjack{}
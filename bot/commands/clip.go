@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
@@ -26,7 +27,7 @@ const timeout time.Duration = 60 * time.Second
 
 // Reply with Pong! and the latency of the bot in ms
 func (s *Clip) HandleCommand(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
-	if len(args) < 2 || (len(args) == 2 && strings.ToLower(args[1]) == "help") {
+	if len(args) == 2 && strings.ToLower(args[1]) == "help" {
 		bot.ChannelMessageSend(ctx.ChannelID, s.Help())
 		return nil
 	}
@@ -86,6 +87,42 @@ func (s *Clip) HandleCommand(bot *discord.Session, ctx *discord.MessageCreate, a
 				Value: fmt.Sprintf("ID: %s\nAliases: %s", clipper.Id, strings.Join(clipper.Alias, ", ")),
 			})
 		}
+	} else if len(args) == 1 { // Clip all active lectures
+		res, err := s.client.List(timeoutCtx, &pb.ListRequest{})
+		if err != nil {
+			return err
+		}
+
+		clips := []*discord.MessageEmbedField{}
+		for _, clipper := range res.GetIds() {
+			clip, err := s.client.Clip(timeoutCtx, &pb.ClipRequest{LectureId: clipper.Id})
+			// The lecture may have ended since listing the clippers
+			if status.Code(err) == codes.InvalidArgument || err == nil && clip.Id == nil {
+				continue
+			} else if err != nil {
+				return err
+			}
+			clips = append(clips, &discord.MessageEmbedField{
+				Name:  "ID: " + clip.GetId(),
+				Value: clip.GetContentUrl(),
+			})
+		}
+
+		embed.Fields = []*discord.MessageEmbedField{
+			{
+				Name:  "Status: Done",
+				Value: fmt.Sprintf("Finished after: %.2f seconds.", time.Since(timer).Seconds()),
+			},
+		}
+		if len(clips) == 0 {
+			embed.Fields = []*discord.MessageEmbedField{
+				{
+					Name:  "No active lectures.",
+					Value: "No clips were created.",
+				},
+			}
+		}
+		embed.Fields = append(embed.Fields, clips...)
 	} else { // Make clip
 		res, err := s.client.Clip(timeoutCtx, req)
 		if err != nil {
@@ -139,7 +176,28 @@ func (s Clip) Desc() string {
 }
 
 func (s Clip) Help() string {
-	return "Usage: `clip [id]`. The ID is optional and specifies the lecture you want to clip.\nUse `clip list` to see the indexes, IDs and aliases of the active clippers. They are addressable by each of those properties."
+	return "Usage: `clip [id]`. The ID is optional and specifies the lecture you want to clip, all active lectures get clipped without it.\nUse `clip list` to see the indexes, IDs and aliases of the active clippers. They are addressable by each of those properties."
+}
+
+func (s Clip) Options() []*discord.ApplicationCommandOption {
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionString,
+			Name:        "lecture",
+			Description: "Index, ID or alias of the lecture to clip, or list to show the active clippers. Clips all if omitted",
+		},
+	}
+}
+
+func (s Clip) Permission(args []string) constants.Permission {
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s Clip) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 3, Interval: time.Minute},
+		Channel: ratelimit.Limit{Burst: 6, Interval: time.Minute},
+	}
 }
 
 func (s Clip) Init(args ...interface{}) constants.Command {
@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"time"
+
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 
 	discord "github.com/bwmarrin/discordgo"
 )
@@ -26,9 +29,13 @@ func (s *Help) HandleCommand(bot *discord.Session, ctx *discord.MessageCreate, a
 	}
 
 	for cmd, obj := range *s.Commands {
+		// Hide commands the user can't call
+		if !obj.Permission([]string{cmd}).Allows(bot, ctx.GuildID, ctx.Author, ctx.Member) {
+			continue
+		}
 		embed.Fields = append(embed.Fields, &discord.MessageEmbedField{
 			Name:  cmd,
-			Value: obj.Desc(),
+			Value: obj.Desc() + "\nUse `" + constants.Prefixes[0] + cmd + "` or `/" + cmd + "`",
 		})
 	}
 
@@ -45,6 +52,21 @@ func (s Help) Help() string {
 	return "The command does not take any additional arguments."
 }
 
+func (s Help) Options() []*discord.ApplicationCommandOption {
+	return []*discord.ApplicationCommandOption{}
+}
+
+func (s Help) Permission(args []string) constants.Permission {
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s Help) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 3, Interval: 30 * time.Second},
+		Channel: ratelimit.Limit{Burst: 6, Interval: 30 * time.Second},
+	}
+}
+
 func (s Help) Init(args ...interface{}) constants.Command {
 	commands, test := args[0].(*map[string]constants.Command)
 	if test {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
@@ -142,6 +143,37 @@ func (s ImageGeneration) Help() string {
 	return "Available commands: `image [help|bounce|fluid] [seed]`\nThe seed is optional. If no seed is specified, a random one will be chosen by Alphie."
 }
 
+func (s ImageGeneration) Options() []*discord.ApplicationCommandOption {
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionString,
+			Name:        "generator",
+			Description: "Which image to generate",
+			Required:    true,
+			Choices: []*discord.ApplicationCommandOptionChoice{
+				{Name: "bounce", Value: "bounce"},
+				{Name: "fluid", Value: "fluid"},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionString,
+			Name:        "seed",
+			Description: "The seed of the image, chosen by Alphie if omitted",
+		},
+	}
+}
+
+func (s ImageGeneration) Permission(args []string) constants.Permission {
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s ImageGeneration) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 2, Interval: time.Minute},
+		Channel: ratelimit.Limit{Burst: 4, Interval: time.Minute},
+	}
+}
+
 func (s ImageGeneration) Init(args ...interface{}) constants.Command {
 	// Establish the connection to the gRPC server
 	cdnUrl := os.Getenv("CDN_DOMAIN")
@@ -2,8 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 
 	discord "github.com/bwmarrin/discordgo"
 )
@@ -30,6 +32,21 @@ func (s Ping) Help() string {
 	return "The command does not take any additional arguments."
 }
 
+func (s Ping) Options() []*discord.ApplicationCommandOption {
+	return []*discord.ApplicationCommandOption{}
+}
+
+func (s Ping) Permission(args []string) constants.Permission {
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s Ping) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 5, Interval: 10 * time.Second},
+		Channel: ratelimit.Limit{Burst: 10, Interval: 10 * time.Second},
+	}
+}
+
 func (s Ping) Init(args ...interface{}) constants.Command {
 	return &s
 }
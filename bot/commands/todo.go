@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
 
 	subcommands "github.com/DominicWuest/Alphie/bot/commands/todo"
 
@@ -36,6 +37,38 @@ func (s *Todo) HandleCommand(bot *discord.Session, ctx *discord.MessageCreate, a
 		return sx.Subscribe(bot, ctx, args[2:])
 	case "archive": // Archives an item
 		return sx.Archive(bot, ctx, args[2:])
+	case "edit", "change": // Edits items
+		return sx.Edit(bot, ctx, args[2:])
+	case "subtask", "subtasks": // Manages the subtasks of items
+		return sx.Subtask(bot, ctx, args[2:])
+	case "group", "groups": // Shared lists of study groups
+		return sx.Group(bot, ctx, args[2:])
+	case "search", "find": // Searches items
+		return sx.Search(bot, ctx, args[2:])
+	case "repeat", "recurring", "every": // Items added on a personal schedule
+		return sx.Repeat(bot, ctx, args[2:])
+	case "export": // Sends the items as a file
+		return sx.Export(bot, ctx, args[2:])
+	case "import": // Adds the items of an attached file
+		return sx.Import(bot, ctx, args[2:])
+	case "remind", "reminder", "reminders": // Sets when to get reminded of due items
+		return sx.Remind(bot, ctx, args[2:])
+	case "digest": // Sets when to get sent the active items
+		return sx.Digest(bot, ctx, args[2:])
+	case "timezone", "tz": // Sets the timezone of the digest and stats
+		return sx.Timezone(bot, ctx, args[2:])
+	case "undo": // Reverts the last changes to items
+		return sx.Undo(bot, ctx, args[2:])
+	case "stats", "statistics": // Shows how many items were created and completed
+		return sx.Stats(bot, ctx, args[2:])
+	case "board": // Posts a message showing the active items which gets kept up to date
+		return sx.Board(bot, ctx, args[2:])
+	case "retention", "autoarchive": // Sets when items get archived automatically
+		return sx.Retention(bot, ctx, args[2:])
+	case "calendar", "semester", "semesters": // Shows the semesters and breaks
+		return sx.Calendar(bot, ctx, args[2:])
+	case "upcoming": // Shows when the subscriptions create items
+		return sx.Upcoming(bot, ctx, args[2:])
 	case "help":
 		bot.ChannelMessageSend(ctx.ChannelID, s.Help())
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
@@ -50,10 +83,527 @@ func (s Todo) Desc() string {
 }
 
 func (s Todo) Help() string {
-	return "Available commands: `todo [add|list|done|remove|subscribe|archive]`\nUse the command `todo [cmd] help` to get more info about the command."
+	return "Available commands: `todo [add|list|search|done|remove|edit|subtask|subscribe|upcoming|calendar|archive|undo|stats|board|retention|repeat|remind|digest|timezone|group|export|import]`\nUse the command `todo [cmd] help` to get more info about the command."
+}
+
+func (s Todo) Options() []*discord.ApplicationCommandOption {
+	// Optional IDs for commands which send a select menu if no IDs are supplied
+	idsOption := []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionString,
+			Name:        "ids",
+			Description: "Comma separated IDs of the items, lets you select them if omitted",
+		},
+	}
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "add",
+			Description: "Add a new item",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "title",
+					Description: "Title of the new item, may contain #tags, prio:high and due fri, opens a form if omitted",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "List your items",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "items",
+					Description: "Which items to list, defaults to the active ones",
+					Choices: []*discord.ApplicationCommandOptionChoice{
+						{Name: "active", Value: "active"},
+						{Name: "done", Value: "done"},
+						{Name: "archived", Value: "archived"},
+						{Name: "all", Value: "all"},
+					},
+				},
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "filters",
+					Description: "Filters such as #exam, prio>=high, due<7d or sort:due",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "search",
+			Description: "Search the titles and descriptions of all your items",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "query",
+					Description: "Words to search for, use quotes for phrases and - to exclude words",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "done",
+			Description: "Mark items or subtasks such as 12.3 as done",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "delete",
+			Description: "Delete items",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "archive",
+			Description: "Archive items",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "edit",
+			Description: "Edit the title, description, due date, tags and priority of items",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommandGroup,
+			Name:        "subtask",
+			Description: "Manage the subtasks of items",
+			Options:     subtaskOptions(),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommandGroup,
+			Name:        "subscribe",
+			Description: "Manage your subscriptions to default schedules",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List all schedules and your subscriptions",
+				},
+				{
+					Type:        discord.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Subscribe to schedules",
+				},
+				{
+					Type:        discord.ApplicationCommandOptionSubCommand,
+					Name:        "delete",
+					Description: "Unsubscribe from schedules",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommandGroup,
+			Name:        "repeat",
+			Description: "Manage items added to your list on a schedule",
+			Options:     repeatOptions(),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "remind",
+			Description: "Set how long before the due date of an item you get reminded",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "lead-times",
+					Description: "Lead times such as 1d 2h, or off to disable reminders. Shows your settings if omitted",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "undo",
+			Description: "Revert your last changes to your items, such as deleting them",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionInteger,
+					Name:        "count",
+					Description: "How many changes to revert, defaults to 1",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "stats",
+			Description: "See how many items you created and completed, and your streak",
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "board",
+			Description: "Post a pinned message showing your active items, kept up to date",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "group",
+					Description: "Show the items of this group and its members instead",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "calendar",
+			Description: "See the current and upcoming semesters and breaks",
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "upcoming",
+			Description: "See when your subscriptions create items",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "within",
+					Description: "How far ahead to look, such as 7d or 2w. Defaults to 7d",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "retention",
+			Description: "Archive completed and expired subscription items automatically",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "settings",
+					Description: "Such as archive 7d, archive off or expire on. Shows what would be archived if omitted",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "digest",
+			Description: "Get your active items sent regularly",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "schedule",
+					Description: "Such as daily 08:00, weekly mon 08:00 or off, append here to get it in this channel",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "timezone",
+			Description: "Set your timezone for your digest and stats",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "Such as Europe/Zurich, or reset. Shows your timezone if omitted",
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommandGroup,
+			Name:        "group",
+			Description: "Shared lists of study groups",
+			Options:     groupOptions(),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "export",
+			Description: "Get your items as a file, e.g. for your calendar app",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "format",
+					Description: "Format of the file",
+					Required:    true,
+					Choices: []*discord.ApplicationCommandOptionChoice{
+						{Name: "iCalendar", Value: "ics"},
+						{Name: "CSV", Value: "csv"},
+						{Name: "JSON", Value: "json"},
+						{Name: "Markdown", Value: "md"},
+					},
+				},
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "items",
+					Description: "Which items to export, defaults to the active ones",
+					Choices: []*discord.ApplicationCommandOptionChoice{
+						{Name: "active", Value: "active"},
+						{Name: "all", Value: "all"},
+					},
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "import",
+			Description: "Add the items of a CSV, JSON, Markdown or iCalendar file",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "File with the items, lets you deselect the ones you don't want to import",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "help",
+			Description: "Show the available TODO commands",
+		},
+	}
+}
+
+// Returns the subcommands of todo group
+func groupOptions() []*discord.ApplicationCommandOption {
+	// The group option comes first, as the subcommands expect the group as their first argument
+	withGroup := func(options ...*discord.ApplicationCommandOption) []*discord.ApplicationCommandOption {
+		return append([]*discord.ApplicationCommandOption{
+			{
+				Type:        discord.ApplicationCommandOptionString,
+				Name:        "group",
+				Description: "ID or name of the group",
+				Required:    true,
+			},
+		}, options...)
+	}
+	idsOption := &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionString,
+		Name:        "ids",
+		Description: "Comma separated IDs of the items",
+		Required:    true,
+	}
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "create",
+			Description: "Create a new group",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "Name of the group",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "List the groups you are in",
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "invite",
+			Description: "Invite a member to a group",
+			Options: withGroup(&discord.ApplicationCommandOption{
+				Type:        discord.ApplicationCommandOptionUser,
+				Name:        "member",
+				Description: "User to invite",
+				Required:    true,
+			}),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "leave",
+			Description: "Leave a group",
+			Options:     withGroup(),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "show",
+			Description: "List the items of a group",
+			Options: withGroup(&discord.ApplicationCommandOption{
+				Type:        discord.ApplicationCommandOptionString,
+				Name:        "items",
+				Description: "Which items to list, defaults to the active ones",
+				Choices: []*discord.ApplicationCommandOptionChoice{
+					{Name: "active", Value: "active"},
+					{Name: "done", Value: "done"},
+					{Name: "archived", Value: "archived"},
+					{Name: "all", Value: "all"},
+					{Name: "mine", Value: "mine"},
+				},
+			}),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "add",
+			Description: "Add an item to a group",
+			Options: withGroup(&discord.ApplicationCommandOption{
+				Type:        discord.ApplicationCommandOptionString,
+				Name:        "title",
+				Description: "Title of the new item, may contain #tags, prio:high and due fri",
+				Required:    true,
+			}),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "assign",
+			Description: "Assign an item to a member, or unassign it",
+			Options: withGroup(
+				&discord.ApplicationCommandOption{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "id",
+					Description: "ID of the item",
+					Required:    true,
+				},
+				&discord.ApplicationCommandOption{
+					Type:        discord.ApplicationCommandOptionUser,
+					Name:        "member",
+					Description: "Member to assign the item to, unassigns it if omitted",
+				},
+			),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "done",
+			Description: "Mark items of a group as done",
+			Options:     withGroup(idsOption),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "archive",
+			Description: "Archive items of a group",
+			Options:     withGroup(idsOption),
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "delete",
+			Description: "Delete items of a group",
+			Options:     withGroup(idsOption),
+		},
+	}
+}
+
+// Returns the subcommands of todo subtask
+func subtaskOptions() []*discord.ApplicationCommandOption {
+	subtaskIdsOption := &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionString,
+		Name:        "ids",
+		Description: "Comma separated IDs of the subtasks, such as 12.3",
+		Required:    true,
+	}
+	idOption := &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionString,
+		Name:        "id",
+		Description: "ID of the item",
+		Required:    true,
+	}
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "add",
+			Description: "Add subtasks to an item",
+			Options: []*discord.ApplicationCommandOption{
+				idOption,
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "titles",
+					Description: "Titles of the subtasks, separated by ;",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "List the subtasks of an item",
+			Options:     []*discord.ApplicationCommandOption{idOption},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "uncheck",
+			Description: "Mark subtasks as not done",
+			Options:     []*discord.ApplicationCommandOption{subtaskIdsOption},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "delete",
+			Description: "Delete subtasks",
+			Options:     []*discord.ApplicationCommandOption{subtaskIdsOption},
+		},
+	}
+}
+
+// Returns the subcommands of todo repeat
+func repeatOptions() []*discord.ApplicationCommandOption {
+	idsOption := []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionString,
+			Name:        "ids",
+			Description: "Comma separated IDs of the recurring items",
+			Required:    true,
+		},
+	}
+	return []*discord.ApplicationCommandOption{
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "add",
+			Description: "Add an item to your list on a schedule",
+			Options: []*discord.ApplicationCommandOption{
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "title",
+					Description: "Title of the items, may contain #tags and prio:high",
+					Required:    true,
+				},
+				{
+					Type:        discord.ApplicationCommandOptionString,
+					Name:        "schedule",
+					Description: "When to add the item in your timezone, such as sun 10:00 or weekday 7:30",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "List your recurring items",
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "pause",
+			Description: "Stop adding recurring items until they are resumed",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "resume",
+			Description: "Resume paused recurring items",
+			Options:     idsOption,
+		},
+		{
+			Type:        discord.ApplicationCommandOptionSubCommand,
+			Name:        "delete",
+			Description: "Delete recurring items, the items they already added stay",
+			Options:     idsOption,
+		},
+	}
+}
+
+func (s Todo) Permission(args []string) constants.Permission {
+	// Managing the subscriptions themselves and the academic calendar affects all users
+	if len(args) > 2 && args[2] == "admin" {
+		switch args[1] {
+		case "subscribe", "subscription", "subscriptions", "schedule", "schedules", "calendar", "semester", "semesters":
+			return constants.Permission{Level: constants.Owner}
+		}
+	}
+	return constants.Permission{Level: constants.Everyone}
+}
+
+func (s Todo) RateLimit() constants.RateLimit {
+	return constants.RateLimit{
+		User:    ratelimit.Limit{Burst: 10, Interval: 10 * time.Second},
+		Channel: ratelimit.Limit{Burst: 20, Interval: 10 * time.Second},
+	}
 }
 
 func (s Todo) Init(args ...interface{}) constants.Command {
+	bot, test := args[0].(*discord.Session)
+	if !test {
+		panic("Error: Passed wrong type to the init function for the command todo")
+	}
+	s.Bot = bot
+
 	connString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		os.Getenv("DB_HOSTNAME"),
 		os.Getenv("DB_PORT"),
@@ -90,10 +640,14 @@ func (s Todo) Init(args ...interface{}) constants.Command {
 		if err != nil {
 			fmt.Println("Error initialising subscriptions: ", err)
 		}
+		sx.InitialiseHandlers()
+		sx.InitialiseReminders()
+		sx.InitialiseRecurring()
+		sx.InitialiseDigests()
+		sx.InitialiseBoards()
+		sx.InitialiseRetention()
 
 	}
 
-	s.SelectedOptions = make(map[string][]string)
-
 	return &s
 }
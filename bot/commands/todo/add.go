@@ -10,8 +10,16 @@ import (
 	discord "github.com/bwmarrin/discordgo"
 )
 
+// Maximum lengths of the fields of the item modal, items from other sources have to fit into them to be editable
+const (
+	maxTitleLength       = 50
+	maxDescriptionLength = 300
+	maxDueLength         = 30
+	maxLabelsLength      = 200 // Tags and priority as formatTagsAndPriority formats them
+)
+
 func (s Todo) addHelp() string {
-	return "Call the `todo add` command with no arguments to add a new TODO item.\nAlternatively, you can use the command `todo add x1` to add an item with a title of `x1`."
+	return "Call the `todo add` command with no arguments to add a new TODO item.\nAlternatively, you can use the command `todo add x1` to add an item with a title of `x1`.\nAppend `due` and a date such as `tomorrow 18:00`, `fri`, `24.12.` or `in 3d` to give the item a due date, e.g. `todo add x1 due fri 18:00`.\nWords such as `#exam` tag the item and `prio:high` sets its priority to `low`, `medium` or `high`, e.g. `todo add x1 #exam prio:high`."
 }
 
 func (s Todo) Add(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
@@ -20,8 +28,7 @@ func (s Todo) Add(bot *discord.Session, ctx *discord.MessageCreate, args []strin
 	}
 	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 	if len(args) == 0 {
-		interactionId := "todo.add-button:" + ctx.Message.ID
-		msg, _ := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
 			Content:   "Press the button to add a new TODO item.\nAlternatively you can use the command `todo add x1` to add an item with a title of `x1`.",
 			Reference: ctx.MessageReference,
 			Components: []discord.MessageComponent{
@@ -30,50 +37,72 @@ func (s Todo) Add(bot *discord.Session, ctx *discord.MessageCreate, args []strin
 						discord.Button{
 							Label:    "Add TODO item",
 							Style:    discord.SuccessButton,
-							CustomID: interactionId,
+							CustomID: addButtonPrefix + ctx.Author.ID,
 						},
 					},
 				},
 			},
 		})
-		constants.Handlers.MessageComponents[interactionId] = func(interaction *discord.Interaction) error {
-			user := interaction.User
-			if user == nil {
-				user = interaction.Member.User
-			}
-			if user.ID == ctx.Author.ID {
-				delete(constants.Handlers.MessageComponents, interactionId)
-				bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-			}
-			s.addItemModalCreate(bot, interaction)
-			return nil
-		}
 	} else if len(args) == 1 && args[0] == "help" {
 		bot.ChannelMessageSend(ctx.ChannelID, s.addHelp())
 	} else { // Add new item with title
-		if err := s.addItem(ctx.Author.ID, strings.Join(args, " "), ""); err != nil {
+		now, err := s.userNow(ctx.Author.ID)
+		if err != nil {
 			return err
 		}
-		msg, _ := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
-			Content: "Successfully added item(s) with title " + strings.Join(args, " ") + ".",
-			AllowedMentions: &discord.MessageAllowedMentions{
-				Users: []string{},
-			},
-		})
-		time.Sleep(messageDeleteDelay)
+		rest, tags, priority := splitTagsAndPriority(args)
+		title, due := splitTitleAndDue(rest, now)
+		if title == "" {
+			bot.ChannelMessageSend(ctx.ChannelID, s.addHelp())
+			return nil
+		}
+		operation, err := s.addItem(ctx.Author.ID, todoItem{Title: title, Due: due, Tags: tags, Priority: priority}, "add")
+		if err != nil {
+			return err
+		}
+		content := "Successfully added item(s) with title " + title + "."
+		if due != nil {
+			content = "Successfully added item(s) with title " + title + ", " + strings.ToLower(formatDue(*due)) + "."
+		}
+		msg, _ := sendUndoable(bot, ctx.ChannelID, content, ctx.Author.ID, operation)
+		time.Sleep(undoMessageDelay)
 		bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
 	}
 	return nil
 }
 
+// Callback for the add button, whose CustomID contains the ID of the user who requested it
+func (s Todo) handleAddButton(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	owner := strings.TrimPrefix(interaction.MessageComponentData().CustomID, addButtonPrefix)
+	if interactionUser(interaction).ID == owner {
+		bot.ChannelMessageDelete(interaction.Message.ChannelID, interaction.Message.ID)
+	}
+	s.addItemModalCreate(bot, interaction)
+	return nil
+}
+
 // Responds to an interaction with the modal for a user to add an item
 func (s Todo) addItemModalCreate(bot *discord.Session, interaction *discord.Interaction) {
-	interactionId := "todo.add-button-modal:" + interaction.ID
+	itemModalCreate(bot, interaction, addModalPrefix+interaction.ID, "Add TODO item", todoItem{})
+}
+
+// Responds to an interaction with a modal for the title, description, due date, tags and priority of an item
+// The fields are pre-filled with the values of item, with the due date in its location
+func itemModalCreate(bot *discord.Session, interaction *discord.Interaction, customId, modalTitle string, item todoItem) {
+	due := ""
+	if item.Due != nil {
+		due = item.Due.Format(modalDueFormat)
+	}
+
 	bot.InteractionRespond(interaction, &discord.InteractionResponse{
 		Type: discord.InteractionResponseModal,
 		Data: &discord.InteractionResponseData{
-			CustomID: interactionId,
-			Title:    "Add TODO item",
+			CustomID: customId,
+			Title:    modalTitle,
 			Components: []discord.MessageComponent{
 				discord.ActionsRow{
 					Components: []discord.MessageComponent{
@@ -82,8 +111,9 @@ func (s Todo) addItemModalCreate(bot *discord.Session, interaction *discord.Inte
 							Label:       "TODO item title",
 							Style:       discord.TextInputShort,
 							Placeholder: "Enter title here...",
+							Value:       item.Title,
 							MinLength:   1,
-							MaxLength:   50,
+							MaxLength:   maxTitleLength,
 							Required:    true,
 						},
 					},
@@ -95,7 +125,34 @@ func (s Todo) addItemModalCreate(bot *discord.Session, interaction *discord.Inte
 							Label:       "TODO item description",
 							Style:       discord.TextInputParagraph,
 							Placeholder: "Enter description here...",
-							MaxLength:   300,
+							Value:       item.Description,
+							MaxLength:   maxDescriptionLength,
+							Required:    false,
+						},
+					},
+				},
+				discord.ActionsRow{
+					Components: []discord.MessageComponent{
+						discord.TextInput{
+							CustomID:    "todo.add-button-modal-due:" + interaction.ID,
+							Label:       "TODO item due date",
+							Style:       discord.TextInputShort,
+							Placeholder: "E.g. tomorrow 18:00, fri, 24.12. or in 3d",
+							Value:       due,
+							MaxLength:   maxDueLength,
+							Required:    false,
+						},
+					},
+				},
+				discord.ActionsRow{
+					Components: []discord.MessageComponent{
+						discord.TextInput{
+							CustomID:    "todo.add-button-modal-labels:" + interaction.ID,
+							Label:       "TODO item tags and priority",
+							Style:       discord.TextInputShort,
+							Placeholder: "E.g. #exam #analysis prio:high",
+							Value:       formatTagsAndPriority(item.Tags, item.Priority),
+							MaxLength:   maxLabelsLength,
 							Required:    false,
 						},
 					},
@@ -103,48 +160,126 @@ func (s Todo) addItemModalCreate(bot *discord.Session, interaction *discord.Inte
 			},
 		},
 	})
+}
 
-	constants.Handlers.ModalSubmit[interactionId] = func(interaction *discord.Interaction) error {
-		bot.InteractionRespond(interaction, &discord.InteractionResponse{
-			Type: discord.InteractionResponseDeferredMessageUpdate,
-		})
-
-		user := interaction.User
-		if user == nil {
-			user = interaction.Member.User
+// Returns the values of a submitted item modal, with the due date relative to now
+// If the due date or the tags and priority are invalid, the user gets told so and ok is false
+func parseItemModal(bot *discord.Session, interaction *discord.Interaction, now time.Time) (item todoItem, ok bool) {
+	// Get title
+	titleRow := interaction.ModalSubmitData().Components[0].(*discord.ActionsRow)
+	item.Title = (*titleRow.Components[0].(*discord.TextInput)).Value
+	// Get description
+	descRow := *interaction.ModalSubmitData().Components[1].(*discord.ActionsRow)
+	item.Description = (*descRow.Components[0].(*discord.TextInput)).Value
+	// Get due date, modals of older messages may not have one
+	if len(interaction.ModalSubmitData().Components) > 2 {
+		dueRow := *interaction.ModalSubmitData().Components[2].(*discord.ActionsRow)
+		if rawDue := (*dueRow.Components[0].(*discord.TextInput)).Value; rawDue != "" {
+			parsed, err := parseDue(rawDue, now)
+			if err != nil {
+				bot.InteractionRespond(interaction, &discord.InteractionResponse{
+					Type: discord.InteractionResponseChannelMessageWithSource,
+					Data: &discord.InteractionResponseData{
+						Content: "`" + rawDue + "` is not a valid due date, your changes were not saved.\nUse dates such as `tomorrow 18:00`, `fri`, `24.12.` or `in 3d`.",
+						Flags:   uint64(discord.MessageFlagsEphemeral),
+					},
+				})
+				return item, false
+			}
+			item.Due = &parsed
 		}
-
-		// Get title
-		titleRow := interaction.ModalSubmitData().Components[0].(*discord.ActionsRow)
-		title := (*titleRow.Components[0].(*discord.TextInput)).Value
-		// Get description
-		descRow := *interaction.ModalSubmitData().Components[1].(*discord.ActionsRow)
-		desc := (*descRow.Components[0].(*discord.TextInput)).Value
-		if err := s.addItem(user.ID, title, desc); err != nil {
-			return err
+	}
+	// Get tags and priority, modals of older messages may not have them
+	if len(interaction.ModalSubmitData().Components) > 3 {
+		labelsRow := *interaction.ModalSubmitData().Components[3].(*discord.ActionsRow)
+		rawLabels := (*labelsRow.Components[0].(*discord.TextInput)).Value
+		rest, tags, priority := splitTagsAndPriority(strings.Fields(rawLabels))
+		if len(rest) != 0 {
+			bot.InteractionRespond(interaction, &discord.InteractionResponse{
+				Type: discord.InteractionResponseChannelMessageWithSource,
+				Data: &discord.InteractionResponseData{
+					Content: "`" + strings.Join(rest, " ") + "` are not valid tags or priorities, your changes were not saved.\nUse tags such as `#exam` and priorities such as `prio:high`.",
+					Flags:   uint64(discord.MessageFlagsEphemeral),
+				},
+			})
+			return item, false
 		}
-		return nil
+		item.Tags, item.Priority = tags, priority
 	}
+	return item, true
 }
 
-// Adds an active todo item
-func (s Todo) addItem(author, title, description string) error {
-	taskId, err := s.CreateTask(author, title, description)
+// Callback for the add modal, adds the item to the user who submitted it
+func (s Todo) handleAddModal(bot *discord.Session, interaction *discord.Interaction) error {
+	user := interactionUser(interaction)
+	if err := s.checkUserPresence(user.ID); err != nil {
+		return err
+	}
+	now, err := s.userNow(user.ID)
 	if err != nil {
 		return err
 	}
 
+	item, ok := parseItemModal(bot, interaction, now)
+	if !ok {
+		return nil
+	}
+
+	bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredMessageUpdate,
+	})
+
+	_, err = s.addItem(user.ID, item, "add")
+	return err
+}
+
+// Adds an active todo item, the addition is journaled as the action
+// Returns the ID of the journaled operation
+func (s Todo) addItem(author string, item todoItem, action string) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	taskId, err := createTask(ctx, tx, author, item)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
+	}
+
 	// Insert task into active
-	if _, err := s.DB.ExecContext(ctx,
+	if _, err := tx.Exec(
 		`INSERT INTO todo.active (discord_user, task) VALUES ($1, $2)`,
 		author,
 		taskId,
 	); err != nil {
-		return err
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
 	}
 
-	return nil
+	operation, err := recordOperation(tx, author, action, []listChange{{Task: taskId, To: "active"}})
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	notifyBoards(author)
+
+	if item.Due != nil {
+		return operation, s.scheduleReminders(author)
+	}
+
+	return operation, nil
 }
@@ -19,15 +19,10 @@ func (s Todo) Archive(bot *discord.Session, ctx *discord.MessageCreate, args []s
 		return err
 	}
 	if len(args) == 0 { // Send message to archive items in bulk
-		actives, err1 := s.getActiveTodos(ctx.Author.ID)
-		if err1 != nil {
-			return err1
-		}
-		completed, err2 := s.getDoneTodos(ctx.Author.ID)
-		if err2 != nil {
-			return err2
+		items, err := s.getArchivableTodos(ctx.Author.ID)
+		if err != nil {
+			return err
 		}
-		items := append(actives, completed...)
 		if len(items) == 0 {
 			msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, "You have no TODO items.", ctx.Reference())
 
@@ -41,43 +36,10 @@ func (s Todo) Archive(bot *discord.Session, ctx *discord.MessageCreate, args []s
 		return s.sendItemSelectMessage(
 			bot,
 			ctx,
-			items,
+			itemsToOptions(items),
 			ctx.Author.Mention()+", please mark which items you want to archive.",
 			"Items to archive",
-			func(items []string, msg *discord.Message) error {
-				content := "Successfully archived " + strings.Join(items, ", ") + "."
-				if len(items) == 0 {
-					content = "Didn't archive any items."
-				}
-
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				if err := s.archiveItems(ctx.Author.ID, items); err != nil {
-					return err
-				}
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-				return nil
-			},
-			func(items []string, msg *discord.Message) error {
-				content := "Cancelled"
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
-				return nil
-			},
+			"archive",
 		)
 	} else if len(args) == 1 && args[0] == "help" { // Send help message
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
@@ -91,7 +53,8 @@ func (s Todo) Archive(bot *discord.Session, ctx *discord.MessageCreate, args []s
 			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 			return nil
 		}
-		if err = s.archiveItems(ctx.Author.ID, ids); err != nil {
+		operation, err := s.archiveItems(ctx.Author.ID, ids)
+		if err != nil {
 			switch err.(type) {
 			case *InvalidIDError:
 				msg, _ := bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("You supplied an invalid ID: %v", err))
@@ -103,44 +66,64 @@ func (s Todo) Archive(bot *discord.Session, ctx *discord.MessageCreate, args []s
 				return err
 			}
 		}
-		msg, _ := bot.ChannelMessageSend(ctx.ChannelID, "Successfully archived "+strings.Join(ids, ", ")+".")
-		time.Sleep(messageDeleteDelay)
+		msg, _ := sendUndoable(bot, ctx.ChannelID, "Successfully archived "+strings.Join(ids, ", ")+".", ctx.Author.ID, operation)
+		time.Sleep(undoMessageDelay)
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 	}
 	return nil
 }
 
-// Archives active/completed items from the user
+// Select action archiving the selected items
+func (s Todo) archiveSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	operation, err := s.archiveItems(user.ID, items)
+	if err != nil {
+		return err
+	}
+
+	content := "Successfully archived " + strings.Join(items, ", ") + "."
+	if len(items) == 0 {
+		content = "Didn't archive any items."
+	}
+
+	finishSelectMessage(bot, msg, content, undoComponents(user.ID, operation)...)
+	return nil
+}
+
+// Archives active/completed items from the user, returns the ID of the journaled operation
 // Returns an InvalidIDError if invalid IDs were supplied
-func (s Todo) archiveItems(userId string, items []string) error {
+func (s Todo) archiveItems(userId string, items []string) (int, error) {
 	active, err := s.getActiveTodos(userId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	completed, err := s.getDoneTodos(userId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	userItems := append(active, completed...)
-
-	// For checking for invalid IDs
-	itemsCopy := make([]string, len(items))
-	copy(itemsCopy, items)
-
-	for _, item := range userItems {
-		for i := range itemsCopy {
-			if itemsCopy[i] == fmt.Sprint(item.ID) {
-				itemsCopy = append(itemsCopy[:i], itemsCopy[i+1:]...)
-				break
-			}
-		}
+	// List every item is in, for journaling where it came from
+	lists := map[string]string{}
+	for _, item := range active {
+		lists[fmt.Sprint(item.ID)] = "active"
+	}
+	for _, item := range completed {
+		lists[fmt.Sprint(item.ID)] = "completed"
 	}
 
 	// Check for wrong ID supplied
-	if len(itemsCopy) != 0 {
-		return &InvalidIDError{itemsCopy}
+	invalid := []string{}
+	changes := []listChange{}
+	for _, item := range items {
+		list, found := lists[item]
+		if !found {
+			invalid = append(invalid, item)
+			continue
+		}
+		changes = append(changes, moveChanges([]string{item}, list, "archived")...)
+	}
+	if len(invalid) != 0 {
+		return 0, &InvalidIDError{invalid}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
@@ -148,7 +131,7 @@ func (s Todo) archiveItems(userId string, items []string) error {
 
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Delete items
@@ -158,10 +141,10 @@ func (s Todo) archiveItems(userId string, items []string) error {
 			pq.Array(items),
 		)
 		if err != nil {
-			if err1 := tx.Rollback(); err != nil {
-				return err1
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, err1
 			}
-			return err
+			return 0, err
 		}
 	}
 
@@ -171,15 +154,24 @@ func (s Todo) archiveItems(userId string, items []string) error {
 		pq.Array(items),
 	)
 	if err != nil {
-		if err1 := tx.Rollback(); err != nil {
-			return err1
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
 		}
-		return err
+		return 0, err
+	}
+
+	operation, err := recordOperation(tx, userId, "archive", changes)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	notifyBoards(userId)
+	return operation, nil
 }
@@ -0,0 +1,427 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	boardDebounce       = 5 * time.Second // How long changes are collected before the affected boards get edited
+	maxBoardMembers     = 20              // Maximum number of members listed on a group board, embeds have at most 25 fields
+	boardOverflowLength = 32              // Length reserved for the field summarising the sections which don't fit into a board anymore
+)
+
+// Refreshes the boards when the lists shown on them change, nil until the boards are initialised
+var boards *boardUpdater
+
+// Message showing the active items of a user or a group
+type board struct {
+	ID      int
+	Channel string
+	Message string
+	User    string // Who posted the board
+	Group   int    // 0 for the board of User
+}
+
+// Lists which changed since the boards were last refreshed
+type boardChanges struct {
+	Users  map[string]bool
+	Groups map[int]bool
+	All    bool
+}
+
+// Collects changes and refreshes the affected boards at most once per delay
+type boardUpdater struct {
+	mutex   sync.Mutex
+	delay   time.Duration
+	refresh func(boardChanges)
+	pending boardChanges
+	timer   *time.Timer
+}
+
+func newBoardUpdater(delay time.Duration, refresh func(boardChanges)) *boardUpdater {
+	return &boardUpdater{
+		delay:   delay,
+		refresh: refresh,
+		pending: boardChanges{Users: map[string]bool{}, Groups: map[int]bool{}},
+	}
+}
+
+// Records the change and starts the timer for refreshing the boards if it isn't running yet
+func (u *boardUpdater) change(fun func(*boardChanges)) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	fun(&u.pending)
+	if u.timer == nil {
+		u.timer = time.AfterFunc(u.delay, u.flush)
+	}
+}
+
+// Refreshes the boards of all changes collected so far
+func (u *boardUpdater) flush() {
+	u.mutex.Lock()
+	changes := u.pending
+	u.pending = boardChanges{Users: map[string]bool{}, Groups: map[int]bool{}}
+	u.timer = nil
+	u.mutex.Unlock()
+
+	u.refresh(changes)
+}
+
+// Marks the lists of the users as changed
+func notifyBoards(userIds ...string) {
+	if boards == nil {
+		return
+	}
+	boards.change(func(changes *boardChanges) {
+		for _, userId := range userIds {
+			changes.Users[userId] = true
+		}
+	})
+}
+
+// Marks the lists of the groups as changed
+func notifyGroupBoards(groupIds ...int) {
+	if boards == nil {
+		return
+	}
+	boards.change(func(changes *boardChanges) {
+		for _, groupId := range groupIds {
+			changes.Groups[groupId] = true
+		}
+	})
+}
+
+// Marks all lists as changed
+func notifyAllBoards() {
+	if boards == nil {
+		return
+	}
+	boards.change(func(changes *boardChanges) {
+		changes.All = true
+	})
+}
+
+func (s Todo) boardHelp() string {
+	return "Usage: `todo board [group]`\nPosts and pins a message showing your active items, or the ones of a group and its members, which gets updated whenever they change.\nPosting a new board replaces the previous one in the same channel, deleting the message stops the updates."
+}
+
+func (s Todo) Board(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.boardHelp())
+		return nil
+	}
+
+	newBoard := board{Channel: ctx.ChannelID, User: ctx.Author.ID}
+	if len(args) != 0 {
+		group, err := s.getGroup(ctx.Author.ID, strings.Join(args, " "))
+		if err != nil {
+			switch err.(type) {
+			case *InvalidIDError:
+				s.replyAndDelete(bot, ctx, "You are not in a group called `"+strings.Join(args, " ")+"`.")
+				return nil
+			default:
+				return err
+			}
+		}
+		newBoard.Group = group.ID
+	}
+
+	embed, err := s.boardEmbed(newBoard, time.Now())
+	if err != nil {
+		return err
+	}
+	msg, err := bot.ChannelMessageSendEmbed(ctx.ChannelID, embed)
+	if err != nil {
+		return err
+	}
+	newBoard.Message = msg.ID
+	// Pinning fails without the permission to manage messages, the board still works without it
+	bot.ChannelMessagePin(ctx.ChannelID, msg.ID)
+
+	replaced, err := s.registerBoard(newBoard)
+	if err != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+		return err
+	}
+	for _, message := range replaced {
+		bot.ChannelMessageDelete(ctx.ChannelID, message)
+	}
+
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	return nil
+}
+
+// Starts refreshing the boards on changes and refreshes all of them, as they may have changed while the bot was offline
+func (s Todo) InitialiseBoards() {
+	boards = newBoardUpdater(boardDebounce, func(changes boardChanges) {
+		if err := s.refreshBoards(changes, time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to refresh boards:", err)
+		}
+	})
+	notifyAllBoards()
+}
+
+// Saves the board, replacing the boards of the same list in its channel, and returns the messages of the replaced ones
+func (s Todo) registerBoard(newBoard board) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`DELETE FROM todo.board
+		WHERE channel=$1 AND (study_group=$3 OR $3=0 AND study_group IS NULL AND discord_user=$2)
+		RETURNING message`,
+		newBoard.Channel,
+		newBoard.User,
+		newBoard.Group,
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, err1
+		}
+		return nil, err
+	}
+	replaced := []string{}
+	for rows.Next() {
+		var message string
+		rows.Scan(&message)
+		replaced = append(replaced, message)
+	}
+	rows.Close()
+
+	var group interface{}
+	if newBoard.Group != 0 {
+		group = newBoard.Group
+	}
+	if _, err := tx.Exec(`INSERT INTO todo.board (channel, message, discord_user, study_group) VALUES ($1, $2, $3, $4)`,
+		newBoard.Channel,
+		newBoard.Message,
+		newBoard.User,
+		group,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, err1
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("%s User %s posted board %s in channel %s; Group: %d\n", constants.Blue, newBoard.User, newBoard.Message, newBoard.Channel, newBoard.Group)
+	return replaced, nil
+}
+
+// Returns the boards showing one of the changed lists
+// Group boards also show the items of their members, so they are affected by changes of the members as well
+func (s Todo) getChangedBoards(changes boardChanges) ([]board, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	users, groups := []string{}, []int64{}
+	for user := range changes.Users {
+		users = append(users, user)
+	}
+	for group := range changes.Groups {
+		groups = append(groups, int64(group))
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, channel, message, discord_user, study_group FROM todo.board
+		WHERE $3 OR (study_group IS NULL AND discord_user=ANY($1)) OR study_group=ANY($2)
+		OR study_group IN (SELECT study_group FROM todo.group_member WHERE discord_user=ANY($1))
+		ORDER BY id`,
+		pq.Array(users),
+		pq.Array(groups),
+		changes.All,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []board{}
+	for rows.Next() {
+		b := board{}
+		var group sql.NullInt64
+		rows.Scan(&b.ID, &b.Channel, &b.Message, &b.User, &group)
+		b.Group = int(group.Int64)
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// Edits the boards showing one of the changed lists, forgetting the ones whose message is gone
+func (s Todo) refreshBoards(changes boardChanges, now time.Time) error {
+	changed, err := s.getChangedBoards(changes)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range changed {
+		embed, err := s.boardEmbed(b, now)
+		if err != nil {
+			return err
+		}
+		if _, err := s.Bot.ChannelMessageEditEmbed(b.Channel, b.Message, embed); err != nil {
+			if !isUnknownMessage(err) {
+				log.Println(constants.Red, "Failed to edit board", b.ID, err)
+				continue
+			}
+			if err := s.deleteBoard(b.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns whether the error was caused by the message or its channel having been deleted
+func isUnknownMessage(err error) bool {
+	restErr, ok := err.(*discord.RESTError)
+	return ok && restErr.Message != nil &&
+		(restErr.Message.Code == discord.ErrCodeUnknownMessage || restErr.Message.Code == discord.ErrCodeUnknownChannel)
+}
+
+func (s Todo) deleteBoard(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM todo.board WHERE id=$1`, id); err != nil {
+		return err
+	}
+
+	log.Printf("%s Deleted board %d, as its message is gone\n", constants.Blue, id)
+	return nil
+}
+
+// Returns the group with the ID
+func (s Todo) getGroupById(groupId int) (studyGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	group := studyGroup{}
+	var members pq.StringArray
+	if err := s.DB.QueryRowContext(ctx, `SELECT g.id, g.group_name, g.creator, ARRAY_AGG(m.discord_user ORDER BY m.discord_user)
+		FROM todo.study_group AS g JOIN todo.group_member AS m ON m.study_group=g.id
+		WHERE g.id=$1 GROUP BY g.id`,
+		groupId,
+	).Scan(&group.ID, &group.Name, &group.Creator, &members); err != nil {
+		return studyGroup{}, err
+	}
+	group.Members = members
+	return group, nil
+}
+
+// Returns the current embed of the board
+func (s Todo) boardEmbed(b board, now time.Time) (*discord.MessageEmbed, error) {
+	if b.Group == 0 {
+		items, err := s.getActiveTodos(b.User)
+		if err != nil {
+			return nil, err
+		}
+		return boardToEmbed("TODO Board", fmt.Sprintf("Active items of <@%s>", b.User), []boardSection{{Name: "Active", Items: items}}, now), nil
+	}
+
+	group, err := s.getGroupById(b.Group)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.getGroupTODOs(group.ID, []string{"active"}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sections := []boardSection{{Name: "Shared"}}
+	for _, item := range shared {
+		sections[0].Items = append(sections[0].Items, item.todoItem)
+	}
+	for i, member := range group.Members {
+		if i == maxBoardMembers {
+			break
+		}
+		items, err := s.getActiveTodos(member)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, boardSection{Member: member, Items: items})
+	}
+	return boardToEmbed("TODO Board: "+group.Name, "Active items of the group and its members", sections, now), nil
+}
+
+// Items shown in one field of a board, the ones of Member if it isn't empty
+type boardSection struct {
+	Name   string
+	Items  []todoItem
+	Member string
+}
+
+func boardToEmbed(title, description string, sections []boardSection, now time.Time) *discord.MessageEmbed {
+	footer := "Updated live, last update"
+
+	// The sections share what is left of the total length of the embed evenly, space left over by a section goes to the next ones
+	remaining := embedTotalLength - len(title) - len(description) - len(footer) - boardOverflowLength
+	fields := []*discord.MessageEmbedField{}
+	for i, section := range sections {
+		name := fmt.Sprintf("%s (%d)", section.Name, len(section.Items))
+		prefix := ""
+		if section.Member != "" {
+			name = fmt.Sprintf("%d active items", len(section.Items))
+			// Mentions only work in field values
+			prefix = "<@" + section.Member + ">\n"
+		}
+
+		limit := remaining/(len(sections)-i) - len(name) - len(prefix)
+		if limit > embedFieldLength-len(prefix) {
+			limit = embedFieldLength - len(prefix)
+		}
+		if limit < boardOverflowLength {
+			fields = append(fields, &discord.MessageEmbedField{
+				Name:  "More",
+				Value: fmt.Sprintf("…and %d more", len(sections)-i),
+			})
+			break
+		}
+
+		value := strings.TrimSpace(prefix + itemLinesWithin(section.Items, limit))
+		if section.Member == "" && len(section.Items) == 0 {
+			value = "Nothing to do"
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  name,
+			Value: value,
+		})
+		remaining -= len(name) + len(value)
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: title,
+		},
+		Description: description,
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text: footer,
+		},
+		Timestamp: now.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,132 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoardUpdaterDebounces(t *testing.T) {
+	refreshed := make(chan boardChanges, 2)
+	updater := newBoardUpdater(20*time.Millisecond, func(changes boardChanges) {
+		refreshed <- changes
+	})
+
+	updater.change(func(changes *boardChanges) { changes.Users["0"] = true })
+	updater.change(func(changes *boardChanges) { changes.Users["1"] = true })
+	updater.change(func(changes *boardChanges) { changes.Groups[2] = true })
+
+	select {
+	case changes := <-refreshed:
+		assert.Equal(t, map[string]bool{"0": true, "1": true}, changes.Users)
+		assert.Equal(t, map[int]bool{2: true}, changes.Groups)
+		assert.False(t, changes.All)
+	case <-time.After(time.Second):
+		t.Fatal("boards weren't refreshed")
+	}
+
+	// Changes after a refresh start a new one
+	updater.change(func(changes *boardChanges) { changes.All = true })
+	select {
+	case changes := <-refreshed:
+		assert.Empty(t, changes.Users)
+		assert.True(t, changes.All)
+	case <-time.After(time.Second):
+		t.Fatal("boards weren't refreshed")
+	}
+}
+
+func TestNotifyBoardsUninitialised(t *testing.T) {
+	assert.NotPanics(t, func() {
+		notifyBoards("0")
+		notifyGroupBoards(1)
+		notifyAllBoards()
+	})
+}
+
+func TestRegisterBoard(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.board`).
+		WithArgs("10", "0", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"message"}).AddRow("99"))
+	dbMock.ExpectExec(`INSERT INTO todo.board`).
+		WithArgs("10", "100", "0", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
+
+	replaced, err := mockTodo.registerBoard(board{Channel: "10", Message: "100", User: "0"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"99"}, replaced)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestIsUnknownMessage(t *testing.T) {
+	tests := []struct {
+		input    error
+		expected bool
+	}{
+		{&discord.RESTError{Message: &discord.APIErrorMessage{Code: discord.ErrCodeUnknownMessage}}, true},
+		{&discord.RESTError{Message: &discord.APIErrorMessage{Code: discord.ErrCodeUnknownChannel}}, true},
+		{&discord.RESTError{Message: &discord.APIErrorMessage{Code: discord.ErrCodeMissingPermissions}}, false},
+		{&discord.RESTError{}, false},
+		{assert.AnError, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, isUnknownMessage(test.input))
+	}
+}
+
+func TestBoardToEmbed(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	embed := boardToEmbed("TODO Board: Analysis", "", []boardSection{
+		{Name: "Shared"},
+		{Member: "1", Items: []todoItem{{ID: 1, Title: "Exercise sheet"}}},
+		{Member: "2"},
+	}, now)
+
+	assert.Equal(t, "2022-06-01T12:00:00Z", embed.Timestamp)
+	assert.Len(t, embed.Fields, 3)
+	assert.Equal(t, "Shared (0)", embed.Fields[0].Name)
+	assert.Equal(t, "Nothing to do", embed.Fields[0].Value)
+	assert.Equal(t, "1 active items", embed.Fields[1].Name)
+	assert.Equal(t, "<@1>\n`ID: 1` Exercise sheet", embed.Fields[1].Value)
+	assert.Equal(t, "<@2>", embed.Fields[2].Value)
+}
+
+func TestBoardToEmbedTotalLength(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	items := []todoItem{}
+	for i := 0; i < 50; i++ {
+		items = append(items, todoItem{ID: i, Title: strings.Repeat("a", 50)})
+	}
+	sections := []boardSection{{Name: "Shared", Items: items}}
+	for i := 0; i < maxBoardMembers; i++ {
+		sections = append(sections, boardSection{Member: fmt.Sprint(i), Items: items})
+	}
+
+	embed := boardToEmbed("TODO Board: Analysis", "Active items of the group and its members", sections, now)
+
+	length := len(embed.Author.Name) + len(embed.Description) + len(embed.Footer.Text)
+	for _, field := range embed.Fields {
+		length += len(field.Name) + len(field.Value)
+		assert.LessOrEqual(t, len(field.Value), embedFieldLength)
+	}
+	assert.LessOrEqual(t, length, embedTotalLength)
+	assert.Len(t, embed.Fields, len(sections))
+	assert.True(t, strings.HasSuffix(embed.Fields[0].Value, "more"))
+	assert.True(t, strings.HasPrefix(embed.Fields[1].Value, "<@0>\n"))
+
+	// Sections which don't fit at all get summarised
+	for i := 0; i < 200; i++ {
+		sections = append(sections, boardSection{Member: fmt.Sprint(i)})
+	}
+	embed = boardToEmbed("TODO Board: Analysis", "", sections, now)
+	assert.True(t, strings.HasPrefix(embed.Fields[len(embed.Fields)-1].Value, "…and "))
+}
@@ -0,0 +1,228 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/calendar"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxCalendarPeriods = 10           // Maximum number of upcoming semesters and breaks shown by todo calendar
+	periodDateFormat   = "2006-01-02" // Format of the first and last days of periods in todo calendar admin
+)
+
+func (s Todo) calendarHelp() string {
+	return "Usage: `todo calendar`\nShows the current and upcoming semesters and breaks. Subscriptions restricted to a semester only create items during it, but not during breaks.\nOwners of the bot can manage them with `todo calendar admin`."
+}
+
+func (s Todo) calendarAdminHelp() string {
+	return "Usage: `todo calendar admin [add|remove]`\n" +
+		"`todo calendar admin add <F|H|break> <first day> <last day> <name>` adds a semester or break, e.g. `todo calendar admin add F 2027-02-22 2027-06-06 Spring semester 2027`.\n" +
+		"`todo calendar admin remove <name>` removes the semesters and breaks with the name. Only owners of the bot may use these commands."
+}
+
+func (s Todo) Calendar(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if len(args) != 0 && args[0] == "admin" {
+		return s.calendarAdmin(bot, ctx, args[1:])
+	}
+	if len(args) != 0 {
+		bot.ChannelMessageSend(ctx.ChannelID, s.calendarHelp())
+		return nil
+	}
+
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return err
+	}
+
+	bot.ChannelMessageSendEmbed(ctx.ChannelID, calendarToEmbed(academicCalendar, time.Now()))
+	return nil
+}
+
+// Returns the academic calendar in the timezone of the bot
+func (s Todo) getCalendar() (calendar.Calendar, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	return calendar.Load(ctx, s.DB, time.Local)
+}
+
+// Returns whether subscriptions restricted to the semester create items at the time
+// Times the calendar doesn't cover get logged, as the subscriptions are paused until the owners add the upcoming semesters
+func (s Todo) semesterActive(semester string, now time.Time) (bool, error) {
+	if semester == calendar.None {
+		return true, nil
+	}
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return false, err
+	}
+	if !academicCalendar.Covers(now) {
+		log.Println(constants.Red, "The academic calendar doesn't contain any semester after", now.Format(time.RFC3339), "add the upcoming ones with todo calendar admin add")
+	}
+	return academicCalendar.Active(semester, now), nil
+}
+
+func (s Todo) calendarAdmin(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if !constants.IsOwner(ctx.Author.ID) {
+		s.replyAndDelete(bot, ctx, "Only owners of the bot may manage the academic calendar.")
+		return nil
+	}
+
+	var content string
+	switch {
+	case len(args) >= 2 && args[0] == "add":
+		period, err := parsePeriod(args[1:])
+		if err != nil {
+			s.replyAndDelete(bot, ctx, "Couldn't parse the period: "+err.Error()+".")
+			return nil
+		}
+		if err := s.addPeriod(period); err != nil {
+			return err
+		}
+		content = fmt.Sprintf("Added the %s from <t:%d:D> to <t:%d:D>.", period.Name, period.Start.Unix(), period.End.AddDate(0, 0, -1).Unix())
+	case len(args) >= 2 && args[0] == "remove":
+		name := strings.Join(args[1:], " ")
+		if err := s.removePeriod(name); err != nil {
+			switch err.(type) {
+			case *InvalidIDError:
+				s.replyAndDelete(bot, ctx, "There is no semester or break called `"+name+"`.")
+				return nil
+			default:
+				return err
+			}
+		}
+		content = "Removed the " + name + "."
+	default:
+		bot.ChannelMessageSend(ctx.ChannelID, s.calendarAdminHelp())
+		return nil
+	}
+
+	log.Println(constants.Yellow, "User", ctx.Author.Username, "changed the academic calendar:", args)
+	bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+	return nil
+}
+
+// Parses a period of the form <F|H|break> <first day> <last day> <name>
+// The end of the returned period is the start of the day after the last day, like the ones of calendar.Load
+func parsePeriod(args []string) (calendar.Period, error) {
+	if len(args) < 4 {
+		return calendar.Period{}, fmt.Errorf("expected a semester, the first and last day and a name")
+	}
+
+	semester := strings.ToUpper(args[0])
+	switch semester {
+	case calendar.Spring, calendar.Fall:
+	case "BREAK":
+		semester = ""
+	default:
+		return calendar.Period{}, fmt.Errorf("the semester has to be one of F, H and break")
+	}
+
+	start, err := time.ParseInLocation(periodDateFormat, args[1], time.Local)
+	if err != nil {
+		return calendar.Period{}, fmt.Errorf("the first day has to be of the form YYYY-MM-DD")
+	}
+	last, err := time.ParseInLocation(periodDateFormat, args[2], time.Local)
+	if err != nil {
+		return calendar.Period{}, fmt.Errorf("the last day has to be of the form YYYY-MM-DD")
+	}
+	if last.Before(start) {
+		return calendar.Period{}, fmt.Errorf("the last day is before the first day")
+	}
+
+	name := strings.Join(args[3:], " ")
+	if len(name) > 64 {
+		return calendar.Period{}, fmt.Errorf("the name is longer than 64 characters")
+	}
+
+	return calendar.Period{Name: name, Semester: semester, Start: start, End: last.AddDate(0, 0, 1)}, nil
+}
+
+// Adds the semester or break to the academic calendar
+func (s Todo) addPeriod(period calendar.Period) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO academic_calendar.period (period_name, semester, start_date, end_date) VALUES ($1, NULLIF($2, ''), $3, $4)`,
+		period.Name,
+		period.Semester,
+		period.Start.Format(periodDateFormat),
+		period.End.AddDate(0, 0, -1).Format(periodDateFormat),
+	); err != nil {
+		return err
+	}
+
+	log.Printf("%s Added period %s to the academic calendar; semester: %s, from %s until %s\n", constants.Blue, period.Name, period.Semester, period.Start.Format(periodDateFormat), period.End.Format(periodDateFormat))
+	return nil
+}
+
+// Removes the semesters and breaks with the name from the academic calendar, ignoring case
+// Returns an InvalidIDError if there are none
+func (s Todo) removePeriod(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM academic_calendar.period WHERE LOWER(period_name)=LOWER($1)`, name)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return &InvalidIDError{[]string{name}}
+	}
+
+	log.Printf("%s Removed period %s from the academic calendar\n", constants.Blue, name)
+	return nil
+}
+
+func calendarToEmbed(academicCalendar calendar.Calendar, now time.Time) *discord.MessageEmbed {
+	description := "There is no semester right now."
+	if current, found := academicCalendar.Semester(now); found {
+		description = fmt.Sprintf("It is week %d of the %s.", int(now.Sub(current.Start).Hours()/24/7)+1, current.Name)
+	}
+	if current, found := academicCalendar.Break(now); found {
+		description += " Subscriptions are paused for the " + current.Name + "."
+	}
+
+	fields := []*discord.MessageEmbedField{}
+	for _, period := range academicCalendar.Upcoming(now) {
+		if len(fields) == maxCalendarPeriods {
+			break
+		}
+		name := period.Name
+		if period.Contains(now) {
+			name += " (now)"
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  name,
+			Value: fmt.Sprintf("<t:%d:D> to <t:%d:D>", period.Start.Unix(), period.End.AddDate(0, 0, -1).Unix()),
+		})
+	}
+
+	footer := "Owners of the bot manage the semesters and breaks with todo calendar admin"
+	if !academicCalendar.Covers(now) {
+		footer = "The calendar doesn't contain any upcoming semesters, so subscriptions restricted to semesters are paused until owners add them with todo calendar admin add"
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: "Academic Calendar",
+		},
+		Description: description,
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text: footer,
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package todo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/calendar"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectCalendar() {
+	dbMock.ExpectQuery(`SELECT period_name, semester, start_date, end_date FROM academic_calendar.period`).
+		WillReturnRows(sqlmock.NewRows([]string{"period_name", "semester", "start_date", "end_date"}).
+			AddRow("Spring semester 2022", "F", time.Date(2022, 2, 21, 0, 0, 0, 0, time.UTC), time.Date(2022, 6, 5, 0, 0, 0, 0, time.UTC)).
+			AddRow("Easter break 2022", nil, time.Date(2022, 4, 15, 0, 0, 0, 0, time.UTC), time.Date(2022, 4, 24, 0, 0, 0, 0, time.UTC)).
+			AddRow("Fall semester 2022", "H", time.Date(2022, 9, 19, 0, 0, 0, 0, time.UTC), time.Date(2022, 12, 25, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSemesterActive(t *testing.T) {
+	tests := []struct {
+		semester string
+		time     time.Time
+		expected bool
+	}{
+		{"F", time.Date(2022, 3, 15, 10, 0, 0, 0, time.Local), true},
+		{"H", time.Date(2022, 3, 15, 10, 0, 0, 0, time.Local), false},
+		{"B", time.Date(2022, 4, 18, 10, 0, 0, 0, time.Local), false},
+		{"B", time.Date(2022, 10, 3, 10, 0, 0, 0, time.Local), true},
+	}
+
+	for _, test := range tests {
+		expectCalendar()
+
+		active, err := mockTodo.semesterActive(test.semester, test.time)
+
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, active, test)
+		assert.Nil(t, dbMock.ExpectationsWereMet())
+	}
+
+	// Subscriptions which aren't restricted to semesters don't need the calendar
+	active, err := mockTodo.semesterActive("N", time.Now())
+	assert.Nil(t, err)
+	assert.True(t, active)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCalendarToEmbed(t *testing.T) {
+	academicCalendar := calendar.New([]calendar.Period{
+		{Name: "Spring semester 2022", Semester: "F", Start: time.Date(2022, 2, 21, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)},
+		{Name: "Easter break 2022", Start: time.Date(2022, 4, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 4, 25, 0, 0, 0, 0, time.UTC)},
+	})
+
+	embed := calendarToEmbed(academicCalendar, time.Date(2022, 4, 18, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "It is week 9 of the Spring semester 2022. Subscriptions are paused for the Easter break 2022.", embed.Description)
+	assert.Len(t, embed.Fields, 2)
+	assert.Equal(t, "Spring semester 2022 (now)", embed.Fields[0].Name)
+	assert.Equal(t, "<t:1645401600:D> to <t:1654387200:D>", embed.Fields[0].Value)
+
+	embed = calendarToEmbed(academicCalendar, time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "There is no semester right now.", embed.Description)
+	assert.Empty(t, embed.Fields)
+	assert.Contains(t, embed.Footer.Text, "doesn't contain any upcoming semesters")
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected calendar.Period
+		valid    bool
+	}{
+		{
+			[]string{"F", "2027-02-22", "2027-06-06", "Spring", "semester", "2027"},
+			calendar.Period{Name: "Spring semester 2027", Semester: calendar.Spring, Start: time.Date(2027, 2, 22, 0, 0, 0, 0, time.Local), End: time.Date(2027, 6, 7, 0, 0, 0, 0, time.Local)},
+			true,
+		},
+		{
+			[]string{"break", "2027-03-26", "2027-04-04", "Easter", "break", "2027"},
+			calendar.Period{Name: "Easter break 2027", Start: time.Date(2027, 3, 26, 0, 0, 0, 0, time.Local), End: time.Date(2027, 4, 5, 0, 0, 0, 0, time.Local)},
+			true,
+		},
+		{[]string{"B", "2027-02-22", "2027-06-06", "Both"}, calendar.Period{}, false},
+		{[]string{"F", "22.02.2027", "2027-06-06", "Spring"}, calendar.Period{}, false},
+		{[]string{"F", "2027-06-06", "2027-02-22", "Spring"}, calendar.Period{}, false},
+		{[]string{"F", "2027-02-22", "2027-06-06"}, calendar.Period{}, false},
+	}
+
+	for _, test := range tests {
+		period, err := parsePeriod(test.input)
+		if test.valid {
+			assert.Nil(t, err, test.input)
+			assert.Equal(t, test.expected, period)
+		} else {
+			assert.NotNil(t, err, test.input)
+		}
+	}
+}
+
+func TestAddPeriod(t *testing.T) {
+	dbMock.ExpectExec(`INSERT INTO academic_calendar.period`).
+		WithArgs("Spring semester 2027", "F", "2027-02-22", "2027-06-06").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := mockTodo.addPeriod(calendar.Period{Name: "Spring semester 2027", Semester: calendar.Spring, Start: time.Date(2027, 2, 22, 0, 0, 0, 0, time.Local), End: time.Date(2027, 6, 7, 0, 0, 0, 0, time.Local)})
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestRemovePeriodWrongName(t *testing.T) {
+	dbMock.ExpectExec(`DELETE FROM academic_calendar.period`).
+		WithArgs("Spring semester 2030").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.IsType(t, &InvalidIDError{}, mockTodo.removePeriod("Spring semester 2030"))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
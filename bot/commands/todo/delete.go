@@ -35,43 +35,10 @@ func (s Todo) Delete(bot *discord.Session, ctx *discord.MessageCreate, args []st
 		return s.sendItemSelectMessage(
 			bot,
 			ctx,
-			items,
+			itemsToOptions(items),
 			ctx.Author.Mention()+", please mark which items you want to delete.",
 			"Items to delete",
-			func(items []string, msg *discord.Message) error {
-				content := "Successfully deleted " + strings.Join(items, ", ") + "."
-				if len(items) == 0 {
-					content = "Didn't delete any items."
-				}
-
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				if err := s.deleteItems(ctx.Author.ID, items); err != nil {
-					return err
-				}
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-				return nil
-			},
-			func(items []string, msg *discord.Message) error {
-				content := "Cancelled"
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
-				return nil
-			},
+			"delete",
 		)
 	} else if len(args) == 1 && args[0] == "help" { // Send help message
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
@@ -85,7 +52,8 @@ func (s Todo) Delete(bot *discord.Session, ctx *discord.MessageCreate, args []st
 			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 			return nil
 		}
-		if err = s.deleteItems(ctx.Author.ID, ids); err != nil {
+		operation, err := s.deleteItems(ctx.Author.ID, ids)
+		if err != nil {
 			switch err.(type) {
 			case *InvalidIDError:
 				msg, _ := bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("You supplied an invalid ID: %v", err))
@@ -97,20 +65,36 @@ func (s Todo) Delete(bot *discord.Session, ctx *discord.MessageCreate, args []st
 				return err
 			}
 		}
-		msg, _ := bot.ChannelMessageSend(ctx.ChannelID, "Successfully deleted "+strings.Join(ids, ", "))
-		time.Sleep(messageDeleteDelay)
+		msg, _ := sendUndoable(bot, ctx.ChannelID, "Successfully deleted "+strings.Join(ids, ", "), ctx.Author.ID, operation)
+		time.Sleep(undoMessageDelay)
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 	}
 	return nil
 }
 
-// Deletes todo items from the user
+// Select action deleting the selected items
+func (s Todo) deleteSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	operation, err := s.deleteItems(user.ID, items)
+	if err != nil {
+		return err
+	}
+
+	content := "Successfully deleted " + strings.Join(items, ", ") + "."
+	if len(items) == 0 {
+		content = "Didn't delete any items."
+	}
+
+	finishSelectMessage(bot, msg, content, undoComponents(user.ID, operation)...)
+	return nil
+}
+
+// Deletes todo items from the user, returns the ID of the journaled operation
 // Returns an InvalidIDError if invalid IDs were supplied
-func (s Todo) deleteItems(userId string, items []string) error {
+func (s Todo) deleteItems(userId string, items []string) (int, error) {
 	userItems, err := s.getAllTodos(userId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// For checking for invalid IDs
@@ -128,7 +112,7 @@ func (s Todo) deleteItems(userId string, items []string) error {
 
 	// Check for wrong ID supplied
 	if len(itemsCopy) != 0 {
-		return &InvalidIDError{itemsCopy}
+		return 0, &InvalidIDError{itemsCopy}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
@@ -136,26 +120,42 @@ func (s Todo) deleteItems(userId string, items []string) error {
 
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Delete items
+	// Delete items, remembering which list they were in so they can be restored
+	changes := []listChange{}
 	for _, table := range []string{"active", "completed", "archived"} {
-		_, err = tx.Exec(fmt.Sprintf(`DELETE FROM todo.%s WHERE discord_user=$1 AND task=any($2)`, table),
+		rows, err := tx.Query(fmt.Sprintf(`DELETE FROM todo.%s WHERE discord_user=$1 AND task=any($2) RETURNING task`, table),
 			userId,
 			pq.Array(items),
 		)
 		if err != nil {
-			if err1 := tx.Rollback(); err != nil {
-				return err1
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, err1
 			}
-			return err
+			return 0, err
 		}
+		for rows.Next() {
+			change := listChange{From: table}
+			rows.Scan(&change.Task)
+			changes = append(changes, change)
+		}
+		rows.Close()
+	}
+
+	operation, err := recordOperation(tx, userId, "delete", changes)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit changes while deleting items: %w", err)
+		return 0, fmt.Errorf("failed to commit changes while deleting items: %w", err)
 	}
 
-	return nil
+	notifyBoards(userId)
+	return operation, nil
 }
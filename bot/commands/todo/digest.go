@@ -0,0 +1,390 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron"
+)
+
+const (
+	digestInterval    = time.Minute    // How often to check for digests which have to be sent
+	firstDigestPeriod = 24 * time.Hour // Subscription items added this long before the first digest count as new
+)
+
+// The digest of the active items a user opted into
+type digestSettings struct {
+	Schedule string // Cronjob format in the timezone of the user
+	Phrase   string // Schedule as the user entered it
+	Channel  string // Empty if the digest is sent by DM
+	NextRun  time.Time
+}
+
+func (s Todo) digestHelp() string {
+	return "Usage: `todo digest [daily [time]|weekly day [time]|off] [here]`\nSends you your active items regularly by DM, highlighting overdue ones and new items of your subscriptions, e.g. `todo digest daily 08:00` or `todo digest weekly mon 08:00`.\nAppend `here` to get the digest in this channel instead, use `todo digest off` to stop it and `todo timezone` to set your timezone."
+}
+
+func (s Todo) Digest(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.digestHelp())
+		return nil
+	}
+
+	var content string
+	switch {
+	case len(args) == 0: // Show the current settings
+		digest, err := s.getDigest(ctx.Author.ID)
+		if err != nil {
+			return err
+		}
+		content = "You don't get a digest of your items."
+		if digest != nil {
+			content = "You get a digest of your items " + formatDigest(*digest) + "."
+		}
+		bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+		return nil
+	case len(args) == 1 && args[0] == "off":
+		if err := s.deleteDigest(ctx.Author.ID); err != nil {
+			return err
+		}
+		content = "You won't get a digest of your items anymore."
+	default:
+		digest, err := parseDigest(args)
+		if err != nil {
+			bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Couldn't interpret the schedule: %v.\n%s", err, s.digestHelp()))
+			return nil
+		}
+		// Digests requested in DMs are sent by DM anyway
+		if digest.Channel != "" {
+			digest.Channel = ""
+			if ctx.GuildID != "" {
+				digest.Channel = ctx.ChannelID
+			}
+		}
+		if digest, err = s.setDigest(ctx.Author.ID, digest, time.Now()); err != nil {
+			return err
+		}
+		content = "You will get a digest of your items " + formatDigest(digest) + "."
+	}
+
+	msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+	return nil
+}
+
+// Describes the schedule and destination of a digest
+func formatDigest(digest digestSettings) string {
+	destination := "by DM"
+	if digest.Channel != "" {
+		destination = "in <#" + digest.Channel + ">"
+	}
+	return fmt.Sprintf("`%s` %s, the next one <t:%d:R>", digest.Phrase, destination, digest.NextRun.Unix())
+}
+
+// Parses the arguments of todo digest, such as `weekly mon 08:00 here`
+// The channel of the returned digest is "here" if the digest should be sent to the channel of the command
+func parseDigest(args []string) (digestSettings, error) {
+	digest := digestSettings{}
+	if len(args) != 0 && strings.ToLower(args[len(args)-1]) == "here" {
+		digest.Channel = "here"
+		args = args[:len(args)-1]
+	}
+	if len(args) == 0 {
+		return digest, fmt.Errorf("the schedule is missing")
+	}
+
+	var words []string
+	switch strings.ToLower(args[0]) {
+	case "daily", "day":
+		words = append([]string{"day"}, args[1:]...)
+	case "weekly", "week":
+		if len(args) < 2 {
+			return digest, fmt.Errorf("the day of the week is missing")
+		}
+		if _, found := weekdays[strings.ToLower(args[1])]; !found {
+			return digest, fmt.Errorf("%q isn't a day of the week", args[1])
+		}
+		words = args[1:]
+	default:
+		return digest, fmt.Errorf("a digest is either daily or weekly")
+	}
+
+	schedule, err := parseRepeatSchedule(words)
+	if err != nil {
+		return digest, err
+	}
+	digest.Schedule = schedule
+	digest.Phrase = strings.ToLower(strings.Join(args, " "))
+	return digest, nil
+}
+
+// Returns the digest of the user, nil if they don't get one
+func (s Todo) getDigest(userId string) (*digestSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	digest := digestSettings{}
+	var channel sql.NullString
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT schedule, phrase, channel, next_run FROM todo.digest WHERE discord_user=$1`,
+		userId,
+	).Scan(&digest.Schedule, &digest.Phrase, &channel, &digest.NextRun)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	digest.Channel = channel.String
+	return &digest, nil
+}
+
+// Sets the digest of the user, scheduling the next one in their timezone
+func (s Todo) setDigest(userId string, digest digestSettings, now time.Time) (digestSettings, error) {
+	schedule, err := scheduleParser.Parse(digest.Schedule)
+	if err != nil {
+		return digest, err
+	}
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return digest, err
+	}
+	digest.NextRun = schedule.Next(now.In(location))
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO todo.digest (discord_user, schedule, phrase, channel, next_run)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (discord_user) DO UPDATE SET schedule=$2, phrase=$3, channel=NULLIF($4, ''), next_run=$5`,
+		userId,
+		digest.Schedule,
+		digest.Phrase,
+		digest.Channel,
+		digest.NextRun,
+	); err != nil {
+		return digest, err
+	}
+
+	log.Printf("%s Set users %s digest to %s\n", constants.Blue, userId, digest.Schedule)
+	return digest, nil
+}
+
+// Stops the digest of the user
+func (s Todo) deleteDigest(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.digest WHERE discord_user=$1`, userId)
+	return err
+}
+
+// Starts checking for digests which have to be sent
+// Digests which should have been sent while the bot was down get sent right away
+func (s Todo) InitialiseDigests() {
+	c.Schedule(cron.Every(digestInterval), cron.FuncJob(func() {
+		if err := s.sendDueDigests(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to send digests:", err)
+		}
+	}))
+	c.Start()
+
+	go func() {
+		if err := s.sendDueDigests(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to send digests:", err)
+		}
+	}()
+}
+
+// Sends all digests due by now and schedules the next ones
+func (s Todo) sendDueDigests(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT d.discord_user, d.schedule, d.channel, d.last_sent, u.timezone
+		FROM todo.digest AS d JOIN todo.discord_user AS u ON u.id=d.discord_user WHERE d.next_run <= $1`,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	type dueDigest struct {
+		userId   string
+		schedule string
+		channel  string
+		since    time.Time // Subscription items added after this are new
+		location *time.Location
+	}
+	due := []dueDigest{}
+	for rows.Next() {
+		var digest dueDigest
+		var channel, timezone sql.NullString
+		var lastSent sql.NullTime
+		if err := rows.Scan(&digest.userId, &digest.schedule, &channel, &lastSent, &timezone); err != nil {
+			rows.Close()
+			return err
+		}
+		digest.channel = channel.String
+		digest.location = loadLocation(timezone.String)
+		digest.since = now.Add(-firstDigestPeriod)
+		if lastSent.Valid {
+			digest.since = lastSent.Time
+		}
+		due = append(due, digest)
+	}
+	rows.Close()
+
+	for _, digest := range due {
+		schedule, err := scheduleParser.Parse(digest.schedule)
+		if err != nil {
+			log.Println(constants.Red, "Invalid schedule of the digest of user", digest.userId, err)
+			continue
+		}
+
+		// Schedule the next digest first, so a failure doesn't send the digest over and over
+		if err := s.scheduleNextDigest(digest.userId, schedule.Next(now.In(digest.location)), now); err != nil {
+			return err
+		}
+
+		items, err := s.getActiveTodos(digest.userId)
+		if err != nil {
+			return err
+		}
+		newTasks, err := s.getNewSubscriptionTasks(digest.userId, digest.since)
+		if err != nil {
+			return err
+		}
+
+		go s.sendDigest(digest.userId, digest.channel, digestToEmbed(items, newTasks, now))
+	}
+
+	return nil
+}
+
+// Schedules the next digest of the user, the current one counts as sent at the time
+func (s Todo) scheduleNextDigest(userId string, next, sent time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `UPDATE todo.digest SET next_run=$2, last_sent=$3 WHERE discord_user=$1`,
+		userId,
+		next,
+		sent,
+	)
+	return err
+}
+
+// Returns the IDs of the tasks added to the user by their subscriptions since the time
+func (s Todo) getNewSubscriptionTasks(userId string, since time.Time) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT c.task FROM todo.operation AS o JOIN todo.operation_change AS c ON c.operation=o.id
+		WHERE o.discord_user=$1 AND o.action=$2 AND o.created_at>$3`,
+		userId,
+		subscriptionAction,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []int{}
+	for rows.Next() {
+		var task int
+		if err := rows.Scan(&task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// Returns the embed of a digest, listing overdue items and new items of subscriptions before the other active items
+func digestToEmbed(items []todoItem, newTasks []int, now time.Time) *discord.MessageEmbed {
+	isNew := map[int]bool{}
+	for _, task := range newTasks {
+		isNew[task] = true
+	}
+
+	var overdue, added, other []todoItem
+	for _, item := range items {
+		switch {
+		case item.Due != nil && item.Due.Before(now):
+			overdue = append(overdue, item)
+		case isNew[item.ID]:
+			added = append(added, item)
+		default:
+			other = append(other, item)
+		}
+	}
+
+	fields := []*discord.MessageEmbedField{}
+	for _, section := range []struct {
+		name  string
+		items []todoItem
+	}{{"Overdue", overdue}, {"New from your subscriptions", added}, {"Active", other}} {
+		if len(section.items) != 0 {
+			fields = append(fields, &discord.MessageEmbedField{
+				Name:  fmt.Sprintf("%s (%d)", section.name, len(section.items)),
+				Value: itemLines(section.items),
+			})
+		}
+	}
+
+	description := fmt.Sprintf("You have %d active items.", len(items))
+	if len(items) == 0 {
+		description = "You have no active items."
+	}
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: "TODO Digest",
+		},
+		Description: description,
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text: "Use todo digest to change when you get your digest",
+		},
+	}
+}
+
+// Sends the digest to the channel, or to the user by DM if it is empty
+func (s Todo) sendDigest(userId, channelId string, embed *discord.MessageEmbed) {
+	if channelId == "" {
+		channel, err := s.Bot.UserChannelCreate(userId)
+		if err != nil {
+			log.Println(constants.Red, "Couldn't open DM channel to send digest to user", userId, err)
+			return
+		}
+		channelId = channel.ID
+	}
+
+	if _, err := s.Bot.ChannelMessageSendComplex(channelId, &discord.MessageSend{
+		Content: "<@" + userId + ">",
+		Embeds:  []*discord.MessageEmbed{embed},
+		AllowedMentions: &discord.MessageAllowedMentions{
+			Users: []string{userId},
+		},
+	}); err != nil {
+		log.Println(constants.Red, "Couldn't send digest to user", userId, err)
+		return
+	}
+
+	log.Println(constants.Blue, "Sent digest to user", userId)
+}
@@ -0,0 +1,125 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDigest(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput digestSettings
+		expectError    bool
+	}{
+		{"daily", digestSettings{Schedule: "0 8 * * *", Phrase: "daily"}, false},
+		{"daily 18:30", digestSettings{Schedule: "30 18 * * *", Phrase: "daily 18:30"}, false},
+		{"weekly Mon 08:00 here", digestSettings{Schedule: "0 8 * * 1", Phrase: "weekly mon 08:00", Channel: "here"}, false},
+
+		{"", digestSettings{}, true},
+		{"here", digestSettings{Channel: "here"}, true},
+		{"weekly", digestSettings{}, true},
+		{"weekly 08:00", digestSettings{}, true},
+		{"monthly 1.", digestSettings{}, true},
+		{"daily 25:00", digestSettings{}, true},
+	}
+
+	for _, test := range tests {
+		digest, err := parseDigest(strings.Fields(test.input))
+		assert.Equal(t, test.expectError, err != nil, test.input)
+		assert.Equal(t, test.expectedOutput, digest, test.input)
+	}
+}
+
+func TestDigestToEmbed(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	items := []todoItem{
+		{ID: 1, Title: "Exercise 1", Due: &future},
+		{ID: 2, Title: "Exercise 0", Due: &past},
+		{ID: 3, Title: "Lecture 4"},
+	}
+
+	embed := digestToEmbed(items, []int{3, 7}, now)
+
+	assert.Equal(t, "You have 3 active items.", embed.Description)
+	assert.Len(t, embed.Fields, 3)
+	assert.Equal(t, "Overdue (1)", embed.Fields[0].Name)
+	assert.Contains(t, embed.Fields[0].Value, "Exercise 0")
+	assert.Equal(t, "New from your subscriptions (1)", embed.Fields[1].Name)
+	assert.Equal(t, "`ID: 3` Lecture 4", embed.Fields[1].Value)
+	assert.Equal(t, "Active (1)", embed.Fields[2].Name)
+
+	assert.Empty(t, digestToEmbed([]todoItem{}, []int{}, now).Fields)
+}
+
+func TestItemLinesTruncated(t *testing.T) {
+	items := []todoItem{}
+	for i := 0; i < 100; i++ {
+		items = append(items, todoItem{ID: i, Title: strings.Repeat("a", 40)})
+	}
+
+	lines := itemLines(items)
+
+	assert.LessOrEqual(t, len(lines), embedFieldLength)
+	assert.True(t, strings.HasSuffix(lines, " more"))
+}
+
+func TestSetDigest(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC) // A Wednesday
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	next := time.Date(2022, 10, 17, 8, 0, 0, 0, zurich)
+
+	dbMock.ExpectQuery(`SELECT timezone FROM todo.discord_user`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Zurich"))
+	dbMock.ExpectExec(`INSERT INTO todo.digest (.+) ON CONFLICT \(discord_user\) DO UPDATE`).
+		WithArgs("0", "0 8 * * 1", "weekly mon", "", next).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	digest, err := mockTodo.setDigest("0", digestSettings{Schedule: "0 8 * * 1", Phrase: "weekly mon"}, now)
+
+	assert.Nil(t, err)
+	assert.True(t, next.Equal(digest.NextRun))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSetTimezone(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`UPDATE todo.discord_user SET timezone`).
+		WithArgs("0", "America/New_York").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery(`SELECT schedule FROM todo.digest`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"schedule"}).AddRow("0 8 * * *"))
+	// The digest is moved to 8:00 in the new timezone
+	dbMock.ExpectExec(`UPDATE todo.digest SET next_run`).
+		WithArgs("0", time.Date(2022, 10, 13, 8, 0, 0, 0, newYork)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// So are the recurring items which aren't paused
+	dbMock.ExpectQuery(`SELECT id, schedule FROM todo.recurring WHERE discord_user=\$1 AND NOT paused`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "schedule"}).AddRow(3, "0 10 * * 0"))
+	dbMock.ExpectExec(`UPDATE todo.recurring SET next_run`).
+		WithArgs(3, time.Date(2022, 10, 16, 10, 0, 0, 0, newYork)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	err := mockTodo.setTimezone("0", "America/New_York", now)
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestLoadLocation(t *testing.T) {
+	assert.Equal(t, time.Local, loadLocation(""))
+	assert.Equal(t, time.Local, loadLocation("Mars/Olympus_Mons"))
+	assert.Equal(t, "Europe/Zurich", loadLocation("Europe/Zurich").String())
+}
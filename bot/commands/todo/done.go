@@ -10,10 +10,10 @@ import (
 )
 
 func (s Todo) doneHelp() string {
-	return "Usage: `todo done [id[,id..]]`\nAlternatively, call `todo done` with no arguments to check off items in bulk without having to supply IDs."
+	return "Usage: `todo done [id[,id..]]`\nSubtasks are checked off with `todo done id.position`, e.g. `todo done 12.3`, the item is marked as done once all of its subtasks are.\nAlternatively, call `todo done` with no arguments to check off items in bulk without having to supply IDs."
 }
 
-func (s *Todo) Done(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+func (s Todo) Done(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
 	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
 		return err
 	}
@@ -33,43 +33,10 @@ func (s *Todo) Done(bot *discord.Session, ctx *discord.MessageCreate, args []str
 		return s.sendItemSelectMessage(
 			bot,
 			ctx,
-			items,
+			itemsToOptions(items),
 			ctx.Author.Mention()+", please mark which items you completed.",
 			"Completed Items",
-			func(items []string, msg *discord.Message) error {
-				if err := s.changeItemsStatus(ctx.Author.ID, items, "active", "completed"); err != nil {
-					return err
-				}
-
-				content := "Successfully marked off " + strings.Join(items, ", ") + " as done."
-				if len(items) == 0 {
-					content = "Didn't mark any items as done."
-				}
-
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-				return nil
-			},
-			func(items []string, msg *discord.Message) error {
-				content := "Cancelled"
-				bot.ChannelMessageEditComplex(&discord.MessageEdit{
-					Content:    &content,
-					Components: []discord.MessageComponent{},
-					ID:         msg.ID,
-					Channel:    ctx.ChannelID,
-				})
-
-				time.Sleep(messageDeleteDelay)
-				bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
-				return nil
-			},
+			"done",
 		)
 	} else if len(args) == 1 && args[0] == "help" { // Send help message
 		bot.ChannelMessageSend(ctx.ChannelID, s.doneHelp())
@@ -84,7 +51,8 @@ func (s *Todo) Done(bot *discord.Session, ctx *discord.MessageCreate, args []str
 			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 			return nil
 		}
-		if err = s.changeItemsStatus(ctx.Author.ID, ids, "active", "completed"); err != nil {
+		// Replies to invalid IDs instead of failing
+		handleErr := func(err error) error {
 			switch err.(type) {
 			case *InvalidIDError:
 				msg, _ := bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("You supplied an invalid ID: %v", err))
@@ -96,10 +64,52 @@ func (s *Todo) Done(bot *discord.Session, ctx *discord.MessageCreate, args []str
 				return err
 			}
 		}
-		msg, _ := bot.ChannelMessageSend(ctx.ChannelID, "Successfully marked "+strings.Join(ids, ", ")+" as done.")
-		time.Sleep(messageDeleteDelay)
+
+		tasks, subtasks := splitSubtaskIds(ids)
+		completed := []string{}
+		var subtasksOperation, operation int
+		if len(subtasks) != 0 {
+			if completed, subtasksOperation, err = s.completeSubtasks(ctx.Author.ID, subtasks); err != nil {
+				return handleErr(err)
+			}
+		}
+		// Items completed by checking off their last subtask are already done
+		remaining := []string{}
+		for _, task := range tasks {
+			if !contains(completed, task) {
+				remaining = append(remaining, task)
+			}
+		}
+		if len(remaining) != 0 {
+			if operation, err = s.changeItemsStatus(ctx.Author.ID, remaining, "active", "completed"); err != nil {
+				return handleErr(err)
+			}
+		}
+
+		content := "Successfully marked " + strings.Join(ids, ", ") + " as done."
+		if len(completed) != 0 {
+			content += "\nAll subtasks of " + strings.Join(completed, ", ") + " are done, so they were marked as done too."
+		}
+		msg, _ := sendUndoable(bot, ctx.ChannelID, content, ctx.Author.ID, subtasksOperation, operation)
+		time.Sleep(undoMessageDelay)
 		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
 	}
 	return nil
 }
+
+// Select action marking the selected items as done
+func (s Todo) doneSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	operation, err := s.changeItemsStatus(user.ID, items, "active", "completed")
+	if err != nil {
+		return err
+	}
+
+	content := "Successfully marked off " + strings.Join(items, ", ") + " as done."
+	if len(items) == 0 {
+		content = "Didn't mark any items as done."
+	}
+
+	finishSelectMessage(bot, msg, content, undoComponents(user.ID, operation)...)
+	return nil
+}
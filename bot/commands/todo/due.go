@@ -0,0 +1,179 @@
+package todo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time of day of due dates given without a time
+const (
+	defaultDueHour   = 23
+	defaultDueMinute = 59
+)
+
+// Format of due dates pre-filled in modals, understood by parseDue
+const modalDueFormat = "2.1.2006 15:04"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var (
+	timeRegex     = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?h?$`)
+	isoDateRegex  = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	dateRegex     = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{4})?)?$`)
+	durationRegex = regexp.MustCompile(`^(\d+)(m|min|h|d|w)$`)
+)
+
+// Parses due dates in natural phrases relative to now, such as "tomorrow 18:00", "fri", "in 3d" or "24.12. 12:00"
+func parseDue(raw string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(raw))
+	if len(fields) > 0 && fields[0] == "in" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, fmt.Errorf("invalid due date %q", raw)
+	}
+
+	// Relative due dates, e.g. "3d"
+	if len(fields) == 1 {
+		if duration, err := parseLeadTime(fields[0]); err == nil {
+			return now.Add(duration), nil
+		}
+	}
+
+	hour, minute := defaultDueHour, defaultDueMinute
+	// The time is always the last field
+	if match := timeRegex.FindStringSubmatch(fields[len(fields)-1]); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		minute = 0
+		if match[2] != "" {
+			minute, _ = strconv.Atoi(match[2])
+		}
+		if hour > 23 || minute > 59 {
+			return time.Time{}, fmt.Errorf("invalid time in due date %q", raw)
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	if len(fields) == 0 {
+		// Only a time was supplied, take the next occurrence of it
+		due := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		return due, nil
+	}
+
+	day := fields[0]
+	today := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if day == "today" {
+		return today, nil
+	}
+	if day == "tomorrow" || day == "tmrw" {
+		return today.AddDate(0, 0, 1), nil
+	}
+	if weekday, found := weekdays[day]; found {
+		// Take the next occurrence of the weekday, which may be today if it didn't pass yet
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		due := today.AddDate(0, 0, days)
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 7)
+		}
+		return due, nil
+	}
+
+	if match := isoDateRegex.FindStringSubmatch(day); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		dayOfMonth, _ := strconv.Atoi(match[3])
+		return validDate(raw, year, month, dayOfMonth, hour, minute, now.Location())
+	}
+
+	if match := dateRegex.FindStringSubmatch(day); match != nil {
+		dayOfMonth, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		if match[3] != "" {
+			year, _ := strconv.Atoi(match[3])
+			return validDate(raw, year, month, dayOfMonth, hour, minute, now.Location())
+		}
+		// Take the next occurrence of the date if no year was given
+		due, err := validDate(raw, now.Year(), month, dayOfMonth, hour, minute, now.Location())
+		if err == nil && !due.After(now) {
+			due, err = validDate(raw, now.Year()+1, month, dayOfMonth, hour, minute, now.Location())
+		}
+		return due, err
+	}
+
+	return time.Time{}, fmt.Errorf("invalid due date %q", raw)
+}
+
+// Returns the date if it exists, e.g. not for the 31.2.
+func validDate(raw string, year, month, day, hour, minute int, loc *time.Location) (time.Time, error) {
+	date := time.Date(year, time.Month(month), day, hour, minute, 0, 0, loc)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date in due date %q", raw)
+	}
+	return date, nil
+}
+
+// Parses durations such as 30m, 2h, 1d or 1w
+func parseLeadTime(raw string) (time.Duration, error) {
+	match := durationRegex.FindStringSubmatch(strings.ToLower(raw))
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+	amount, _ := strconv.Atoi(match[1])
+	unit := map[string]time.Duration{
+		"m":   time.Minute,
+		"min": time.Minute,
+		"h":   time.Hour,
+		"d":   24 * time.Hour,
+		"w":   7 * 24 * time.Hour,
+	}[match[2]]
+
+	return time.Duration(amount) * unit, nil
+}
+
+// Formats a duration in the format understood by parseLeadTime, using the largest fitting unit
+func formatLeadTime(duration time.Duration) string {
+	units := []struct {
+		suffix string
+		length time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+	}
+	for _, unit := range units {
+		if duration >= unit.length && duration%unit.length == 0 {
+			return fmt.Sprint(int64(duration/unit.length), unit.suffix)
+		}
+	}
+	return fmt.Sprint(int64(duration/time.Minute), "m")
+}
+
+// Splits the arguments of todo add into title and due date, which is introduced by the last "due"
+// If what follows doesn't parse as a due date, everything is treated as the title
+func splitTitleAndDue(args []string, now time.Time) (string, *time.Time) {
+	for i := len(args) - 1; i > 0; i-- {
+		if strings.ToLower(args[i]) != "due" {
+			continue
+		}
+		due, err := parseDue(strings.Join(args[i+1:], " "), now)
+		if err != nil {
+			break
+		}
+		return strings.Join(args[:i], " "), &due
+	}
+	return strings.Join(args, " "), nil
+}
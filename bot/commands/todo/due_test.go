@@ -0,0 +1,139 @@
+package todo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Wednesday, 1st of June 2022, 12:00
+var testNow = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func date(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2022, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseDue(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput time.Time
+		expectedError  error
+	}{
+		{"today", date(6, 1, 23, 59), nil},
+		{"today 18:00", date(6, 1, 18, 0), nil},
+		{"tomorrow", date(6, 2, 23, 59), nil},
+		{"Tomorrow 18:00", date(6, 2, 18, 0), nil},
+		{"tmrw 8h", date(6, 2, 8, 0), nil},
+		{"fri", date(6, 3, 23, 59), nil},
+		{"friday 10:30", date(6, 3, 10, 30), nil},
+		{"wed", date(6, 1, 23, 59), nil},
+		{"wed 11:00", date(6, 8, 11, 0), nil},
+		{"mon", date(6, 6, 23, 59), nil},
+		{"18:00", date(6, 1, 18, 0), nil},
+		{"11:00", date(6, 2, 11, 0), nil},
+		{"in 3d", date(6, 4, 12, 0), nil},
+		{"2h", date(6, 1, 14, 0), nil},
+		{"1w", date(6, 8, 12, 0), nil},
+		{"2022-06-24", date(6, 24, 23, 59), nil},
+		{"24.6.", date(6, 24, 23, 59), nil},
+		{"24.6.2022 9:15", date(6, 24, 9, 15), nil},
+		{"1.1.", time.Date(2023, 1, 1, 23, 59, 0, 0, time.UTC), nil},
+		// The dot after the month is optional without a year
+		{"24.6", date(6, 24, 23, 59), nil},
+		{"24.6 9:15", date(6, 24, 9, 15), nil},
+
+		{"", time.Time{}, fmt.Errorf(`invalid due date ""`)},
+		{"someday", time.Time{}, fmt.Errorf(`invalid due date "someday"`)},
+		{"fri 25:00", time.Time{}, fmt.Errorf(`invalid time in due date "fri 25:00"`)},
+		{"31.2.", time.Time{}, fmt.Errorf(`invalid date in due date "31.2."`)},
+		{"24.62022", time.Time{}, fmt.Errorf(`invalid due date "24.62022"`)},
+		{"next fri 18:00", time.Time{}, fmt.Errorf(`invalid due date "next fri 18:00"`)},
+	}
+
+	for _, test := range tests {
+		result, err := parseDue(test.input, testNow)
+		assert.Equal(t, test.expectedOutput, result, test.input)
+		assert.Equal(t, test.expectedError, err, test.input)
+	}
+}
+
+func TestParseModalDueFormat(t *testing.T) {
+	due := date(12, 24, 9, 5)
+	result, err := parseDue(due.Format(modalDueFormat), testNow)
+	assert.Equal(t, due, result)
+	assert.Nil(t, err)
+}
+
+func TestParseDueInLocation(t *testing.T) {
+	// Due dates are entered in the timezone of the user
+	newYork, _ := time.LoadLocation("America/New_York")
+	result, err := parseDue("fri 18:00", time.Date(2022, 10, 12, 12, 0, 0, 0, newYork))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, 10, 14, 18, 0, 0, 0, newYork), result)
+	assert.Equal(t, time.Date(2022, 10, 14, 22, 0, 0, 0, time.UTC), result.UTC())
+}
+
+func TestParseLeadTime(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput time.Duration
+		expectedError  error
+	}{
+		{"30m", 30 * time.Minute, nil},
+		{"30min", 30 * time.Minute, nil},
+		{"2h", 2 * time.Hour, nil},
+		{"1d", 24 * time.Hour, nil},
+		{"1W", 7 * 24 * time.Hour, nil},
+
+		{"1", 0, fmt.Errorf(`invalid duration "1"`)},
+		{"h", 0, fmt.Errorf(`invalid duration "h"`)},
+		{"1y", 0, fmt.Errorf(`invalid duration "1y"`)},
+	}
+
+	for _, test := range tests {
+		result, err := parseLeadTime(test.input)
+		assert.Equal(t, test.expectedOutput, result, test.input)
+		assert.Equal(t, test.expectedError, err, test.input)
+	}
+}
+
+func TestFormatLeadTime(t *testing.T) {
+	tests := []struct {
+		input          time.Duration
+		expectedOutput string
+	}{
+		{30 * time.Minute, "30m"},
+		{90 * time.Minute, "90m"},
+		{2 * time.Hour, "2h"},
+		{48 * time.Hour, "2d"},
+		{14 * 24 * time.Hour, "2w"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, formatLeadTime(test.input))
+	}
+}
+
+func TestSplitTitleAndDue(t *testing.T) {
+	due := date(6, 3, 18, 0)
+	tests := []struct {
+		input         []string
+		expectedTitle string
+		expectedDue   *time.Time
+	}{
+		{[]string{"Sheet", "3"}, "Sheet 3", nil},
+		{[]string{"Sheet", "3", "due", "fri", "18:00"}, "Sheet 3", &due},
+		{[]string{"Pay", "due", "fees", "due", "fri", "18:00"}, "Pay due fees", &due},
+		{[]string{"Pay", "dues"}, "Pay dues", nil},
+		{[]string{"Pay", "due", "fees"}, "Pay due fees", nil},
+		{[]string{"due", "fri"}, "due fri", nil},
+	}
+
+	for _, test := range tests {
+		title, due := splitTitleAndDue(test.input, testNow)
+		assert.Equal(t, test.expectedTitle, title)
+		assert.Equal(t, test.expectedDue, due)
+	}
+}
@@ -0,0 +1,390 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+// Maximum amount of items which can be edited at once, as every item gets its own button
+const maxEditButtons = 25
+
+func (s Todo) editHelp() string {
+	return "Usage: `todo edit [id[,id..]]`\nAlternatively, call `todo edit` with no arguments to select the items to edit without having to supply IDs."
+}
+
+func (s Todo) Edit(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 { // Send message to select the items to edit
+		items, err := s.getAllTodos(ctx.Author.ID)
+		if err != nil {
+			return err
+		} else if len(items) == 0 {
+			msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, "You have no TODO items.", ctx.Reference())
+
+			time.Sleep(messageDeleteDelay)
+
+			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+			bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+			return nil
+		}
+		return s.sendItemSelectMessage(
+			bot,
+			ctx,
+			itemsToOptions(items),
+			ctx.Author.Mention()+", please mark which items you want to edit.",
+			"Items to edit",
+			"edit",
+		)
+	} else if len(args) == 1 && args[0] == "help" { // Send help message
+		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+		bot.ChannelMessageSend(ctx.ChannelID, s.editHelp())
+	} else { // Parse rest as IDs and send the edit buttons for them
+		ids, err := parseIds(args)
+		if err != nil {
+			msg, _ := bot.ChannelMessageSend(ctx.ChannelID, "Error parsing IDs.\n"+s.editHelp())
+			time.Sleep(messageDeleteDelay)
+			bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+			return nil
+		}
+		items, err := s.getOwnItems(ctx.Author.ID, ids)
+		if err != nil {
+			switch err.(type) {
+			case *InvalidIDError:
+				msg, _ := bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("You supplied an invalid ID: %v", err))
+				time.Sleep(messageDeleteDelay)
+				bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+				bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+				return nil
+			default:
+				return err
+			}
+		}
+		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+		bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+			Content:    ctx.Author.Mention() + ", press the buttons to edit your items.",
+			Components: editButtons(ctx.Author.ID, items),
+		})
+	}
+	return nil
+}
+
+// Select action replacing the select message with buttons to edit the selected items
+func (s Todo) editSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	if len(items) == 0 {
+		finishSelectMessage(bot, msg, "Didn't select any items to edit.")
+		return nil
+	}
+
+	selected, err := s.getOwnItems(user.ID, items)
+	if err != nil {
+		return err
+	}
+
+	content := user.Mention() + ", press the buttons to edit your items."
+	components := editButtons(user.ID, selected)
+	_, err = bot.ChannelMessageEditComplex(&discord.MessageEdit{
+		Content:    &content,
+		Components: components,
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+	})
+	return err
+}
+
+// Returns rows of buttons which open the edit modal of an item each, with the owner encoded in their CustomIDs
+func editButtons(owner string, items []todoItem) []discord.MessageComponent {
+	if len(items) > maxEditButtons {
+		items = items[:maxEditButtons]
+	}
+
+	rows := []discord.MessageComponent{}
+	row := discord.ActionsRow{}
+	for i, item := range items {
+		label := fmt.Sprint(item.ID, ": ", item.Title)
+		if len(label) > 80 {
+			label = label[:77] + "..."
+		}
+		row.Components = append(row.Components, discord.Button{
+			Label:    label,
+			Style:    discord.PrimaryButton,
+			CustomID: fmt.Sprint(editButtonPrefix, owner, ":", item.ID),
+		})
+		// Discord allows at most five buttons per row
+		if len(row.Components) == 5 || i == len(items)-1 {
+			rows = append(rows, row)
+			row = discord.ActionsRow{}
+		}
+	}
+	return rows
+}
+
+// Callback for the edit buttons, opens the edit modal pre-filled with the current values of the item
+func (s Todo) handleEditButton(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	split := strings.Split(strings.TrimPrefix(interaction.MessageComponentData().CustomID, editButtonPrefix), ":")
+	if len(split) != 2 {
+		return fmt.Errorf("invalid edit button ID %s", interaction.MessageComponentData().CustomID)
+	}
+	owner, taskId := split[0], split[1]
+
+	user := interactionUser(interaction)
+	if user.ID != owner {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: "You can only edit your own items.",
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		})
+	}
+
+	items, err := s.getOwnItems(user.ID, []string{taskId})
+	if err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			return constants.RespondExpired(bot, interaction)
+		default:
+			return err
+		}
+	}
+
+	// The due date is shown the way the user entered it, in their timezone
+	location, err := s.getLocation(user.ID)
+	if err != nil {
+		return err
+	}
+	if items[0].Due != nil {
+		due := items[0].Due.In(location)
+		items[0].Due = &due
+	}
+
+	itemModalCreate(bot, interaction, editModalPrefix+taskId, "Edit TODO item", items[0])
+	return nil
+}
+
+// Callback for the edit modal, saves the changes to the item
+func (s Todo) handleEditModal(bot *discord.Session, interaction *discord.Interaction) error {
+	now, err := s.userNow(interactionUser(interaction).ID)
+	if err != nil {
+		return err
+	}
+	item, ok := parseItemModal(bot, interaction, now)
+	if !ok {
+		return nil
+	}
+
+	taskId, err := strconv.Atoi(strings.TrimPrefix(interaction.ModalSubmitData().CustomID, editModalPrefix))
+	if err != nil {
+		return err
+	}
+	item.ID = taskId
+
+	user := interactionUser(interaction)
+	_, operation, err := s.editItem(user.ID, item)
+	if err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+				Type: discord.InteractionResponseChannelMessageWithSource,
+				Data: &discord.InteractionResponseData{
+					Content: "This item doesn't exist anymore, your changes were not saved.",
+					Flags:   uint64(discord.MessageFlagsEphemeral),
+				},
+			})
+		default:
+			return err
+		}
+	}
+
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Content:    "Successfully edited " + item.Title + ".",
+			Components: undoComponents(user.ID, operation),
+			Flags:      uint64(discord.MessageFlagsEphemeral),
+		},
+	})
+}
+
+// Returns the items with the IDs, which have to be in any of the users lists
+// Returns an InvalidIDError if invalid IDs were supplied
+func (s Todo) getOwnItems(userId string, ids []string) ([]todoItem, error) {
+	userItems, err := s.getAllTodos(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []todoItem{}
+	invalid := []string{}
+	for _, id := range ids {
+		found := false
+		for _, item := range userItems {
+			if fmt.Sprint(item.ID) == id {
+				items = append(items, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			invalid = append(invalid, id)
+		}
+	}
+
+	if len(invalid) != 0 {
+		return nil, &InvalidIDError{invalid}
+	}
+	return items, nil
+}
+
+// Changes title, description, due date, tags and priority of the users item with the ID item.ID
+// Returns the ID of the edited task and the ID of the journaled operation, which is 0 if no values changed
+// Tasks the user didn't create themselves, e.g. the ones created for subscriptions, are copied together with their subtasks
+// before being edited, so the changes only affect the user
+// Returns an InvalidIDError if the item isn't in any of the users lists
+func (s Todo) editItem(userId string, item todoItem) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	previous, taskId, err := editTask(ctx, tx, userId, item)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, 0, err1
+		}
+		return 0, 0, err
+	}
+
+	// Copies which don't change any values, e.g. the ones made before changing subtasks, aren't journaled
+	operation := 0
+	if !sameValues(previous, item) {
+		if operation, err = recordEdit(tx, userId, taskId, previous); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, 0, err1
+			}
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	log.Printf("%s Edited users %s item %d, now task %d\n", constants.Blue, userId, item.ID, taskId)
+	notifyBoards(userId)
+
+	return taskId, operation, s.scheduleReminders(userId)
+}
+
+// Edits the item like editItem within the transaction, returns the task as it was before the edit and the ID of the edited task
+func editTask(ctx context.Context, tx *sql.Tx, userId string, item todoItem) (todoItem, int, error) {
+	// Find the list of the item, its creator and its current values
+	var table string
+	previous := todoItem{ID: item.ID}
+	err := tx.QueryRowContext(ctx, `SELECT l.list, t.creator, t.title, t.description, t.due, t.tags, t.priority, COALESCE(t.subscription, '') FROM todo.task AS t
+		JOIN `+userListsQuery+` AS l ON l.task=t.id
+		WHERE l.discord_user=$1 AND t.id=$2`,
+		userId,
+		item.ID,
+	).Scan(&table, &previous.Creator, &previous.Title, &previous.Description, &previous.Due, pq.Array(&previous.Tags), &previous.Priority, &previous.Subscription)
+	if err == sql.ErrNoRows {
+		return todoItem{}, 0, &InvalidIDError{[]string{fmt.Sprint(item.ID)}}
+	} else if err != nil {
+		return todoItem{}, 0, err
+	}
+
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	if previous.Creator == userId {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE todo.task SET title=$2, description=$3, due=$4, tags=$5, priority=$6 WHERE id=$1`,
+			item.ID,
+			item.Title,
+			item.Description,
+			item.Due,
+			pq.Array(tags),
+			item.Priority,
+		)
+		return previous, item.ID, err
+	}
+
+	// Copy on write, so other users of the task aren't affected
+	// The copy still belongs to the subscription of the original, for the stats of the user
+	item.Subscription = previous.Subscription
+	taskId, err := createTask(ctx, tx, userId, item)
+	if err != nil {
+		return todoItem{}, 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE todo.%s SET task=$3 WHERE discord_user=$1 AND task=$2`, table),
+		userId,
+		item.ID,
+		taskId,
+	); err != nil {
+		return todoItem{}, 0, err
+	}
+	// The copy keeps the subtasks and the ones the user checked off
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO todo.subtask (task, position, title) SELECT $2, position, title FROM todo.subtask WHERE task=$1`,
+		item.ID,
+		taskId,
+	); err != nil {
+		return todoItem{}, 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE todo.completed_subtask SET task=$3 WHERE discord_user=$1 AND task=$2`,
+		userId,
+		item.ID,
+		taskId,
+	); err != nil {
+		return todoItem{}, 0, err
+	}
+	// Undoing earlier operations moves the copy and unchecks its subtasks, not the ones of the original task
+	for _, query := range []string{
+		`UPDATE todo.operation_change AS c SET task=$3 FROM todo.operation AS o WHERE o.id=c.operation AND o.discord_user=$1 AND c.task=$2`,
+		`UPDATE todo.operation_subtask AS c SET task=$3 FROM todo.operation AS o WHERE o.id=c.operation AND o.discord_user=$1 AND c.task=$2`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userId, item.ID, taskId); err != nil {
+			return todoItem{}, 0, err
+		}
+	}
+	return previous, taskId, nil
+}
+
+// Returns whether the items have the same title, description, due date, tags and priority
+func sameValues(a, b todoItem) bool {
+	if (a.Due == nil) != (b.Due == nil) || (a.Due != nil && !a.Due.Equal(*b.Due)) {
+		return false
+	}
+	if len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return a.Title == b.Title && a.Description == b.Description && a.Priority == b.Priority
+}
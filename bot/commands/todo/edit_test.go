@@ -0,0 +1,131 @@
+package todo
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectScheduleReminders(userId string) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`DELETE FROM todo.reminder`).
+		WithArgs(userId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec(`INSERT INTO todo.reminder`).
+		WithArgs(userId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectCommit()
+}
+
+// Columns of the query editItem uses to find the list and the current values of the item
+var editColumns = []string{"list", "creator", "title", "description", "due", "tags", "priority", "subscription"}
+
+func TestEditItemOwnTask(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`SELECT l.list, t.creator, (.+) FROM todo.task`).
+		WithArgs("0", 1).
+		WillReturnRows(sqlmock.NewRows(editColumns).AddRow("active", "0", "old", "d", nil, "{}", priorityNone, ""))
+	dbMock.ExpectExec(`UPDATE todo.task`).
+		WithArgs(1, "t", "d", &testNow, pq.Array([]string{"exam"}), priorityLow).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The previous values are journaled, so the edit can be undone
+	dbMock.ExpectQuery(`INSERT INTO todo.operation \(discord_user, action\) VALUES \(\$1, 'edit'\)`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	dbMock.ExpectExec(`INSERT INTO todo.operation_edit`).
+		WithArgs(5, 1, 1, "old", "d", nil, pq.Array([]string{}), priorityNone).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	taskId, operation, err := mockTodo.editItem("0", todoItem{ID: 1, Title: "t", Description: "d", Due: &testNow, Tags: []string{"exam"}, Priority: priorityLow})
+
+	assert.Equal(t, 1, taskId)
+	assert.Equal(t, 5, operation)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestEditItemForeignTask(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`SELECT l.list, t.creator, (.+) FROM todo.task`).
+		WithArgs("0", 1).
+		WillReturnRows(sqlmock.NewRows(editColumns).AddRow("completed", "1", "t", "d", nil, "{}", priorityNone, "401-0212-16L"))
+	// The copy keeps the subscription of the original
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WithArgs("0", "t", "d", nil, pq.Array([]string{}), priorityNone, "401-0212-16L").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	dbMock.ExpectExec(`UPDATE todo.completed SET task`).
+		WithArgs("0", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO todo.subtask (.+) SELECT \$2, position, title FROM todo.subtask WHERE task=\$1`).
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec(`UPDATE todo.completed_subtask SET task=\$3`).
+		WithArgs("0", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`UPDATE todo.operation_change AS c SET task=\$3`).
+		WithArgs("0", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectExec(`UPDATE todo.operation_subtask AS c SET task=\$3`).
+		WithArgs("0", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Copies without changed values aren't journaled
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	taskId, operation, err := mockTodo.editItem("0", todoItem{ID: 1, Title: "t", Description: "d"})
+
+	assert.Equal(t, 2, taskId)
+	assert.Equal(t, 0, operation)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestEditItemForeignTaskRollback(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`SELECT l.list, t.creator, (.+) FROM todo.task`).
+		WithArgs("0", 1).
+		WillReturnRows(sqlmock.NewRows(editColumns).AddRow("active", "1", "old", "d", nil, "{}", priorityNone, ""))
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WithArgs("0", "t", "d", nil, pq.Array([]string{}), priorityNone, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	dbMock.ExpectExec(`UPDATE todo.active SET task`).
+		WithArgs("0", 1, 2).
+		WillReturnError(sql.ErrConnDone)
+	// The copy gets discarded together with the rest of the changes
+	dbMock.ExpectRollback()
+
+	_, _, err := mockTodo.editItem("0", todoItem{ID: 1, Title: "t", Description: "d"})
+
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestEditItemWrongID(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`SELECT l.list, t.creator, (.+) FROM todo.task`).
+		WithArgs("0", 1).
+		WillReturnRows(sqlmock.NewRows(editColumns))
+	dbMock.ExpectRollback()
+
+	_, _, err := mockTodo.editItem("0", todoItem{ID: 1, Title: "t"})
+
+	assert.IsType(t, &InvalidIDError{}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSameValues(t *testing.T) {
+	later := testNow.Add(time.Hour)
+	item := todoItem{Title: "t", Description: "d", Due: &testNow, Tags: []string{"exam"}, Priority: priorityLow}
+
+	assert.True(t, sameValues(item, item))
+	assert.True(t, sameValues(todoItem{Tags: []string{}}, todoItem{}))
+	assert.False(t, sameValues(item, todoItem{Title: "t", Description: "d", Due: &later, Tags: []string{"exam"}, Priority: priorityLow}))
+	assert.False(t, sameValues(item, todoItem{Title: "t", Description: "d", Tags: []string{"exam"}, Priority: priorityLow}))
+	assert.False(t, sameValues(item, todoItem{Title: "t", Description: "d", Due: &testNow, Tags: []string{"lab"}, Priority: priorityLow}))
+}
@@ -0,0 +1,108 @@
+package todo
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/commands/todo/exporter"
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+func (s Todo) exportHelp() string {
+	return "Usage: `todo export [" + strings.Join(exporter.Formats(), "|") + "] [active|all]`\nSends your active or all items as a file, e.g. `todo export ics` to import the items with a due date into your calendar app."
+}
+
+func (s Todo) Export(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) > 2 || args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.exportHelp())
+		return nil
+	}
+
+	format, ok := exporter.Get(strings.ToLower(strings.TrimPrefix(args[0], ".")))
+	if !ok {
+		bot.ChannelMessageSend(ctx.ChannelID, "Unknown format `"+args[0]+"`.\n"+s.exportHelp())
+		return nil
+	}
+	lists := []string{"active"}
+	if len(args) == 2 {
+		switch strings.ToLower(args[1]) {
+		case "active":
+		case "all":
+			lists = []string{"active", "completed", "archived"}
+		default:
+			bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.exportHelp())
+			return nil
+		}
+	}
+
+	items, err := s.getExportItems(ctx.Author.ID, lists)
+	if err != nil {
+		return err
+	}
+
+	file := bytes.Buffer{}
+	if err := format.Export(&file, items, time.Now()); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("%s, here are your %d TODO items.", ctx.Author.Mention(), len(items))
+	if _, ok := format.(exporter.ICS); ok {
+		content += "\nItems without a due date aren't part of calendar files."
+	}
+	if _, err := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Content: content,
+		Files: []*discord.File{
+			{
+				Name:        "todos." + format.Extension(),
+				ContentType: format.ContentType(),
+				Reader:      &file,
+			},
+		},
+		Reference: ctx.Reference(),
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("%s Exported users %s items; Lists: %v, Format: %s\n", constants.Blue, ctx.Author.ID, lists, format.Extension())
+	return nil
+}
+
+// Returns the items of the user in the lists for exporting, with their due dates in local time
+func (s Todo) getExportItems(userId string, lists []string) ([]exporter.Item, error) {
+	items := []exporter.Item{}
+	for _, list := range lists {
+		todos, err := s.getUserTODOs(userId, listsFilter(list))
+		if err != nil {
+			return nil, err
+		}
+		for _, todo := range todos {
+			items = append(items, exportItem(todo, list))
+		}
+	}
+	return items, nil
+}
+
+// Turns an item of a list into the item as it gets exported
+func exportItem(item todoItem, list string) exporter.Item {
+	exported := exporter.Item{
+		ID:          item.ID,
+		Title:       item.Title,
+		Description: item.Description,
+		Tags:        item.Tags,
+		Priority:    strings.ToLower(priorityLabels[item.Priority]),
+		List:        list,
+	}
+	if item.Due != nil {
+		due := item.Due.Local()
+		exported.Due = &due
+	}
+	return exported
+}
@@ -0,0 +1,53 @@
+package todo
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/bot/commands/todo/exporter"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportItem(t *testing.T) {
+	local := testNow.Local()
+	tests := []struct {
+		item           todoItem
+		list           string
+		expectedOutput exporter.Item
+	}{
+		{
+			todoItem{ID: 1, Title: "t", Description: "d", Tags: []string{"exam"}, Priority: priorityHigh},
+			"active",
+			exporter.Item{ID: 1, Title: "t", Description: "d", Tags: []string{"exam"}, Priority: "high", List: "active"},
+		},
+		{
+			todoItem{ID: 2, Title: "t", Due: &testNow, Tags: []string{}},
+			"archived",
+			exporter.Item{ID: 2, Title: "t", Due: &local, Tags: []string{}, List: "archived"},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, exportItem(test.item, test.list))
+	}
+}
+
+func TestGetExportItems(t *testing.T) {
+	columns := []string{"id", "creator", "title", "description", "due", "tags", "priority", "subtasks", "subtasks_done"}
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN (.+) WHERE l.discord_user=\$1 AND l.list=ANY\(\$2\)`).
+		WithArgs("0", pq.Array([]string{"active"})).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "0", "a", "", nil, "{}", priorityNone, 0, 0))
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN (.+) WHERE l.discord_user=\$1 AND l.list=ANY\(\$2\)`).
+		WithArgs("0", pq.Array([]string{"completed"})).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "0", "c", "", nil, "{}", priorityLow, 0, 0))
+
+	items, err := mockTodo.getExportItems("0", []string{"active", "completed"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []exporter.Item{
+		{ID: 1, Title: "a", Tags: []string{}, List: "active"},
+		{ID: 2, Title: "c", Tags: []string{}, Priority: "low", List: "completed"},
+	}, items)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,45 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Exports items as comma separated values with a header, tags are separated by spaces
+type CSV struct{}
+
+// Header of the exported CSV files
+var csvHeader = []string{"id", "title", "description", "due", "tags", "priority", "list"}
+
+func (CSV) Extension() string { return "csv" }
+
+func (CSV) ContentType() string { return "text/csv" }
+
+func (CSV) Export(w io.Writer, items []Item, now time.Time) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, item := range items {
+		due := ""
+		if item.Due != nil {
+			due = item.Due.Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			fmt.Sprint(item.ID),
+			item.Title,
+			item.Description,
+			due,
+			strings.Join(item.Tags, " "),
+			item.Priority,
+			item.List,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
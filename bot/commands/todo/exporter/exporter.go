@@ -0,0 +1,53 @@
+// Package exporter formats TODO items as files, independently of how they get sent to the user
+package exporter
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// A TODO item as it gets exported
+type Item struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Due         *time.Time `json:"due"` // nil if the item has no due date, formatted in its location
+	Tags        []string   `json:"tags"`
+	Priority    string     `json:"priority"` // low, medium or high, empty if the item has no priority
+	List        string     `json:"list"`     // active, completed or archived
+}
+
+// Format items can be exported to
+type Exporter interface {
+	// File extension of the format, without a leading dot
+	Extension() string
+	// MIME type of the format
+	ContentType() string
+	// Writes the items to w, now is the time of the export
+	Export(w io.Writer, items []Item, now time.Time) error
+}
+
+// Exporters by the name of their format
+var exporters = map[string]Exporter{
+	"csv":  CSV{},
+	"ics":  ICS{},
+	"json": JSON{},
+	"md":   Markdown{},
+}
+
+// Returns the exporter of the format, ok is false if there is none
+func Get(format string) (exporter Exporter, ok bool) {
+	exporter, ok = exporters[format]
+	return exporter, ok
+}
+
+// Returns the names of all formats, sorted alphabetically
+func Formats() []string {
+	formats := []string{}
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
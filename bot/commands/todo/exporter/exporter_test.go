@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Run the tests with -update to rewrite the golden files with the current output
+var update = flag.Bool("update", false, "update the golden files")
+
+var (
+	testNow = time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	testDue = time.Date(2022, 10, 16, 18, 30, 0, 0, time.UTC)
+)
+
+var testItems = []Item{
+	{
+		ID:          1,
+		Title:       "Exercise 1",
+		Description: "Hand in on Moodle, then check the solutions; both parts",
+		Due:         &testDue,
+		Tags:        []string{"ana", "exam"},
+		Priority:    "high",
+		List:        "active",
+	},
+	{
+		ID:    2,
+		Title: "Read script, chapter 2",
+		List:  "active",
+	},
+	{
+		ID:          3,
+		Title:       "Lecture recording",
+		Description: "Watched\non Wednesday, with a description long enough to need folding in iCalendar files",
+		Due:         &testDue,
+		Tags:        []string{},
+		Priority:    "low",
+		List:        "completed",
+	},
+	{
+		ID:    4,
+		Title: "Old exam 2019",
+		Tags:  []string{"exam"},
+		List:  "archived",
+	},
+}
+
+func TestExporters(t *testing.T) {
+	for _, format := range Formats() {
+		exporter, ok := Get(format)
+		assert.True(t, ok)
+
+		buffer := bytes.Buffer{}
+		assert.Nil(t, exporter.Export(&buffer, testItems, testNow), format)
+
+		golden := filepath.Join("testdata", "export."+exporter.Extension()+".golden")
+		if *update {
+			assert.Nil(t, os.WriteFile(golden, buffer.Bytes(), 0644))
+		}
+		expected, err := os.ReadFile(golden)
+		assert.Nil(t, err, format)
+		assert.Equal(t, string(expected), buffer.String(), format)
+	}
+}
+
+func TestExportersEmpty(t *testing.T) {
+	for _, format := range Formats() {
+		exporter, _ := Get(format)
+		assert.Nil(t, exporter.Export(&bytes.Buffer{}, []Item{}, testNow), format)
+	}
+}
+
+func TestGet(t *testing.T) {
+	_, ok := Get("pdf")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"csv", "ics", "json", "md"}, Formats())
+}
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{"SUMMARY:short", "SUMMARY:short"},
+		{strings.Repeat("a", 75), strings.Repeat("a", 75)},
+		{strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a"},
+		{strings.Repeat("a", 150), strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a"},
+		// Multi-byte characters are moved to the next line as a whole
+		{strings.Repeat("a", 74) + "ä", strings.Repeat("a", 74) + "\r\n ä"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, foldICSLine(test.input))
+	}
+}
+
+func TestReadICS(t *testing.T) {
+	buffer := bytes.Buffer{}
+	assert.Nil(t, ICS{}.Export(&buffer, testItems, testNow))
+
+	// Reading an export unfolds its lines and unescapes the texts
+	descriptions := []string{}
+	for _, property := range ReadICS(buffer.Bytes()) {
+		if property.Name == "DESCRIPTION" {
+			descriptions = append(descriptions, property.Text())
+		}
+	}
+	assert.Equal(t, []string{testItems[0].Description, testItems[0].Description, testItems[2].Description, testItems[2].Description}, descriptions)
+
+	properties := ReadICS([]byte("dtstart;tzid=\"Europe/Zurich\":20221014T090000\r\nno value\r\nDUE;VALUE=DATE:20221014\r\n"))
+	assert.Equal(t, []ICSProperty{
+		{Name: "DTSTART", Params: map[string]string{"TZID": "Europe/Zurich"}, Value: "20221014T090000"},
+		{Name: "DUE", Params: map[string]string{"VALUE": "DATE"}, Value: "20221014"},
+	}, properties)
+}
+
+func TestICSPropertyTime(t *testing.T) {
+	tests := []struct {
+		property       ICSProperty
+		expectedOutput time.Time
+		expectDate     bool
+		expectError    bool
+	}{
+		{ICSProperty{Value: "20221014T090000Z"}, time.Date(2022, 10, 14, 9, 0, 0, 0, time.UTC), false, false},
+		{ICSProperty{Value: "20221014T090000"}, time.Date(2022, 10, 14, 9, 0, 0, 0, time.UTC), false, false},
+		{ICSProperty{Params: map[string]string{"TZID": "Europe/Zurich"}, Value: "20221014T090000"}, time.Date(2022, 10, 14, 7, 0, 0, 0, time.UTC), false, false},
+		// Unknown time zones fall back to the location
+		{ICSProperty{Params: map[string]string{"TZID": "Middle/Earth"}, Value: "20221014T090000"}, time.Date(2022, 10, 14, 9, 0, 0, 0, time.UTC), false, false},
+		{ICSProperty{Params: map[string]string{"TZID": "Europe/Zurich", "VALUE": "DATE"}, Value: "20221014"}, time.Date(2022, 10, 14, 0, 0, 0, 0, time.UTC), true, false},
+		{ICSProperty{Value: "20221014"}, time.Date(2022, 10, 14, 0, 0, 0, 0, time.UTC), true, false},
+		{ICSProperty{Value: "tomorrow"}, time.Time{}, false, true},
+	}
+
+	for _, test := range tests {
+		output, date, err := test.property.Time(time.UTC)
+		if test.expectError {
+			assert.NotNil(t, err, test.property.Value)
+			continue
+		}
+		assert.Nil(t, err, test.property.Value)
+		assert.Equal(t, test.expectedOutput, output, test.property.Value)
+		assert.Equal(t, test.expectDate, date, test.property.Value)
+	}
+}
@@ -0,0 +1,185 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Exports items with a due date as iCalendar, each as a VTODO for task apps and a VEVENT for calendar apps
+// Items without a due date are left out, as calendar apps can't place them
+type ICS struct{}
+
+const (
+	icsTimeFormat = "20060102T150405Z"
+	icsLineLength = 75 // Maximum length of a line in octets, longer ones get folded
+)
+
+// Status of the VTODO entries by the list of the item
+var icsStatus = map[string]string{
+	"active":    "NEEDS-ACTION",
+	"completed": "COMPLETED",
+	"archived":  "CANCELLED",
+}
+
+// Priorities of iCalendar by the priority of the item, 1 is the highest and 9 the lowest
+var icsPriority = map[string]int{
+	"high":   1,
+	"medium": 5,
+	"low":    9,
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func (ICS) Extension() string { return "ics" }
+
+func (ICS) ContentType() string { return "text/calendar" }
+
+func (ICS) Export(w io.Writer, items []Item, now time.Time) error {
+	stamp := now.UTC().Format(icsTimeFormat)
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Alphie//TODO Export//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	for _, item := range items {
+		if item.Due == nil {
+			continue
+		}
+		due := item.Due.UTC().Format(icsTimeFormat)
+		properties := []string{
+			"DTSTAMP:" + stamp,
+			"SUMMARY:" + icsEscaper.Replace(item.Title),
+		}
+		if item.Description != "" {
+			properties = append(properties, "DESCRIPTION:"+icsEscaper.Replace(item.Description))
+		}
+		if len(item.Tags) != 0 {
+			tags := []string{}
+			for _, tag := range item.Tags {
+				tags = append(tags, icsEscaper.Replace(tag))
+			}
+			properties = append(properties, "CATEGORIES:"+strings.Join(tags, ","))
+		}
+		if priority, ok := icsPriority[item.Priority]; ok {
+			properties = append(properties, fmt.Sprint("PRIORITY:", priority))
+		}
+
+		lines = append(lines, "BEGIN:VTODO", fmt.Sprintf("UID:todo-%d@alphie", item.ID))
+		lines = append(lines, properties...)
+		lines = append(lines, "DUE:"+due)
+		if status, ok := icsStatus[item.List]; ok {
+			lines = append(lines, "STATUS:"+status)
+		}
+		lines = append(lines, "END:VTODO")
+
+		lines = append(lines, "BEGIN:VEVENT", fmt.Sprintf("UID:todo-%d-event@alphie", item.ID))
+		lines = append(lines, properties...)
+		lines = append(lines, "DTSTART:"+due, "DTEND:"+due, "TRANSP:TRANSPARENT", "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldICSLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Folds a line longer than icsLineLength octets into multiple ones, continuation lines start with a space
+// Multi-byte characters are never split
+func foldICSLine(line string) string {
+	folded := strings.Builder{}
+	length := 0
+	for _, char := range line {
+		size := utf8.RuneLen(char)
+		if length+size > icsLineLength {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(char)
+		length += size
+	}
+	return folded.String()
+}
+
+const (
+	icsDateTimeFormat = "20060102T150405"
+	icsDateFormat     = "20060102"
+)
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// A property of an iCalendar file, such as DTSTART;TZID=Europe/Zurich:20230920T101500
+type ICSProperty struct {
+	Name   string            // Upper-cased name of the property
+	Params map[string]string // Values of the parameters by their upper-cased names, without quotes
+	Value  string            // Raw value, see Text and Time to parse it
+}
+
+// Reads the properties of an iCalendar file, continuation lines start with a space or tab
+// Lines without a value are skipped
+func ReadICS(data []byte) []ICSProperty {
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if len(lines) != 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	properties := []ICSProperty{}
+	for _, line := range lines {
+		nameAndParams, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		params := strings.Split(nameAndParams, ";")
+		property := ICSProperty{Name: strings.ToUpper(params[0]), Params: map[string]string{}, Value: value}
+		for _, param := range params[1:] {
+			key, paramValue, _ := strings.Cut(param, "=")
+			property.Params[strings.ToUpper(key)] = strings.Trim(paramValue, `"`)
+		}
+		properties = append(properties, property)
+	}
+	return properties
+}
+
+// Unescaped value of a text property
+func (p ICSProperty) Text() string {
+	return icsUnescaper.Replace(p.Value)
+}
+
+// Parses the value of a DATE or DATE-TIME property into the location
+// Date-times are read in their TZID if it is given and known, otherwise in the location, dates always in the location
+// Returns whether it is a date without a time
+func (p ICSProperty) Time(location *time.Location) (time.Time, bool, error) {
+	if strings.ToUpper(p.Params["VALUE"]) == "DATE" {
+		parsed, err := time.ParseInLocation(icsDateFormat, p.Value, location)
+		return parsed, true, err
+	}
+
+	if strings.HasSuffix(p.Value, "Z") {
+		parsed, err := time.Parse(icsDateTimeFormat+"Z", p.Value)
+		return parsed.In(location), false, err
+	}
+	given := location
+	if tz, ok := p.Params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(tz); err == nil {
+			given = loaded
+		}
+	}
+	if parsed, err := time.ParseInLocation(icsDateTimeFormat, p.Value, given); err == nil {
+		return parsed.In(location), false, nil
+	}
+	parsed, err := time.ParseInLocation(icsDateFormat, p.Value, location)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q", p.Value)
+	}
+	return parsed, true, nil
+}
@@ -0,0 +1,29 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Exports items as an indented JSON array
+type JSON struct{}
+
+func (JSON) Extension() string { return "json" }
+
+func (JSON) ContentType() string { return "application/json" }
+
+func (JSON) Export(w io.Writer, items []Item, now time.Time) error {
+	// Export empty arrays instead of null
+	exported := []Item{}
+	for _, item := range items {
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+		exported = append(exported, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exported)
+}
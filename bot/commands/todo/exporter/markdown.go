@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Exports items as Markdown checklists, one section per list
+// Items are written the way todo add understands them, e.g. `- [ ] Exercise 1 #ana prio:high due 24.12.2022 18:00`
+type Markdown struct{}
+
+// Format of due dates, understood by todo add
+const markdownDueFormat = "2.1.2006 15:04"
+
+// Sections in the order they are exported in
+var markdownSections = []struct {
+	list, title, check string
+}{
+	{"active", "Active", " "},
+	{"completed", "Completed", "x"},
+	{"archived", "Archived", "x"},
+}
+
+func (Markdown) Extension() string { return "md" }
+
+func (Markdown) ContentType() string { return "text/markdown" }
+
+func (Markdown) Export(w io.Writer, items []Item, now time.Time) error {
+	builder := strings.Builder{}
+	builder.WriteString("# TODOs\n")
+	for _, section := range markdownSections {
+		lines := []string{}
+		for _, item := range items {
+			if item.List != section.list {
+				continue
+			}
+			lines = append(lines, markdownItem(item, section.check))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		builder.WriteString("\n## " + section.title + "\n\n")
+		builder.WriteString(strings.Join(lines, ""))
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Formats an item as a checklist entry, with its description indented below it
+func markdownItem(item Item, check string) string {
+	words := []string{item.Title}
+	for _, tag := range item.Tags {
+		words = append(words, "#"+tag)
+	}
+	if item.Priority != "" {
+		words = append(words, "prio:"+item.Priority)
+	}
+	if item.Due != nil {
+		words = append(words, "due", item.Due.Format(markdownDueFormat))
+	}
+
+	line := fmt.Sprintf("- [%s] %s\n", check, strings.Join(words, " "))
+	if item.Description != "" {
+		for _, descriptionLine := range strings.Split(item.Description, "\n") {
+			line += "  " + descriptionLine + "\n"
+		}
+	}
+	return line
+}
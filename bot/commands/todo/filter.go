@@ -0,0 +1,209 @@
+package todo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Priorities of items, items without a priority have priorityNone
+const (
+	priorityNone = iota
+	priorityLow
+	priorityMedium
+	priorityHigh
+)
+
+var priorities = map[string]int{
+	"none":   priorityNone,
+	"low":    priorityLow,
+	"l":      priorityLow,
+	"medium": priorityMedium,
+	"med":    priorityMedium,
+	"m":      priorityMedium,
+	"high":   priorityHigh,
+	"h":      priorityHigh,
+}
+
+var priorityLabels = []string{"", "Low", "Medium", "High"}
+
+// The lists of a user together with the name of the list
+const userListsQuery = `(
+	SELECT 'active' AS list, task, discord_user FROM todo.active
+	UNION ALL SELECT 'completed', task, discord_user FROM todo.completed
+	UNION ALL SELECT 'archived', task, discord_user FROM todo.archived
+)`
+
+// ORDER BY clauses of the sort keys of todo list
+var sortKeys = map[string]string{
+	"due":      "t.due ASC NULLS LAST, t.priority DESC, t.id ASC",
+	"prio":     "t.priority DESC, t.due ASC NULLS LAST, t.id ASC",
+	"priority": "t.priority DESC, t.due ASC NULLS LAST, t.id ASC",
+	"title":    "LOWER(t.title) ASC, t.id ASC",
+	"id":       "t.id ASC",
+}
+
+var (
+	tagRegex         = regexp.MustCompile(`^#([^\s#]+)$`)
+	comparisonRegex  = regexp.MustCompile(`^(prio|priority|due)(<=|>=|<|>|=)(.+)$`)
+	setPriorityRegex = regexp.MustCompile(`^(?:prio|priority)[:=](.+)$`)
+)
+
+// Filter for the items of a user, the zero value matches nothing
+type listFilter struct {
+	lists      []string // Any of active, completed and archived
+	tags       []string // Tags all items need to have
+	priorityOp string   // Comparison of the priority, empty if the priority isn't filtered
+	priority   int
+	dueOp      string // Comparison of the due date, empty if the due date isn't filtered
+	due        time.Time
+	sort       string // Key of sortKeys, empty to sort by urgency
+}
+
+// Returns a filter matching all items of the lists
+func listsFilter(lists ...string) listFilter {
+	return listFilter{lists: lists}
+}
+
+// Parses the arguments of todo list, such as `all #exam prio>=high due<7d sort:due`
+// Without any list given, only active items are listed
+func parseListFilter(args []string, now time.Time) (listFilter, error) {
+	filter := listFilter{}
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		switch lower {
+		case "all":
+			filter.lists = append(filter.lists, "active", "completed", "archived")
+			continue
+		case "active":
+			filter.lists = append(filter.lists, "active")
+			continue
+		case "archive", "archived":
+			filter.lists = append(filter.lists, "archived")
+			continue
+		case "done", "checked", "check", "completed":
+			filter.lists = append(filter.lists, "completed")
+			continue
+		}
+
+		if match := tagRegex.FindStringSubmatch(lower); match != nil {
+			filter.tags = append(filter.tags, match[1])
+		} else if strings.HasPrefix(lower, "sort:") {
+			key := strings.TrimPrefix(lower, "sort:")
+			if _, ok := sortKeys[key]; !ok {
+				return listFilter{}, fmt.Errorf("invalid sort key %q", key)
+			}
+			filter.sort = key
+		} else if match := comparisonRegex.FindStringSubmatch(lower); match != nil {
+			if match[1] == "due" {
+				due, err := parseDueBound(match[3], now)
+				if err != nil {
+					return listFilter{}, err
+				}
+				filter.dueOp, filter.due = match[2], due
+			} else {
+				priority, err := parsePriority(match[3])
+				if err != nil {
+					return listFilter{}, err
+				}
+				filter.priorityOp, filter.priority = match[2], priority
+			}
+		} else {
+			return listFilter{}, fmt.Errorf("invalid filter %q", arg)
+		}
+	}
+
+	if len(filter.lists) == 0 {
+		filter.lists = []string{"active"}
+	}
+	filter.lists = deduplicate(filter.lists)
+	sort.Strings(filter.lists)
+	filter.tags = deduplicate(filter.tags)
+
+	return filter, nil
+}
+
+// Parses the bound of a due date filter, either a duration from now such as 7d, now or a due date such as fri
+func parseDueBound(raw string, now time.Time) (time.Time, error) {
+	if raw == "now" {
+		return now, nil
+	}
+	if duration, err := parseLeadTime(raw); err == nil {
+		return now.Add(duration), nil
+	}
+	return parseDue(raw, now)
+}
+
+// Parses a priority such as high or h
+func parsePriority(raw string) (int, error) {
+	priority, ok := priorities[strings.ToLower(raw)]
+	if !ok {
+		return 0, fmt.Errorf("invalid priority %q", raw)
+	}
+	return priority, nil
+}
+
+// Returns the query and its arguments to get the items of the user matching the filter
+func (f listFilter) query(user string) (string, []interface{}) {
+	query := `SELECT t.id, t.creator, t.title, t.description, t.due, t.tags, t.priority, ` + subtaskProgressColumns + ` FROM todo.task AS t JOIN ` +
+		userListsQuery + ` AS l ON l.task=t.id WHERE l.discord_user=$1 AND l.list=ANY($2)`
+	args := []interface{}{user, pq.Array(f.lists)}
+
+	if len(f.tags) != 0 {
+		args = append(args, pq.Array(f.tags))
+		query += fmt.Sprintf(" AND t.tags @> $%d", len(args))
+	}
+	if f.priorityOp != "" {
+		args = append(args, f.priority)
+		query += fmt.Sprintf(" AND t.priority %s $%d", f.priorityOp, len(args))
+	}
+	if f.dueOp != "" {
+		args = append(args, f.due)
+		query += fmt.Sprintf(" AND t.due %s $%d", f.dueOp, len(args))
+	}
+
+	order, ok := sortKeys[f.sort]
+	if !ok {
+		order = sortKeys["due"]
+	}
+	query += " ORDER BY " + order
+
+	return query, args
+}
+
+// Splits the tags, such as #exam, and the priority, such as prio:high, from the rest of the words
+func splitTagsAndPriority(words []string) (rest, tags []string, priority int) {
+	rest = []string{}
+	tags = []string{}
+	for _, word := range words {
+		if match := tagRegex.FindStringSubmatch(strings.ToLower(word)); match != nil {
+			tags = append(tags, match[1])
+		} else if match := setPriorityRegex.FindStringSubmatch(strings.ToLower(word)); match != nil {
+			parsed, err := parsePriority(match[1])
+			if err != nil {
+				rest = append(rest, word)
+				continue
+			}
+			priority = parsed
+		} else {
+			rest = append(rest, word)
+		}
+	}
+	return rest, deduplicate(tags), priority
+}
+
+// Formats the tags and the priority of an item the way splitTagsAndPriority parses them
+func formatTagsAndPriority(tags []string, priority int) string {
+	words := []string{}
+	for _, tag := range tags {
+		words = append(words, "#"+tag)
+	}
+	if priority != priorityNone {
+		words = append(words, "prio:"+strings.ToLower(priorityLabels[priority]))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListFilter(t *testing.T) {
+	tests := []struct {
+		input          []string
+		expectedOutput listFilter
+		expectedError  error
+	}{
+		{[]string{}, listFilter{lists: []string{"active"}}, nil},
+		{[]string{"all"}, listFilter{lists: []string{"active", "archived", "completed"}}, nil},
+		{[]string{"done", "archived"}, listFilter{lists: []string{"archived", "completed"}}, nil},
+		{[]string{"#Exam", "#ana", "#exam"}, listFilter{lists: []string{"active"}, tags: []string{"exam", "ana"}}, nil},
+		{[]string{"prio>=high"}, listFilter{lists: []string{"active"}, priorityOp: ">=", priority: priorityHigh}, nil},
+		{[]string{"priority=none"}, listFilter{lists: []string{"active"}, priorityOp: "=", priority: priorityNone}, nil},
+		{[]string{"due<7d"}, listFilter{lists: []string{"active"}, dueOp: "<", due: date(6, 8, 12, 0)}, nil},
+		{[]string{"due<=fri"}, listFilter{lists: []string{"active"}, dueOp: "<=", due: date(6, 3, 23, 59)}, nil},
+		{[]string{"due<now"}, listFilter{lists: []string{"active"}, dueOp: "<", due: testNow}, nil},
+		{[]string{"sort:due"}, listFilter{lists: []string{"active"}, sort: "due"}, nil},
+		{[]string{"all", "#exam", "prio>low", "sort:prio"}, listFilter{lists: []string{"active", "archived", "completed"}, tags: []string{"exam"}, priorityOp: ">", priority: priorityLow, sort: "prio"}, nil},
+
+		{[]string{"foo"}, listFilter{}, fmt.Errorf("invalid filter %q", "foo")},
+		{[]string{"sort:foo"}, listFilter{}, fmt.Errorf("invalid sort key %q", "foo")},
+		{[]string{"prio>=urgent"}, listFilter{}, fmt.Errorf("invalid priority %q", "urgent")},
+		{[]string{"prio=>high"}, listFilter{}, fmt.Errorf("invalid priority %q", ">high")},
+	}
+
+	for _, test := range tests {
+		result, err := parseListFilter(test.input, testNow)
+		assert.Equal(t, test.expectedOutput, result, test.input)
+		assert.Equal(t, test.expectedError, err, test.input)
+	}
+}
+
+func TestListFilterQuery(t *testing.T) {
+	filter := listFilter{
+		lists:      []string{"active"},
+		tags:       []string{"exam"},
+		priorityOp: ">=",
+		priority:   priorityHigh,
+		dueOp:      "<",
+		due:        testNow,
+		sort:       "title",
+	}
+
+	query, args := filter.query("userId")
+
+	assert.Contains(t, query, "WHERE l.discord_user=$1 AND l.list=ANY($2) AND t.tags @> $3 AND t.priority >= $4 AND t.due < $5 ORDER BY LOWER(t.title) ASC, t.id ASC")
+	assert.Equal(t, []interface{}{"userId", pq.Array([]string{"active"}), pq.Array([]string{"exam"}), priorityHigh, testNow}, args)
+}
+
+func TestSplitTagsAndPriority(t *testing.T) {
+	tests := []struct {
+		input            []string
+		expectedRest     []string
+		expectedTags     []string
+		expectedPriority int
+	}{
+		{[]string{"Study"}, []string{"Study"}, nil, priorityNone},
+		{[]string{"Study", "#Exam", "prio:high"}, []string{"Study"}, []string{"exam"}, priorityHigh},
+		{[]string{"#a", "Study", "#b", "priority=l", "due", "fri"}, []string{"Study", "due", "fri"}, []string{"a", "b"}, priorityLow},
+		{[]string{"prio:urgent", "#"}, []string{"prio:urgent", "#"}, nil, priorityNone},
+	}
+
+	for _, test := range tests {
+		rest, tags, priority := splitTagsAndPriority(test.input)
+		assert.Equal(t, test.expectedRest, rest)
+		assert.Equal(t, test.expectedTags, tags)
+		assert.Equal(t, test.expectedPriority, priority)
+	}
+}
+
+func TestFormatTagsAndPriority(t *testing.T) {
+	assert.Equal(t, "", formatTagsAndPriority(nil, priorityNone))
+	assert.Equal(t, "#exam #ana prio:medium", formatTagsAndPriority([]string{"exam", "ana"}, priorityMedium))
+
+	_, tags, priority := splitTagsAndPriority([]string{"#exam", "#ana", "prio:medium"})
+	assert.Equal(t, []string{"exam", "ana"}, tags)
+	assert.Equal(t, priorityMedium, priority)
+}
@@ -0,0 +1,830 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/paginator"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+// Prefix of the CustomIDs of the join buttons of group invites, followed by the ID of the group
+const groupJoinPrefix = "todo.group-join:"
+
+var mentionRegex = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+type studyGroup struct {
+	ID      int
+	Name    string
+	Creator string
+	Members []string
+}
+
+// Item of a group together with its state in the group
+type groupItem struct {
+	todoItem
+	Status      string // One of active, completed and archived
+	Assignee    string // Empty if the item isn't assigned to anyone
+	CompletedBy string // Empty if the item hasn't been completed
+}
+
+func (s Todo) groupHelp() string {
+	return "Usage: `todo group [create|list|invite|leave|show|add|assign|done|archive|delete]`\n" +
+		"`todo group create <name>` creates a shared list and `todo group list` shows the groups you are in.\n" +
+		"`todo group invite <group> @user..` invites members, `todo group leave <group>` leaves a group.\n" +
+		"`todo group show <group> [all|active|done|archived|mine]` lists the items of a group, together with who they are assigned to and who completed them.\n" +
+		"`todo group add <group> <title>` adds an item, the title may contain tags, a priority and a due date like with `todo add`.\n" +
+		"`todo group assign <group> <id> [@user]` assigns an item to a member, or unassigns it if no member is given.\n" +
+		"`todo group [done|archive|delete] <group> <id[,id..]>` changes the items of the group.\n" +
+		"Groups can be referred to by their ID or their name."
+}
+
+func (s Todo) Group(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.groupHelp())
+		return nil
+	}
+
+	switch args[0] {
+	case "create", "new":
+		return s.groupCreate(bot, ctx, args[1:])
+	case "list":
+		return s.groupList(bot, ctx)
+	}
+
+	if len(args) < 2 {
+		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.groupHelp())
+		return nil
+	}
+	// Names may consist of several words, the arguments of the subcommand follow them
+	group, rest, err := s.findGroup(ctx.Author.ID, args[1:])
+	if err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, "You are not in a group called `"+strings.Join(args[1:], " ")+"`.")
+			return nil
+		default:
+			return err
+		}
+	}
+
+	switch args[0] {
+	case "invite":
+		return s.groupInvite(bot, ctx, group, rest)
+	case "leave":
+		if err := s.leaveGroup(group.ID, ctx.Author.ID); err != nil {
+			return err
+		}
+		s.replyAndDelete(bot, ctx, "Successfully left "+group.Name+".")
+	case "show":
+		return s.groupShow(bot, ctx, args[1:])
+	case "add":
+		return s.groupAdd(bot, ctx, group, rest)
+	case "assign", "unassign":
+		return s.groupAssign(bot, ctx, group, rest)
+	case "done", "check":
+		return s.groupChangeStatus(bot, ctx, group, rest, []string{"active"}, "completed", "marked %s as done")
+	case "archive":
+		return s.groupChangeStatus(bot, ctx, group, rest, []string{"active", "completed"}, "archived", "archived %s")
+	case "delete", "remove":
+		ids, err := parseIds(rest)
+		if err != nil {
+			s.replyAndDelete(bot, ctx, "Error parsing IDs.\n"+s.groupHelp())
+			return nil
+		}
+		if err := s.deleteGroupItems(ctx.Author.ID, group.ID, ids); err != nil {
+			switch err.(type) {
+			case *InvalidIDError:
+				s.replyAndDelete(bot, ctx, fmt.Sprintf("You supplied an invalid ID: %v", err))
+				return nil
+			default:
+				return err
+			}
+		}
+		s.replyAndDelete(bot, ctx, "Successfully deleted "+strings.Join(ids, ", ")+".")
+	default:
+		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.groupHelp())
+	}
+	return nil
+}
+
+// Sends the content, then deletes it together with the command after a delay
+func (s Todo) replyAndDelete(bot *discord.Session, ctx *discord.MessageCreate, content string) {
+	msg, _ := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Content: content,
+		AllowedMentions: &discord.MessageAllowedMentions{
+			Users: []string{},
+		},
+	})
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+}
+
+func (s Todo) groupCreate(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	name := strings.Join(args, " ")
+	if name == "" || len(name) > 64 {
+		s.replyAndDelete(bot, ctx, "Please supply a name of at most 64 characters, e.g. `todo group create Analysis study group`.")
+		return nil
+	}
+
+	id, err := s.createGroup(ctx.Author.ID, name)
+	if err != nil {
+		return err
+	}
+
+	bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Created the group **%s** with ID `%d`. Use `todo group invite %d @user` to invite members.", name, id, id))
+	return nil
+}
+
+func (s Todo) groupList(bot *discord.Session, ctx *discord.MessageCreate) error {
+	groups, err := s.getUserGroups(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		s.replyAndDelete(bot, ctx, "You are not in any groups. Create one with `todo group create <name>`.")
+		return nil
+	}
+
+	fields := []*discord.MessageEmbedField{}
+	for _, group := range groups {
+		members := []string{}
+		for _, member := range group.Members {
+			members = append(members, "<@"+member+">")
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  fmt.Sprintf("%d: %s", group.ID, group.Name),
+			Value: "Members: " + strings.Join(members, ", "),
+		})
+	}
+
+	bot.ChannelMessageSendEmbed(ctx.ChannelID, &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: ctx.Author.Username + "s groups",
+		},
+		Color:  todoEmbedColor,
+		Fields: fields,
+	})
+	return nil
+}
+
+func (s Todo) groupInvite(bot *discord.Session, ctx *discord.MessageCreate, group studyGroup, args []string) error {
+	invited := []string{}
+	for _, arg := range args {
+		if match := mentionRegex.FindStringSubmatch(arg); match != nil && !group.hasMember(match[1]) {
+			invited = append(invited, match[1])
+		}
+	}
+	invited = deduplicate(invited)
+	if len(invited) == 0 {
+		s.replyAndDelete(bot, ctx, "Please mention the users to invite who aren't in the group yet, e.g. `todo group invite "+fmt.Sprint(group.ID)+" @user`.")
+		return nil
+	}
+
+	mentions := []string{}
+	for _, id := range invited {
+		mentions = append(mentions, "<@"+id+">")
+	}
+
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	_, err := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Content: strings.Join(mentions, " ") + ", " + ctx.Author.Mention() + " invited you to the study group **" + group.Name + "**.",
+		Components: []discord.MessageComponent{
+			discord.ActionsRow{
+				Components: []discord.MessageComponent{
+					discord.Button{
+						Label:    "Join " + group.Name,
+						Style:    discord.SuccessButton,
+						CustomID: fmt.Sprint(groupJoinPrefix, group.ID),
+					},
+				},
+			},
+		},
+		AllowedMentions: &discord.MessageAllowedMentions{
+			Users: invited,
+		},
+	})
+	return err
+}
+
+// Callback for the join button of group invites, only the users mentioned in the invite can join
+func (s Todo) handleGroupJoin(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	groupId, err := strconv.Atoi(strings.TrimPrefix(interaction.MessageComponentData().CustomID, groupJoinPrefix))
+	if err != nil {
+		return err
+	}
+
+	user := interactionUser(interaction)
+	invited := false
+	for _, mentioned := range interaction.Message.Mentions {
+		invited = invited || mentioned.ID == user.ID
+	}
+	if !invited {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: "You weren't invited to this group.",
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		})
+	}
+
+	if err := s.checkUserPresence(user.ID); err != nil {
+		return err
+	}
+	if err := s.joinGroup(groupId, user.ID); err != nil {
+		return err
+	}
+
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Content: "You joined the group, use `todo group show " + fmt.Sprint(groupId) + "` to see its items.",
+			Flags:   uint64(discord.MessageFlagsEphemeral),
+		},
+	})
+}
+
+// Sends the paginated items of a group, args are the group followed by an optional filter
+func (s Todo) groupShow(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	page, err := s.groupPage(ctx.Author, strings.Join(args, " "), 0)
+	if err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, "Couldn't interpret command.\n"+s.groupHelp())
+			return nil
+		default:
+			return err
+		}
+	}
+
+	bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Embeds:     page.Embeds,
+		Components: groupPaginator.Components(page, ctx.Author.ID, 0),
+	})
+	return nil
+}
+
+// Returns a page of the items of a group, rawArgs are the group followed by an optional filter
+// Returns an InvalidIDError if the user isn't in the group or the filter is invalid
+func (s Todo) groupPage(user *discord.User, rawArgs string, page int) (paginator.Page, error) {
+	group, filter, err := s.findGroup(user.ID, strings.Fields(rawArgs))
+	if err != nil {
+		return paginator.Page{}, err
+	}
+	if len(filter) > 1 {
+		return paginator.Page{}, &InvalidIDError{filter}
+	}
+
+	lists, assignee := []string{"active"}, ""
+	if len(filter) == 1 {
+		switch filter[0] {
+		case "all":
+			lists = []string{"active", "completed", "archived"}
+		case "active":
+		case "done", "completed":
+			lists = []string{"completed"}
+		case "archive", "archived":
+			lists = []string{"archived"}
+		case "mine":
+			assignee = user.ID
+		default:
+			return paginator.Page{}, &InvalidIDError{filter}
+		}
+	}
+
+	items, err := s.getGroupTODOs(group.ID, lists, assignee)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+
+	start, end := paginator.Bounds(len(items), listPageSize, page)
+	return paginator.Page{
+		Embeds: []*discord.MessageEmbed{groupToEmbed(group, items[start:end], start, rawArgs, user)},
+		Pages:  paginator.Pages(len(items), listPageSize),
+	}, nil
+}
+
+// Renders a page of a group message, using the group and filter shown in its embed
+func (s Todo) renderGroupPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	if len(msg.Embeds) == 0 {
+		return paginator.Page{}, fmt.Errorf("group message %s has no embed", msg.ID)
+	}
+	return s.groupPage(user, strings.Trim(strings.TrimPrefix(msg.Embeds[0].Description, filterPrefix), "`"), page)
+}
+
+// Returns an embed containing the items of a group, showing who they are assigned to and who completed them
+func groupToEmbed(group studyGroup, items []groupItem, offset int, rawArgs string, user *discord.User) *discord.MessageEmbed {
+	embed := todosToEmbed(nil, offset, user)
+
+	for i, item := range items {
+		labels := []string{}
+		if item.Status != "active" {
+			labels = append(labels, "**"+listLabels[item.Status]+"**")
+		}
+		if item.Assignee != "" {
+			labels = append(labels, "Assigned to <@"+item.Assignee+">")
+		}
+		if item.CompletedBy != "" {
+			labels = append(labels, "Done by <@"+item.CompletedBy+">")
+		}
+		embed.Fields = append(embed.Fields, itemEmbedField(offset+i+1, item.todoItem, labels...))
+	}
+
+	embed.Author.Name = group.Name
+	embed.Description = filterPrefix + "`" + rawArgs + "`"
+	fitEmbed(embed)
+	return embed
+}
+
+func (s Todo) groupAdd(bot *discord.Session, ctx *discord.MessageCreate, group studyGroup, args []string) error {
+	now, err := s.userNow(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	rest, tags, priority := splitTagsAndPriority(args)
+	title, due := splitTitleAndDue(rest, now)
+	if title == "" {
+		s.replyAndDelete(bot, ctx, "Please supply a title, e.g. `todo group add "+fmt.Sprint(group.ID)+" Exercise sheet 3 due fri`.")
+		return nil
+	}
+
+	taskId, err := s.addGroupItem(group.ID, ctx.Author.ID, todoItem{Title: title, Due: due, Tags: tags, Priority: priority})
+	if err != nil {
+		return err
+	}
+
+	s.replyAndDelete(bot, ctx, fmt.Sprintf("Successfully added %s with ID %d to %s.", title, taskId, group.Name))
+	return nil
+}
+
+func (s Todo) groupAssign(bot *discord.Session, ctx *discord.MessageCreate, group studyGroup, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		s.replyAndDelete(bot, ctx, "Couldn't interpret command.\n"+s.groupHelp())
+		return nil
+	}
+
+	taskId, err := strconv.Atoi(args[0])
+	if err != nil {
+		s.replyAndDelete(bot, ctx, "Error parsing ID.\n"+s.groupHelp())
+		return nil
+	}
+
+	assignee := ""
+	if len(args) == 2 {
+		match := mentionRegex.FindStringSubmatch(args[1])
+		if match == nil || !group.hasMember(match[1]) {
+			s.replyAndDelete(bot, ctx, "Items can only be assigned to members of the group.")
+			return nil
+		}
+		assignee = match[1]
+	}
+
+	if err := s.assignGroupItem(group.ID, taskId, assignee); err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("You supplied an invalid ID: %v", err))
+			return nil
+		default:
+			return err
+		}
+	}
+
+	if assignee == "" {
+		s.replyAndDelete(bot, ctx, "Successfully unassigned "+args[0]+".")
+	} else {
+		s.replyAndDelete(bot, ctx, "Successfully assigned "+args[0]+" to <@"+assignee+">.")
+	}
+	return nil
+}
+
+// Changes the status of the items given in args, result is formatted with the changed IDs
+func (s Todo) groupChangeStatus(bot *discord.Session, ctx *discord.MessageCreate, group studyGroup, args, from []string, to, result string) error {
+	ids, err := parseIds(args)
+	if err != nil {
+		s.replyAndDelete(bot, ctx, "Error parsing IDs.\n"+s.groupHelp())
+		return nil
+	}
+	if err := s.changeGroupItemsStatus(ctx.Author.ID, group.ID, ids, from, to); err != nil {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("You supplied an invalid ID: %v", err))
+			return nil
+		default:
+			return err
+		}
+	}
+	s.replyAndDelete(bot, ctx, "Successfully "+fmt.Sprintf(result, strings.Join(ids, ", "))+".")
+	return nil
+}
+
+// Returns whether the user is a member of the group
+func (g studyGroup) hasMember(userId string) bool {
+	for _, member := range g.Members {
+		if member == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates a group with the creator as its only member and returns its ID
+func (s Todo) createGroup(creator, name string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var groupId int
+	if err := tx.QueryRow(`INSERT INTO todo.study_group (group_name, creator) VALUES ($1, $2) RETURNING id`,
+		name,
+		creator,
+	).Scan(&groupId); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO todo.group_member (study_group, discord_user) VALUES ($1, $2)`,
+		groupId,
+		creator,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, err1
+		}
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("%s Created group %d; creator: %s, name: %s\n", constants.Blue, groupId, creator, name)
+	return groupId, nil
+}
+
+// Returns the groups the user is a member of
+func (s Todo) getUserGroups(userId string) ([]studyGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT g.id, g.group_name, g.creator, ARRAY_AGG(m.discord_user ORDER BY m.discord_user)
+		FROM todo.study_group AS g JOIN todo.group_member AS m ON m.study_group=g.id
+		WHERE g.id IN (SELECT study_group FROM todo.group_member WHERE discord_user=$1)
+		GROUP BY g.id ORDER BY g.id`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []studyGroup{}
+	for rows.Next() {
+		group := studyGroup{}
+		var members pq.StringArray
+		rows.Scan(&group.ID, &group.Name, &group.Creator, &members)
+		group.Members = members
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// Returns the group of the user with the ID or name raw
+// Returns an InvalidIDError if the user isn't in such a group
+func (s Todo) getGroup(userId, raw string) (studyGroup, error) {
+	groups, err := s.getUserGroups(userId)
+	if err != nil {
+		return studyGroup{}, err
+	}
+	for _, group := range groups {
+		if fmt.Sprint(group.ID) == raw || strings.EqualFold(group.Name, raw) {
+			return group, nil
+		}
+	}
+	return studyGroup{}, &InvalidIDError{[]string{raw}}
+}
+
+// Returns the group of the user whose ID or name the arguments start with, together with the remaining arguments
+// The longest matching name is used, so names may consist of several words
+// Returns an InvalidIDError if the arguments don't start with a group of the user
+func (s Todo) findGroup(userId string, args []string) (studyGroup, []string, error) {
+	groups, err := s.getUserGroups(userId)
+	if err != nil {
+		return studyGroup{}, nil, err
+	}
+	for i := len(args); i > 0; i-- {
+		raw := strings.Join(args[:i], " ")
+		for _, group := range groups {
+			if fmt.Sprint(group.ID) == raw || strings.EqualFold(group.Name, raw) {
+				return group, args[i:], nil
+			}
+		}
+	}
+	return studyGroup{}, nil, &InvalidIDError{args}
+}
+
+// Adds the user to the group, if they aren't a member already
+func (s Todo) joinGroup(groupId int, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO todo.group_member (study_group, discord_user) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		groupId,
+		userId,
+	); err != nil {
+		return err
+	}
+
+	log.Printf("%s User %s joined group %d\n", constants.Blue, userId, groupId)
+	notifyGroupBoards(groupId)
+	return nil
+}
+
+// Removes the user from the group, deleting the group together with its items if it has no members left
+func (s Todo) leaveGroup(groupId int, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todo.group_member WHERE study_group=$1 AND discord_user=$2`,
+		groupId,
+		userId,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	// Assignments to the user are dropped, as only members can be assigned items
+	if _, err := tx.Exec(`UPDATE todo.group_task SET assignee=NULL WHERE study_group=$1 AND assignee=$2`,
+		groupId,
+		userId,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todo.study_group WHERE id=$1 AND id NOT IN (SELECT study_group FROM todo.group_member)`,
+		groupId,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s User %s left group %d\n", constants.Blue, userId, groupId)
+	notifyGroupBoards(groupId)
+	return nil
+}
+
+// Adds an active item to the group and returns its ID
+func (s Todo) addGroupItem(groupId int, author string, item todoItem) (int, error) {
+	taskId, err := s.CreateTask(author, item)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO todo.group_task (study_group, task) VALUES ($1, $2)`,
+		groupId,
+		taskId,
+	); err != nil {
+		return 0, err
+	}
+
+	notifyGroupBoards(groupId)
+	return taskId, nil
+}
+
+// Assigns the item of the group to the assignee, or unassigns it if assignee is empty
+// Returns an InvalidIDError if the item isn't in the group
+func (s Todo) assignGroupItem(groupId, taskId int, assignee string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var assigneeArg interface{}
+	if assignee != "" {
+		assigneeArg = assignee
+	}
+
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE todo.group_task SET assignee=$3 WHERE study_group=$1 AND task=$2`,
+		groupId,
+		taskId,
+		assigneeArg,
+	)
+	if err != nil {
+		return err
+	}
+	if changed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if changed == 0 {
+		return &InvalidIDError{[]string{fmt.Sprint(taskId)}}
+	}
+
+	log.Printf("%s Assigned item %d of group %d to %q\n", constants.Blue, taskId, groupId, assignee)
+	notifyGroupBoards(groupId)
+	return nil
+}
+
+// Returns the items of the group in one of the lists, only the ones assigned to assignee unless it is empty
+func (s Todo) getGroupTODOs(groupId int, lists []string, assignee string) ([]groupItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT t.id, t.creator, t.title, t.description, t.due, t.tags, t.priority, g.status, g.assignee, g.completed_by
+		FROM todo.task AS t JOIN todo.group_task AS g ON g.task=t.id
+		WHERE g.study_group=$1 AND g.status=ANY($2) AND ($3='' OR g.assignee=$3)
+		ORDER BY `+sortKeys["due"],
+		groupId,
+		pq.Array(lists),
+		assignee,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []groupItem{}
+	for rows.Next() {
+		item := groupItem{}
+		var due sql.NullTime
+		var tags pq.StringArray
+		var itemAssignee, completedBy sql.NullString
+		rows.Scan(&item.ID, &item.Creator, &item.Title, &item.Description, &due, &tags, &item.Priority, &item.Status, &itemAssignee, &completedBy)
+		if due.Valid {
+			item.Due = &due.Time
+		}
+		item.Tags = tags
+		item.Assignee = itemAssignee.String
+		item.CompletedBy = completedBy.String
+		items = append(items, item)
+	}
+	log.Printf("%s Got group TODOs; Group: %d, Lists: %v\n", constants.Blue, groupId, lists)
+
+	return items, nil
+}
+
+// Changes the status of the groups items from one of "from" to "to", completed items remember who completed them
+// The change is journaled as done by the user
+// Returns an InvalidIDError if invalid IDs were supplied, in which case no item is changed
+func (s Todo) changeGroupItemsStatus(userId string, groupId int, itemIds []string, from []string, to string) error {
+	if err := checkNoSubtaskIds(itemIds); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := recordGroupChange(tx, userId, groupId, itemIds, from, to); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	query := `UPDATE todo.group_task SET status=$4 WHERE study_group=$1 AND task=ANY($2) AND status=ANY($3) RETURNING task`
+	args := []interface{}{groupId, pq.Array(itemIds), pq.Array(from), to}
+	if to == "completed" {
+		query = `UPDATE todo.group_task SET status=$4, completed_by=$5, completed_at=now() WHERE study_group=$1 AND task=ANY($2) AND status=ANY($3) RETURNING task`
+		args = append(args, userId)
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	// For checking for invalid IDs
+	idsCopy := make([]string, len(itemIds))
+	copy(idsCopy, itemIds)
+
+	for rows.Next() {
+		var item string
+		rows.Scan(&item)
+		for i := range idsCopy {
+			if idsCopy[i] == item {
+				idsCopy = append(idsCopy[:i], idsCopy[i+1:]...)
+				break
+			}
+		}
+	}
+	rows.Close()
+
+	// Check for wrong ID supplied
+	if len(idsCopy) != 0 {
+		if err := tx.Rollback(); err != nil {
+			return err
+		}
+		return &InvalidIDError{idsCopy}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s User %s changed items %v of group %d from %v to %s\n", constants.Blue, userId, itemIds, groupId, from, to)
+	notifyGroupBoards(groupId)
+	return nil
+}
+
+// Deletes the items from the group, the deletion is journaled as done by the user
+// Returns an InvalidIDError if invalid IDs were supplied, in which case no item is deleted
+func (s Todo) deleteGroupItems(userId string, groupId int, itemIds []string) error {
+	if err := checkNoSubtaskIds(itemIds); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// The journal keeps the values of the deleted items, so undoing the deletion restores them
+	if _, err := recordGroupChange(tx, userId, groupId, itemIds, []string{"active", "completed", "archived"}, ""); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM todo.group_task WHERE study_group=$1 AND task=ANY($2)`,
+		groupId,
+		pq.Array(itemIds),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil || int(deleted) != len(itemIds) {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		if err != nil {
+			return err
+		}
+		return &InvalidIDError{itemIds}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s User %s deleted items %v of group %d\n", constants.Blue, userId, itemIds, groupId)
+	notifyGroupBoards(groupId)
+	return nil
+}
@@ -0,0 +1,153 @@
+package todo
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectUserGroups(userId string) {
+	dbMock.ExpectQuery(`SELECT g.id, g.group_name, g.creator, ARRAY_AGG`).
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "group_name", "creator", "members"}).
+			AddRow(1, "Analysis", "0", "{0,1}").
+			AddRow(2, "Physics", "1", "{0,1,2}"))
+}
+
+func TestGetGroup(t *testing.T) {
+	tests := []struct {
+		input      string
+		expectedID int
+		valid      bool
+	}{
+		{"1", 1, true},
+		{"physics", 2, true},
+		{"3", 0, false},
+		{"Chemistry", 0, false},
+	}
+
+	for _, test := range tests {
+		expectUserGroups("0")
+
+		group, err := mockTodo.getGroup("0", test.input)
+
+		if test.valid {
+			assert.Equal(t, test.expectedID, group.ID)
+			assert.Nil(t, err)
+		} else {
+			assert.IsType(t, &InvalidIDError{}, err)
+		}
+		assert.Nil(t, dbMock.ExpectationsWereMet())
+	}
+}
+
+func TestFindGroup(t *testing.T) {
+	tests := []struct {
+		input      []string
+		expectedID int
+		rest       []string
+		valid      bool
+	}{
+		{[]string{"1", "2", "3"}, 1, []string{"2", "3"}, true},
+		{[]string{"Analysis", "study", "group", "4"}, 3, []string{"4"}, true},
+		{[]string{"analysis", "4"}, 1, []string{"4"}, true},
+		{[]string{"Analysis", "study"}, 1, []string{"study"}, true},
+		{[]string{"Chemistry", "4"}, 0, nil, false},
+		{[]string{}, 0, nil, false},
+	}
+
+	for _, test := range tests {
+		dbMock.ExpectQuery(`SELECT g.id, g.group_name, g.creator, ARRAY_AGG`).
+			WithArgs("0").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "group_name", "creator", "members"}).
+				AddRow(1, "Analysis", "0", "{0,1}").
+				AddRow(3, "Analysis study group", "0", "{0}"))
+
+		group, rest, err := mockTodo.findGroup("0", test.input)
+
+		if test.valid {
+			assert.Nil(t, err, test.input)
+			assert.Equal(t, test.expectedID, group.ID, test.input)
+			assert.Equal(t, test.rest, rest, test.input)
+		} else {
+			assert.IsType(t, &InvalidIDError{}, err, test.input)
+		}
+		assert.Nil(t, dbMock.ExpectationsWereMet())
+	}
+}
+
+func TestStudyGroupHasMember(t *testing.T) {
+	group := studyGroup{Members: []string{"0", "1"}}
+
+	assert.True(t, group.hasMember("1"))
+	assert.False(t, group.hasMember("2"))
+}
+
+// Expects the journaling of a change of group items as an operation with the ID 3
+func expectRecordGroupChange(userId string, groupId int, items, from []string, to interface{}) {
+	dbMock.ExpectQuery(`INSERT INTO todo.operation \(discord_user, action\)`).
+		WithArgs(userId, groupAction).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	dbMock.ExpectExec(`INSERT INTO todo.operation_group_change (.+) FROM todo.group_task`).
+		WithArgs(3, groupId, pq.Array(items), pq.Array(from), to).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(items))))
+}
+
+func TestChangeGroupItemsStatus(t *testing.T) {
+	dbMock.ExpectBegin()
+	expectRecordGroupChange("0", 1, []string{"1", "2"}, []string{"active"}, "completed")
+	dbMock.ExpectQuery(`UPDATE todo.group_task SET status=\$4, completed_by=\$5, completed_at=now\(\)`).
+		WithArgs(1, pq.Array([]string{"1", "2"}), pq.Array([]string{"active"}), "completed", "0").
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("1").AddRow("2"))
+	dbMock.ExpectCommit()
+
+	err := mockTodo.changeGroupItemsStatus("0", 1, []string{"1", "2"}, []string{"active"}, "completed")
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestChangeGroupItemsStatusWrongIDs(t *testing.T) {
+	dbMock.ExpectBegin()
+	expectRecordGroupChange("0", 1, []string{"1", "2"}, []string{"active", "completed"}, "archived")
+	dbMock.ExpectQuery(`UPDATE todo.group_task SET status=\$4 WHERE`).
+		WithArgs(1, pq.Array([]string{"1", "2"}), pq.Array([]string{"active", "completed"}), "archived").
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("1"))
+	dbMock.ExpectRollback()
+
+	err := mockTodo.changeGroupItemsStatus("0", 1, []string{"1", "2"}, []string{"active", "completed"}, "archived")
+
+	assert.Equal(t, &InvalidIDError{[]string{"2"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteGroupItems(t *testing.T) {
+	dbMock.ExpectBegin()
+	// The journal keeps the deleted items, so the deletion can be undone
+	expectRecordGroupChange("0", 1, []string{"1", "2"}, []string{"active", "completed", "archived"}, nil)
+	dbMock.ExpectExec(`DELETE FROM todo.group_task`).
+		WithArgs(1, pq.Array([]string{"1", "2"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectCommit()
+
+	err := mockTodo.deleteGroupItems("0", 1, []string{"1", "2"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteGroupItemsWrongIDs(t *testing.T) {
+	dbMock.ExpectBegin()
+	expectRecordGroupChange("0", 1, []string{"1", "2"}, []string{"active", "completed", "archived"}, nil)
+	dbMock.ExpectExec(`DELETE FROM todo.group_task`).
+		WithArgs(1, pq.Array([]string{"1", "2"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectRollback()
+
+	err := mockTodo.deleteGroupItems("0", 1, []string{"1", "2"})
+
+	assert.IsType(t, &InvalidIDError{}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
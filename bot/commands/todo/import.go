@@ -0,0 +1,560 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/commands/todo/exporter"
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxImportSize   = 1 << 20 // Maximum size of imported files in bytes
+	maxImportItems  = 100     // Maximum amount of items imported at once, keeps the select message manageable
+	importTimeout   = 10 * time.Second
+	importURLPrefix = "https://cdn.discordapp.com/attachments/" // Files can only be imported from Discord
+)
+
+var (
+	markdownItemRegex    = regexp.MustCompile(`^\s*[-*+] \[([ xX])\] (.+)$`)
+	markdownHeadingRegex = regexp.MustCompile(`^#+ (.+)$`)
+)
+
+var importClient = http.Client{Timeout: importTimeout}
+
+func (s Todo) importHelp() string {
+	return "Usage: attach a file to `todo import`\nSupported are CSV and JSON files as `todo export` creates them, Markdown checklists such as `- [ ] Exercise 1 #ana due fri` and iCalendar files.\nYou can deselect the items you don't want to import before they get added."
+}
+
+func (s Todo) Import(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.importHelp())
+		return nil
+	}
+
+	var name, url string
+	if len(ctx.Message.Attachments) != 0 {
+		attachment := ctx.Message.Attachments[0]
+		if attachment.Size > maxImportSize {
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("Files can be at most %d KB large.", maxImportSize/1024))
+			return nil
+		}
+		name, url = attachment.Filename, attachment.URL
+	} else if len(args) == 1 && strings.HasPrefix(args[0], importURLPrefix) {
+		name, url = path.Base(strings.Split(args[0], "?")[0]), args[0]
+	} else {
+		bot.ChannelMessageSend(ctx.ChannelID, s.importHelp())
+		return nil
+	}
+
+	data, err := downloadImport(url)
+	if err != nil {
+		return err
+	}
+	now, err := s.userNow(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	items, err := parseImport(name, data, now)
+	if err != nil {
+		s.replyAndDelete(bot, ctx, "Couldn't import the file: "+err.Error()+".\n"+s.importHelp())
+		return nil
+	}
+	if len(items) == 0 {
+		s.replyAndDelete(bot, ctx, "The file doesn't contain any items.")
+		return nil
+	}
+	if len(items) > maxImportItems {
+		s.replyAndDelete(bot, ctx, fmt.Sprintf("You can import at most %d items at once, the file contains %d.", maxImportItems, len(items)))
+		return nil
+	}
+
+	options := importOptions(items, now.Location())
+	for i := range options {
+		options[i].Default = true
+	}
+	msg, err := s.sendSelectMessage(
+		bot,
+		ctx,
+		options,
+		fmt.Sprintf("%s, the file contains %d items. Deselect the ones you don't want to import.", ctx.Author.Mention(), len(items)),
+		"Items to import",
+		"import",
+	)
+	if err != nil {
+		return err
+	}
+
+	// The items are kept per select message, so importing another file doesn't change what this one imports
+	return s.savePendingImport(msg.ID, ctx.Author.ID, items)
+}
+
+// Downloads a file to import, at most maxImportSize bytes of it
+func downloadImport(url string) ([]byte, error) {
+	resp, err := importClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s failed with status %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+}
+
+// Turns items to import into options for a select message, with their positions in the file as values and due dates in the location
+func importOptions(items []exporter.Item, location *time.Location) []discord.SelectMenuOption {
+	options := []discord.SelectMenuOption{}
+	for i, item := range items {
+		details := []string{}
+		if item.List != "active" {
+			details = append(details, listLabels[item.List])
+		}
+		if item.Due != nil {
+			details = append(details, "Due "+item.Due.In(location).Format(modalDueFormat))
+		}
+		for _, tag := range item.Tags {
+			details = append(details, "#"+tag)
+		}
+		if item.Description != "" {
+			details = append(details, item.Description)
+		}
+		options = append(options, selectOption(fmt.Sprint(i+1), item.Title, strings.Join(details, " ")))
+	}
+	return options
+}
+
+// Returns the options of the pending import of the select message
+func (s Todo) pendingImportOptions(userId, messageId string) ([]discord.SelectMenuOption, error) {
+	items, err := s.getPendingImport(messageId, userId)
+	if err != nil {
+		return nil, err
+	}
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return nil, err
+	}
+	return importOptions(items, location), nil
+}
+
+// Select action importing the selected items of the pending import of the select message
+func (s Todo) importSelected(bot *discord.Session, user *discord.User, selected []string, msg *discord.Message) error {
+	items, err := s.getPendingImport(msg.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	chosen := []exporter.Item{}
+	for i, item := range items {
+		if contains(selected, fmt.Sprint(i+1)) {
+			chosen = append(chosen, item)
+		}
+	}
+
+	ids, operation, err := s.importItems(user.ID, msg.ID, chosen)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("Successfully imported %d items.", len(ids))
+	if len(ids) == 0 {
+		content = "Didn't import any items."
+	}
+
+	finishSelectMessage(bot, msg, content, undoComponents(user.ID, operation)...)
+	return nil
+}
+
+// Parses the items of a file to import, the format is chosen by the extension of its name
+func parseImport(name string, data []byte, now time.Time) ([]exporter.Item, error) {
+	var items []exporter.Item
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		items, err = parseCSVImport(data, now)
+	case ".json":
+		items, err = parseJSONImport(data)
+	case ".md", ".markdown", ".txt":
+		items, err = parseMarkdownImport(data, now)
+	case ".ics", ".ical":
+		items, err = parseICSImport(data, now)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q", path.Ext(name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].Title = strings.TrimSpace(items[i].Title)
+		if items[i].Title == "" {
+			return nil, fmt.Errorf("item %d has no title", i+1)
+		}
+		// Imported items have to fit into the item modal like the ones added with it, so they can be edited
+		if len(items[i].Title) > maxTitleLength {
+			return nil, fmt.Errorf("the title of item %d is longer than %d characters", i+1, maxTitleLength)
+		}
+		if len(items[i].Description) > maxDescriptionLength {
+			return nil, fmt.Errorf("the description of item %d is longer than %d characters", i+1, maxDescriptionLength)
+		}
+		if items[i].Tags == nil {
+			items[i].Tags = []string{}
+		}
+		for _, tag := range items[i].Tags {
+			if !tagRegex.MatchString("#" + tag) {
+				return nil, fmt.Errorf("item %d has the invalid tag %q", i+1, tag)
+			}
+		}
+		priority, err := parsePriority(importPriority(items[i].Priority))
+		if err != nil {
+			return nil, fmt.Errorf("item %d has the invalid priority %q", i+1, items[i].Priority)
+		}
+		if len(formatTagsAndPriority(items[i].Tags, priority)) > maxLabelsLength {
+			return nil, fmt.Errorf("the tags of item %d are longer than %d characters", i+1, maxLabelsLength)
+		}
+		list, err := importList(items[i].List)
+		if err != nil {
+			return nil, fmt.Errorf("item %d is in the invalid list %q", i+1, items[i].List)
+		}
+		items[i].List = list
+	}
+	return items, nil
+}
+
+// Returns the priority to parse, items without one have no priority
+func importPriority(priority string) string {
+	if priority == "" {
+		return "none"
+	}
+	return priority
+}
+
+// Returns the list an item gets imported into, items without one are active
+func importList(list string) (string, error) {
+	switch strings.ToLower(list) {
+	case "", "active":
+		return "active", nil
+	case "completed", "done":
+		return "completed", nil
+	case "archived", "archive":
+		return "archived", nil
+	}
+	return "", fmt.Errorf("invalid list %q", list)
+}
+
+// Splits tags separated by spaces or commas, with or without a leading #
+func splitImportTags(raw string) []string {
+	tags := []string{}
+	for _, tag := range strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool { return r == ' ' || r == ',' }) {
+		if tag = strings.TrimPrefix(tag, "#"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return deduplicate(tags)
+}
+
+// Parses CSV files with a header, of which only the title column is required
+// The columns are the ones todo export creates
+func parseCSVImport(data []byte, now time.Time) ([]exporter.Item, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []exporter.Item{}, nil
+	}
+
+	columns := map[string]int{}
+	for i, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("the CSV file has no title column")
+	}
+	value := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	items := []exporter.Item{}
+	for i, record := range records[1:] {
+		item := exporter.Item{
+			Title:       value(record, "title"),
+			Description: value(record, "description"),
+			Tags:        splitImportTags(value(record, "tags")),
+			Priority:    strings.ToLower(value(record, "priority")),
+			List:        value(record, "list"),
+		}
+		if rawDue := value(record, "due"); rawDue != "" {
+			due, err := time.Parse(time.RFC3339, rawDue)
+			if err != nil {
+				if due, err = parseDue(rawDue, now); err != nil {
+					return nil, fmt.Errorf("item %d has the invalid due date %q", i+1, rawDue)
+				}
+			}
+			item.Due = &due
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Parses JSON files as todo export creates them
+func parseJSONImport(data []byte) ([]exporter.Item, error) {
+	items := []exporter.Item{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Priority = strings.ToLower(items[i].Priority)
+		for j := range items[i].Tags {
+			items[i].Tags[j] = strings.ToLower(strings.TrimPrefix(items[i].Tags[j], "#"))
+		}
+		// Tags differing only in case are the same tag, like in the item modal
+		items[i].Tags = deduplicate(items[i].Tags)
+	}
+	return items, nil
+}
+
+// Parses Markdown checklists, with the items written the way todo add understands them
+// Checked items are completed, unless they are below an Archived heading, and indented lines are their descriptions
+func parseMarkdownImport(data []byte, now time.Time) ([]exporter.Item, error) {
+	items := []exporter.Item{}
+	archived := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if match := markdownHeadingRegex.FindStringSubmatch(line); match != nil {
+			archived = strings.EqualFold(strings.TrimSpace(match[1]), "archived")
+			continue
+		}
+
+		match := markdownItemRegex.FindStringSubmatch(line)
+		if match == nil {
+			// Indented lines below an item are its description
+			if len(items) != 0 && strings.HasPrefix(line, "  ") && strings.TrimSpace(line) != "" {
+				item := &items[len(items)-1]
+				if item.Description != "" {
+					item.Description += "\n"
+				}
+				item.Description += strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		rest, tags, priority := splitTagsAndPriority(strings.Fields(match[2]))
+		title, due := splitTitleAndDue(rest, now)
+		item := exporter.Item{
+			Title:    title,
+			Due:      due,
+			Tags:     tags,
+			Priority: strings.ToLower(priorityLabels[priority]),
+			List:     "active",
+		}
+		if match[1] != " " {
+			item.List = "completed"
+			if archived {
+				item.List = "archived"
+			}
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// Parses the VTODO and VEVENT entries of iCalendar files
+// Entries with the same summary and due date, such as the ones todo export creates, are only imported once
+func parseICSImport(data []byte, now time.Time) ([]exporter.Item, error) {
+	items := []exporter.Item{}
+	seen := map[string]bool{}
+	var item *exporter.Item
+	for _, property := range exporter.ReadICS(data) {
+		value := property.Value
+		switch name := property.Name; {
+		case name == "BEGIN" && (value == "VTODO" || value == "VEVENT"):
+			item = &exporter.Item{List: "active"}
+		case item == nil:
+		case name == "END" && (value == "VTODO" || value == "VEVENT"):
+			key := item.Title
+			if item.Due != nil {
+				key += item.Due.UTC().Format(time.RFC3339)
+			}
+			if !seen[key] {
+				seen[key] = true
+				items = append(items, *item)
+			}
+			item = nil
+		case name == "SUMMARY":
+			item.Title = property.Text()
+		case name == "DESCRIPTION":
+			item.Description = property.Text()
+		case name == "CATEGORIES":
+			item.Tags = append(item.Tags, splitImportTags(property.Text())...)
+		case name == "PRIORITY":
+			priority, _ := strconv.Atoi(value)
+			switch {
+			case priority >= 1 && priority <= 4:
+				item.Priority = "high"
+			case priority == 5:
+				item.Priority = "medium"
+			case priority >= 6 && priority <= 9:
+				item.Priority = "low"
+			}
+		case name == "STATUS":
+			switch strings.ToUpper(value) {
+			case "COMPLETED":
+				item.List = "completed"
+			case "CANCELLED":
+				item.List = "archived"
+			}
+		case name == "DUE" || name == "DTSTART" && item.Due == nil:
+			// Dates without a time are due at the end of the day, like due dates given without a time
+			due, date, err := property.Time(now.Location())
+			if err != nil {
+				return nil, err
+			}
+			if date {
+				due = due.Add(defaultDueHour*time.Hour + defaultDueMinute*time.Minute)
+			}
+			item.Due = &due
+		}
+	}
+	return items, nil
+}
+
+// Keeps the parsed items of a file until the user confirmed which ones to import in the select message
+func (s Todo) savePendingImport(messageId, userId string, items []exporter.Item) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `INSERT INTO todo.pending_import (message, discord_user, items) VALUES ($1, $2, $3)`,
+		messageId,
+		userId,
+		encoded,
+	)
+	return err
+}
+
+// Returns the items of the pending import of the select message of the user, which are empty if there is none
+func (s Todo) getPendingImport(messageId, userId string) ([]exporter.Item, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT items FROM todo.pending_import WHERE message=$1 AND discord_user=$2`, messageId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []exporter.Item{}
+	if rows.Next() {
+		var encoded []byte
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &items); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Deletes the pending imports of select messages which expired by now
+func (s Todo) purgePendingImports(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.pending_import WHERE created_at<$1`, now.Add(-selectMessageTimeout))
+	return err
+}
+
+// Adds the items to the lists of the user in a single transaction and removes the pending import of the select message
+// Returns the IDs of the created tasks and of the journaled operation
+func (s Todo) importItems(userId, messageId string, items []exporter.Item) ([]int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := []int{}
+	changes := []listChange{}
+	for _, item := range items {
+		priority, _ := parsePriority(importPriority(item.Priority))
+		taskId, err := createTask(ctx, tx, userId, todoItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Due:         item.Due,
+			Tags:        item.Tags,
+			Priority:    priority,
+		})
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, 0, err1
+			}
+			return nil, 0, err
+		}
+		list, _ := importList(item.List)
+		if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO todo.%s (discord_user, task) VALUES ($1, $2)`, list),
+			userId,
+			taskId,
+		); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, 0, err1
+			}
+			return nil, 0, err
+		}
+		ids = append(ids, taskId)
+		changes = append(changes, listChange{Task: taskId, To: list})
+	}
+
+	operation, err := recordOperation(tx, userId, "import", changes)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, 0, err1
+		}
+		return nil, 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todo.pending_import WHERE message=$1`, messageId); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, 0, err1
+		}
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+
+	log.Printf("%s Imported %d items for user %s\n", constants.Blue, len(ids), userId)
+	notifyBoards(userId)
+
+	return ids, operation, s.scheduleReminders(userId)
+}
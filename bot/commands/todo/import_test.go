@@ -0,0 +1,196 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/bot/commands/todo/exporter"
+	"github.com/stretchr/testify/assert"
+)
+
+// Items of the golden files of the exporter
+var (
+	importDue   = time.Date(2022, 10, 16, 18, 30, 0, 0, time.UTC)
+	importItems = []exporter.Item{
+		{ID: 1, Title: "Exercise 1", Description: "Hand in on Moodle, then check the solutions; both parts", Due: &importDue, Tags: []string{"ana", "exam"}, Priority: "high", List: "active"},
+		{ID: 2, Title: "Read script, chapter 2", Tags: []string{}, List: "active"},
+		{ID: 3, Title: "Lecture recording", Description: "Watched\non Wednesday, with a description long enough to need folding in iCalendar files", Due: &importDue, Tags: []string{}, Priority: "low", List: "completed"},
+		{ID: 4, Title: "Old exam 2019", Tags: []string{"exam"}, List: "archived"},
+	}
+)
+
+// Returns the items without their IDs, which aren't imported by every format
+func withoutIDs(items []exporter.Item) []exporter.Item {
+	stripped := []exporter.Item{}
+	for _, item := range items {
+		item.ID = 0
+		stripped = append(stripped, item)
+	}
+	return stripped
+}
+
+// Files created by todo export can be imported again
+func TestParseImportExported(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format         string
+		expectedOutput []exporter.Item
+	}{
+		{"csv", withoutIDs(importItems)},
+		{"json", importItems},
+		{"md", withoutIDs(importItems)},
+		// Calendar files only contain the items with a due date
+		{"ics", withoutIDs([]exporter.Item{importItems[0], importItems[2]})},
+	}
+
+	for _, test := range tests {
+		data, err := os.ReadFile(filepath.Join("exporter", "testdata", "export."+test.format+".golden"))
+		assert.Nil(t, err)
+
+		items, err := parseImport("todos."+test.format, data, now)
+		assert.Nil(t, err, test.format)
+		for i := range items {
+			if items[i].Due != nil {
+				due := items[i].Due.UTC()
+				items[i].Due = &due
+			}
+		}
+		assert.Equal(t, test.expectedOutput, items, test.format)
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC) // A Wednesday
+	friday := time.Date(2022, 10, 14, 23, 59, 0, 0, time.UTC)
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	tests := []struct {
+		name           string
+		data           string
+		expectedOutput []exporter.Item
+		expectError    bool
+	}{
+		{
+			"list.md",
+			"Some notes\n* [ ] Exercise 1 #Ana due fri\n  hand in\n- [X] Exercise 0\n",
+			[]exporter.Item{
+				{Title: "Exercise 1", Description: "hand in", Due: &friday, Tags: []string{"ana"}, List: "active"},
+				{Title: "Exercise 0", Tags: []string{}, List: "completed"},
+			},
+			false,
+		},
+		{
+			"items.csv",
+			"Title,Tags,Due\nExercise 1,\"#ana, exam\",fri\n",
+			[]exporter.Item{{Title: "Exercise 1", Due: &friday, Tags: []string{"ana", "exam"}, List: "active"}},
+			false,
+		},
+		{
+			"calendar.ics",
+			"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Exam\nDTSTART;TZID=Europe/Zurich:20221014T090000\nEND:VEVENT\nBEGIN:VTODO\nSUMMARY:Hand in\nDUE;VALUE=DATE:20221014\nPRIORITY:5\nEND:VTODO\nEND:VCALENDAR\n",
+			[]exporter.Item{
+				{Title: "Exam", Due: timePointer(time.Date(2022, 10, 14, 9, 0, 0, 0, zurich).In(time.UTC)), Tags: []string{}, List: "active"},
+				{Title: "Hand in", Due: &friday, Tags: []string{}, Priority: "medium", List: "active"},
+			},
+			false,
+		},
+		{"items.pdf", "", nil, true},
+		{"items.csv", "description\nd\n", nil, true},
+		{"items.csv", "title,priority\nt,urgent\n", nil, true},
+		{"items.json", `[{"title": ""}]`, nil, true},
+		{"items.json", `[{"title": "t", "list": "trash"}]`, nil, true},
+		{"items.json", `{`, nil, true},
+		// Items have to fit into the item modal
+		{"items.json", `[{"title": "` + strings.Repeat("t", maxTitleLength+1) + `"}]`, nil, true},
+		{"items.json", `[{"title": "t", "description": "` + strings.Repeat("d", maxDescriptionLength+1) + `"}]`, nil, true},
+		{"items.json", `[{"title": "t", "tags": ["` + strings.Repeat("t", maxLabelsLength) + `"]}]`, nil, true},
+		{"items.json", `[{"title": "t", "tags": ["two words"]}]`, nil, true},
+		{"items.json", `[{"title": "t", "tags": ["#Exam", "exam"]}]`, []exporter.Item{{Title: "t", Tags: []string{"exam"}, List: "active"}}, false},
+	}
+
+	for _, test := range tests {
+		items, err := parseImport(test.name, []byte(test.data), now)
+		assert.Equal(t, test.expectError, err != nil, test.name)
+		assert.Equal(t, test.expectedOutput, items, test.name)
+	}
+}
+
+func timePointer(t time.Time) *time.Time {
+	return &t
+}
+
+func TestImportItems(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WithArgs("0", "a", "", nil, sqlmock.AnyArg(), priorityHigh, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	dbMock.ExpectExec(`INSERT INTO todo.active`).
+		WithArgs("0", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WithArgs("0", "b", "", nil, sqlmock.AnyArg(), priorityNone, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	dbMock.ExpectExec(`INSERT INTO todo.completed`).
+		WithArgs("0", 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordOperation("0", "import", 3, []int64{5, 6}, []string{"", ""}, []string{"active", "completed"})
+	dbMock.ExpectExec(`DELETE FROM todo.pending_import`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	ids, operation, err := mockTodo.importItems("0", "1", []exporter.Item{
+		{Title: "a", Priority: "high", List: "active"},
+		{Title: "b", List: "completed"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 6}, ids)
+	assert.Equal(t, 3, operation)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestImportItemsRollback(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	dbMock.ExpectExec(`INSERT INTO todo.active`).
+		WithArgs("0", 5).
+		WillReturnError(os.ErrInvalid)
+	dbMock.ExpectRollback()
+
+	_, _, err := mockTodo.importItems("0", "1", []exporter.Item{{Title: "a", List: "active"}})
+
+	assert.Equal(t, os.ErrInvalid, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestPendingImportOptions(t *testing.T) {
+	// Each select message offers the items of its own import
+	dbMock.ExpectQuery(`SELECT items FROM todo.pending_import WHERE message=\$1 AND discord_user=\$2`).
+		WithArgs("1", "0").
+		WillReturnRows(sqlmock.NewRows([]string{"items"}).AddRow([]byte(`[{"title":"a","list":"active"}]`)))
+	dbMock.ExpectQuery(`SELECT timezone FROM todo.discord_user`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow(nil))
+	dbMock.ExpectQuery(`SELECT items FROM todo.pending_import WHERE message=\$1 AND discord_user=\$2`).
+		WithArgs("2", "0").
+		WillReturnRows(sqlmock.NewRows([]string{"items"}).AddRow([]byte(`[{"title":"b","list":"active"},{"title":"c","list":"active"}]`)))
+	dbMock.ExpectQuery(`SELECT timezone FROM todo.discord_user`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow(nil))
+
+	first, err := mockTodo.pendingImportOptions("0", "1")
+	assert.Nil(t, err)
+	second, err := mockTodo.pendingImportOptions("0", "2")
+	assert.Nil(t, err)
+
+	assert.Len(t, first, 1)
+	assert.Equal(t, "a", first[0].Label)
+	assert.Len(t, second, 2)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -8,29 +8,85 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/paginator"
 
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/lib/pq"
+	"github.com/robfig/cron"
 )
 
 type Todo struct {
-	DB              *sql.DB
-	SelectedOptions map[string][]string // Keeps track of items a user selected in a select menu, so we can react on button clicks
+	DB  *sql.DB
+	Bot *discord.Session // Used for messages not caused by a command, such as reminders
 }
 
+// Action taken on the values a user selected in a select message
+type selectAction func(bot *discord.Session, user *discord.User, selected []string, msg *discord.Message) error
+
+// Returns the options the owner of a select message can select from in it
+type selectOptionsFunc func(userId, messageId string) ([]discord.SelectMenuOption, error)
+
+// Select actions and the options to select from by the name of the action, as encoded in the CustomIDs of select messages
+var (
+	selectActions map[string]selectAction
+	selectOptions map[string]selectOptionsFunc
+)
+
+// Paginators of the messages too long to fit into a single message
+var (
+	listPaginator          *paginator.Paginator
+	selectPaginator        *paginator.Paginator
+	subscriptionsPaginator *paginator.Paginator
+	searchPaginator        *paginator.Paginator
+	groupPaginator         *paginator.Paginator
+	repeatPaginator        *paginator.Paginator
+)
+
 type todoItem struct {
-	ID          int
-	Creator     string
-	Title       string
-	Description string
+	ID           int
+	Creator      string
+	Title        string
+	Description  string
+	Due          *time.Time // nil if the item has no due date
+	Tags         []string
+	Priority     int
+	Subscription string // ID of the subscription the task was created for, empty if a user created it
+	Subtasks     int    // Amount of subtasks of the item
+	SubtasksDone int    // Amount of subtasks the user checked off
 }
 
 const (
-	todoEmbedColor     = 0x0BEEF0
-	messageDeleteDelay = 5 * time.Second
-	dbTimeout          = 5 * time.Second
+	todoEmbedColor       = 0x0BEEF0
+	messageDeleteDelay   = 5 * time.Second
+	dbTimeout            = 5 * time.Second
+	selectMessageTimeout = 24 * time.Hour // How long select messages and buttons stay interactive
+	listPageSize         = 10             // Items per page of todo list, keeps the embeds below Discords size limits
+	selectPageSize       = 25             // Options per page of select messages, the maximum Discord allows
+	selectedPrefix       = "Selected: "   // Start of the line of select messages listing the selected values
+	maxSelectedLength    = 1000           // Maximum length of the line listing the selected values, messages can be at most 2000 characters long
+	embedFieldNameLength = 256            // Maximum length of the name of an embed field
+	embedFieldLength     = 1024           // Maximum length of the value of an embed field
+	embedTotalLength     = 6000           // Maximum total length of all texts of an embed
+)
+
+// Prefixes of the CustomIDs of components, followed by the state of the component
+const (
+	selectMenuPrefix    = "todo.select-item-message:"
+	selectSubmitPrefix  = "todo.select-item-message-submit:"
+	selectCancelPrefix  = "todo.select-item-message-cancel:"
+	addButtonPrefix     = "todo.add-button:"
+	addModalPrefix      = "todo.add-button-modal:"
+	editButtonPrefix    = "todo.edit-button:"
+	editModalPrefix     = "todo.edit-modal:"
+	listPagePrefix      = "todo.list-page:"
+	selectPagePrefix    = "todo.select-page:"
+	subscriptionsPrefix = "todo.subscriptions-page:"
+	searchPagePrefix    = "todo.search-page:"
+	groupPagePrefix     = "todo.group-page:"
+	repeatPagePrefix    = "todo.repeat-page:"
 )
 
 type InvalidIDError struct {
@@ -39,13 +95,13 @@ type InvalidIDError struct {
 
 func (e *InvalidIDError) Error() string { return strings.Join(e.InvalidIDs, " ") }
 
-// Parses IDs as they get passed to the command
+// Parses IDs as they get passed to the command, subtasks are given as id.position, e.g. 12.3
 // Turn IDs into format id[,id]+
 func parseIds(rawArr []string) ([]string, error) {
-	re := regexp.MustCompile(`(\d+)[ ]*,?[ ]*`)
+	re := regexp.MustCompile(`(\d+(?:\.\d+)?)[ ]*,?[ ]*`)
 	ids := re.ReplaceAllString(strings.Trim(strings.Join(rawArr, " "), " "), "$1,")
 	ids = ids[:len(ids)-1] // Get rid of trailing comma
-	if match, _ := regexp.MatchString(`^\d+(,\d+)*$`, ids); !match {
+	if match, _ := regexp.MatchString(`^\d+(\.\d+)?(,\d+(\.\d+)?)*$`, ids); !match {
 		return nil, fmt.Errorf("invalid id format")
 	}
 	return deduplicate(strings.Split(ids, ",")), nil
@@ -70,6 +126,16 @@ func deduplicate(arr []string) []string {
 	return newArr
 }
 
+// Returns whether the array contains the item
+func contains(arr []string, item string) bool {
+	for _, setItem := range arr {
+		if item == setItem {
+			return true
+		}
+	}
+	return false
+}
+
 // Checks if a user is present in the database and inserts them if not
 func (s Todo) checkUserPresence(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
@@ -109,17 +175,36 @@ func (s Todo) checkUserPresence(id string) error {
 	return nil
 }
 
-// Creates a new task and returns its id
-func (s Todo) CreateTask(author, title, description string) (int, error) {
+// Runs queries either on the database or within a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Creates a new task with the title, description, due date, tags and priority of item and returns its id
+func (s Todo) CreateTask(author string, item todoItem) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
+	return createTask(ctx, s.DB, author, item)
+}
+
+// Creates a new task like CreateTask, using q to allow creating tasks within a transaction
+func createTask(ctx context.Context, q queryer, author string, item todoItem) (int, error) {
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
 	// Insert task into task table and get its ID
-	rows, err := s.DB.QueryContext(ctx,
-		`INSERT INTO todo.task (creator, title, description) VALUES ($1, $2, $3) RETURNING id`,
+	rows, err := q.QueryContext(ctx,
+		`INSERT INTO todo.task (creator, title, description, due, tags, priority, subscription) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) RETURNING id`,
 		author,
-		title,
-		description,
+		item.Title,
+		item.Description,
+		item.Due,
+		pq.Array(tags),
+		item.Priority,
+		item.Subscription,
 	)
 	if err != nil {
 		return 0, err
@@ -131,97 +216,198 @@ func (s Todo) CreateTask(author, title, description string) (int, error) {
 	rows.Scan(&taskId)
 	rows.Close()
 
-	log.Printf("%s Created new task; creator: %s, title: %s, description: %s\n", constants.Blue, author, title, description)
+	log.Printf("%s Created new task; creator: %s, title: %s, description: %s\n", constants.Blue, author, item.Title, item.Description)
 
 	return taskId, nil
 }
 
 // Returns an array of the active todo items for a given user id
 func (s Todo) getActiveTodos(userId string) ([]todoItem, error) {
-	return s.getUserTODOs(userId, "active")
+	return s.getUserTODOs(userId, listsFilter("active"))
 }
 
 // Returns an array of the completed todo items for a given user id
 func (s Todo) getDoneTodos(userId string) ([]todoItem, error) {
-	return s.getUserTODOs(userId, "completed")
+	return s.getUserTODOs(userId, listsFilter("completed"))
 }
 
 // Returns an array of the archived todo items for a given user id
 func (s Todo) getArchivedTodos(userId string) ([]todoItem, error) {
-	return s.getUserTODOs(userId, "archived")
+	return s.getUserTODOs(userId, listsFilter("archived"))
 }
 
-// Returns an array of all non-archived todo items for a given user id
+// Returns an array of the active and completed todo items for a given user id
+func (s Todo) getArchivableTodos(userId string) ([]todoItem, error) {
+	return s.getUserTODOs(userId, listsFilter("active", "completed"))
+}
+
+// Returns an array of all todo items for a given user id
 func (s Todo) getAllTodos(userId string) ([]todoItem, error) {
-	todo1, err := s.getUserTODOs(userId, "active")
-	if err != nil {
-		return nil, err
-	}
-	todo2, err := s.getUserTODOs(userId, "completed")
-	if err != nil {
-		return nil, err
-	}
-	todo3, err := s.getUserTODOs(userId, "archived")
-	if err != nil {
-		return nil, err
-	}
-	return append(append(todo1, todo2...), todo3...), nil
+	return s.getUserTODOs(userId, listsFilter("active", "completed", "archived"))
 }
 
-// Returns an array of all TODOs of a user from the specified table
-func (s Todo) getUserTODOs(user, table string) ([]todoItem, error) {
+// Returns an array of all TODOs of a user matching the filter, sorted by urgency unless the filter sorts differently
+func (s Todo) getUserTODOs(user string, filter listFilter) ([]todoItem, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
 	items := []todoItem{}
 
-	rows, err := s.DB.QueryContext(ctx,
-		fmt.Sprintf(`SELECT t.* FROM todo.task AS t JOIN todo.%s AS a ON a.task=t.id WHERE a.discord_user=$1`, table),
-		user,
-	)
+	query, args := filter.query(user)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		nextItem := todoItem{}
-		rows.Scan(&nextItem.ID, &nextItem.Creator, &nextItem.Title, &nextItem.Description)
+		var due sql.NullTime
+		var tags pq.StringArray
+		rows.Scan(&nextItem.ID, &nextItem.Creator, &nextItem.Title, &nextItem.Description, &due, &tags, &nextItem.Priority, &nextItem.Subtasks, &nextItem.SubtasksDone)
+		if due.Valid {
+			nextItem.Due = &due.Time
+		}
+		nextItem.Tags = tags
 		items = append(items, nextItem)
 	}
-	log.Printf("%s Got users TODOs; User: %s, Table: %s\n", constants.Blue, user, table)
+	log.Printf("%s Got users TODOs; User: %s, Lists: %v\n", constants.Blue, user, filter.lists)
 
 	return items, nil
 }
 
-// Returns an embed containing all todo items
-func todosToEmbed(todos []todoItem, ctx *discord.MessageCreate) *discord.MessageEmbed {
+// Formats the due date of an item for Discord, which displays it in the timezone of the reader
+func formatDue(due time.Time) string {
+	overdue := ""
+	if due.Before(time.Now()) {
+		overdue = " **Overdue**"
+	}
+	return fmt.Sprintf("Due <t:%d:f> (<t:%d:R>)%s", due.Unix(), due.Unix(), overdue)
+}
+
+// Formats the priority, tags and subtask progress of an item for embeds, empty if it has none of them
+func formatLabels(item todoItem) string {
+	labels := []string{}
+	if item.Priority != priorityNone {
+		labels = append(labels, "**"+priorityLabels[item.Priority]+"**")
+	}
+	for _, tag := range item.Tags {
+		labels = append(labels, "`#"+tag+"`")
+	}
+	if item.Subtasks != 0 {
+		labels = append(labels, fmt.Sprintf("`%d/%d`", item.SubtasksDone, item.Subtasks))
+	}
+	return strings.Join(labels, " ")
+}
+
+// Returns the embed field of an item, with extra labels such as its state shown after its ID
+func itemEmbedField(number int, item todoItem, extra ...string) *discord.MessageEmbedField {
+	value := "`ID: " + fmt.Sprint(item.ID, "`")
+	if labels := strings.Join(append(extra, formatLabels(item)), " "); strings.TrimSpace(labels) != "" {
+		value += " " + strings.TrimSpace(labels)
+	}
+	if item.Due != nil {
+		value += "\n" + formatDue(*item.Due)
+	}
+	if item.Description != "" {
+		value += "\n" + item.Description
+	}
+	return &discord.MessageEmbedField{
+		Name:  shorten(fmt.Sprintf("%d: %s", number, item.Title), embedFieldNameLength),
+		Value: shorten(value, embedFieldLength),
+	}
+}
+
+// Cuts the text off with "..." if it is longer than limit bytes, without splitting characters
+func shorten(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	if limit < 3 {
+		return text[:0]
+	}
+	end := limit - 3
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end] + "..."
+}
+
+// Shortens the values of the fields of the embed, so its texts fit into embedTotalLength
+// The fields share the space evenly, space left over by a field goes to the next ones
+func fitEmbed(embed *discord.MessageEmbed) {
+	remaining := embedTotalLength - len(embed.Title) - len(embed.Description)
+	if embed.Author != nil {
+		remaining -= len(embed.Author.Name)
+	}
+	if embed.Footer != nil {
+		remaining -= len(embed.Footer.Text)
+	}
+	for _, field := range embed.Fields {
+		remaining -= len(field.Name)
+	}
+
+	for i, field := range embed.Fields {
+		field.Value = shorten(field.Value, remaining/(len(embed.Fields)-i))
+		remaining -= len(field.Value)
+	}
+}
+
+// Lists the items, one per line, as long as they fit into an embed field
+func itemLines(items []todoItem) string {
+	return itemLinesWithin(items, embedFieldLength)
+}
+
+// Lists the items, one per line, as long as they fit into limit characters
+func itemLinesWithin(items []todoItem, limit int) string {
+	lines := []string{}
+	length := 0
+	for i, item := range items {
+		line := "`ID: " + fmt.Sprint(item.ID, "` ", item.Title)
+		if item.Due != nil {
+			line += fmt.Sprintf(", due <t:%d:R>", item.Due.Unix())
+		}
+		more := fmt.Sprintf("And %d more", len(items)-i)
+		if length+len(line)+len(more)+2 > limit {
+			lines = append(lines, more)
+			break
+		}
+		lines = append(lines, line)
+		length += len(line) + 1
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns an embed containing the todo items of user in the order given, numbered starting after offset
+func todosToEmbed(todos []todoItem, offset int, user *discord.User) *discord.MessageEmbed {
 	fields := []*discord.MessageEmbedField{}
 
 	for i, item := range todos {
-		value := "`ID: " + fmt.Sprint(item.ID, "` ", item.Description)
-		fields = append(fields, &discord.MessageEmbedField{
-			Name:  fmt.Sprintf("%d: %s", i+1, item.Title),
-			Value: value,
-		})
+		fields = append(fields, itemEmbedField(offset+i+1, item))
 	}
 
 	embed := discord.MessageEmbed{
 		Author: &discord.MessageEmbedAuthor{
-			Name: ctx.Author.Username + "s TODOs",
+			Name: user.Username + "s TODOs",
 		},
 		Color:  todoEmbedColor,
 		Fields: fields,
 		Footer: &discord.MessageEmbedFooter{
-			Text:    "Invoked by " + ctx.Author.Username,
-			IconURL: ctx.Author.AvatarURL(""),
+			Text:    "Invoked by " + user.Username,
+			IconURL: user.AvatarURL(""),
 		},
 	}
+	fitEmbed(&embed)
 	return &embed
 }
 
-// Changes the items status from "from" to "to"
+// Changes the items status from "from" to "to", returns the ID of the journaled operation
 // Returns an InvalidIDError if invalid IDs were supplied
-func (s Todo) changeItemsStatus(userId string, itemIds []string, from, to string) error {
+func (s Todo) changeItemsStatus(userId string, itemIds []string, from, to string) (int, error) {
+	if err := checkNoSubtaskIds(itemIds); err != nil {
+		return 0, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
@@ -231,7 +417,7 @@ func (s Todo) changeItemsStatus(userId string, itemIds []string, from, to string
 		pq.Array(itemIds),
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// For checking for invalid IDs
@@ -251,90 +437,156 @@ func (s Todo) changeItemsStatus(userId string, itemIds []string, from, to string
 
 	// Check for wrong ID supplied
 	if len(idsCopy) != 0 {
-		return &InvalidIDError{idsCopy}
+		return 0, &InvalidIDError{idsCopy}
 	}
 
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Delete active items
-	_, err = tx.Exec(fmt.Sprintf(`DELETE FROM todo.%s WHERE discord_user=$1 AND task=ANY($2)`, from),
-		userId,
-		pq.Array(itemIds),
-	)
+	moved, err := moveItems(tx, userId, itemIds, from, to)
 	if err != nil {
 		log.Println(constants.Red, "Couldn't change item status", err)
 		if err1 := tx.Rollback(); err1 != nil {
-			return err1
+			return 0, err1
 		}
-		return err
+		return 0, err
 	}
 
-	// Put all items into "to"
-	_, err = s.DB.Exec(fmt.Sprintf(`INSERT INTO todo.%s (discord_user, task) VALUES ($1, UNNEST($2::INTEGER[]))`, to),
-		userId,
-		pq.Array(itemIds),
-	)
+	operation, err := recordOperation(tx, userId, "move", moveChanges(moved, from, to))
 	if err != nil {
 		if err1 := tx.Rollback(); err1 != nil {
-			return err1
+			return 0, err1
 		}
-		return err
+		return 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return 0, err
 	}
 
 	log.Printf("%s Changed users %s items %v from %s to %s\n", constants.Blue, userId, itemIds, from, to)
-	return nil
+	notifyBoards(userId)
+	return operation, nil
 }
 
-// Sends a message with the option for the user to select multiple items at once
-// If the user presses the green button, submit gets called
-// If the user presses the red button, cancel gets called
-// Items has to be of non-zero length
-func (s *Todo) sendItemSelectMessage(bot *discord.Session, ctx *discord.MessageCreate, items []todoItem, content, placeholder string, submit, cancel func([]string, *discord.Message) error) error {
-	interactionId := "todo.select-item-message:" + ctx.Message.ID
+// Moves the items of the user which are still in the list "from" to the list "to", returns the IDs of the moved items
+// The caller journals the move, see moveChanges
+func moveItems(tx *sql.Tx, userId string, itemIds []string, from, to string) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf(`DELETE FROM todo.%s WHERE discord_user=$1 AND task=ANY($2) RETURNING task`, from),
+		userId,
+		pq.Array(itemIds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	moved := []string{}
+	for rows.Next() {
+		var task string
+		rows.Scan(&task)
+		moved = append(moved, task)
+	}
+	rows.Close()
+	if len(moved) == 0 {
+		return moved, nil
+	}
 
-	if len(items) == 0 {
-		return fmt.Errorf("options array cannot be of length zero")
+	if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO todo.%s (discord_user, task) VALUES ($1, UNNEST($2::INTEGER[]))`, to),
+		userId,
+		pq.Array(moved),
+	); err != nil {
+		return nil, err
 	}
 
-	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	return moved, nil
+}
 
+// Turns todo items into options for a select message, with their IDs as values
+func itemsToOptions(items []todoItem) []discord.SelectMenuOption {
 	options := []discord.SelectMenuOption{}
 	for _, item := range items {
-		label := item.Title
-		description := item.Description
+		options = append(options, selectOption(fmt.Sprint(item.ID), item.Title, item.Description))
+	}
+	return options
+}
 
-		if len(label) > 100 {
-			label = label[:97] + "..."
-		}
-		if len(description) > 100 {
-			description = description[:97] + "..."
+// Creates an option for a select message, truncating label and description to the allowed length
+func selectOption(value, label, description string) discord.SelectMenuOption {
+	return discord.SelectMenuOption{
+		Label:       shorten(label, 100),
+		Value:       value,
+		Description: shorten(description, 100),
+	}
+}
+
+// Sends a message with the option for the user to select multiple items at once
+// If the user presses the green button, the select action with the name action gets called
+// The owner and action are encoded in the CustomIDs and the selection is kept in the database,
+// so the message stays usable across restarts until it expires
+// Options are split into pages of selectPageSize and have to be the ones returned by the options function of the action,
+// the ones marked as default are selected initially
+// Options has to be of non-zero length
+func (s Todo) sendItemSelectMessage(bot *discord.Session, ctx *discord.MessageCreate, options []discord.SelectMenuOption, content, placeholder, action string) error {
+	_, err := s.sendSelectMessage(bot, ctx, options, content, placeholder, action)
+	return err
+}
+
+// Sends a select message like sendItemSelectMessage and returns it, for actions whose options depend on the message
+func (s Todo) sendSelectMessage(bot *discord.Session, ctx *discord.MessageCreate, options []discord.SelectMenuOption, content, placeholder, action string) (*discord.Message, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("options array cannot be of length zero")
+	}
+
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+
+	// Options marked as default start out selected
+	selected := []string{}
+	for _, option := range options {
+		if option.Default {
+			selected = append(selected, option.Value)
 		}
+	}
 
-		options = append(options, discord.SelectMenuOption{
-			Label:       label,
-			Value:       fmt.Sprintf("%d", item.ID),
-			Description: description,
-		})
+	page := selectPage(options, selected, content, placeholder, action, ctx.Author.ID, 0)
+	msg, err := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Content:    page.Content,
+		Components: selectPaginator.Components(page, ctx.Author.ID, 0),
+	})
+	if err != nil || len(selected) == 0 {
+		return msg, err
 	}
 
-	msg, _ := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
-		Content: content,
+	_, err = s.updateSelection(msg.ID, ctx.Author.ID, nil, selected)
+	return msg, err
+}
+
+// Returns the page of a select message, with the selected values marked and listed below the content
+func selectPage(options []discord.SelectMenuOption, selected []string, content, placeholder, action, owner string, page int) paginator.Page {
+	start, end := paginator.Bounds(len(options), selectPageSize, page)
+	pageOptions := append([]discord.SelectMenuOption{}, options[start:end]...)
+	for i := range pageOptions {
+		pageOptions[i].Default = false
+		for _, value := range selected {
+			if pageOptions[i].Value == value {
+				pageOptions[i].Default = true
+				break
+			}
+		}
+	}
+
+	state := action + ":" + owner
+	return paginator.Page{
+		Content: content + formatSelected(selected),
 		Components: []discord.MessageComponent{
 			discord.ActionsRow{
 				Components: []discord.MessageComponent{
 					discord.SelectMenu{
-						CustomID:    interactionId,
+						CustomID:    selectMenuPrefix + state,
 						Placeholder: placeholder,
 						MinValues:   new(int), // 0
-						MaxValues:   len(items),
-						Options:     options,
+						MaxValues:   len(pageOptions),
+						Options:     pageOptions,
 					},
 				},
 			},
@@ -343,86 +595,365 @@ func (s *Todo) sendItemSelectMessage(bot *discord.Session, ctx *discord.MessageC
 					discord.Button{
 						Label:    constants.Emojis["success"],
 						Style:    discord.SuccessButton,
-						CustomID: "todo.select-item-message-submit:" + ctx.Message.ID,
+						CustomID: selectSubmitPrefix + state,
 					},
 					discord.Button{
 						Label:    constants.Emojis["fail"],
 						Style:    discord.DangerButton,
-						CustomID: "todo.select-item-message-cancel:" + ctx.Message.ID,
+						CustomID: selectCancelPrefix + state,
 					},
 				},
 			},
 		},
-	})
+		Pages: paginator.Pages(len(options), selectPageSize),
+	}
+}
 
-	// Callback for select menu
-	constants.Handlers.MessageComponents[interactionId] = func(interaction *discord.Interaction) error {
-		bot.InteractionRespond(interaction, &discord.InteractionResponse{
-			Type: discord.InteractionResponseDeferredMessageUpdate,
-		})
+// Renders a page of a select message, taking the options from the action and everything else from the message
+func (s Todo) renderSelectPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	menu := findSelectMenu(msg)
+	if menu == nil {
+		return paginator.Page{}, fmt.Errorf("select message %s has no select menu", msg.ID)
+	}
+	action, owner := parseSelectState(menu.CustomID)
+
+	optionsFunc, found := selectOptions[action]
+	if !found {
+		return paginator.Page{}, fmt.Errorf("unknown select action %s", action)
+	}
+	options, err := optionsFunc(owner, msg.ID)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+	if len(options) == 0 {
+		return paginator.Page{Content: "There is nothing left to select."}, nil
+	}
+
+	selected, err := s.getSelection(msg.ID)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+	return selectPage(options, selected, stripSelected(msg.Content), menu.Placeholder, action, owner, page), nil
+}
 
-		user := interaction.User
-		if user == nil {
-			user = interaction.Member.User
+// Returns the select menu of a select message, nil if it has none
+func findSelectMenu(msg *discord.Message) *discord.SelectMenu {
+	for _, row := range msg.Components {
+		row, ok := row.(*discord.ActionsRow)
+		if !ok {
+			continue
 		}
+		for _, component := range row.Components {
+			if menu, ok := component.(*discord.SelectMenu); ok {
+				return menu
+			}
+		}
+	}
+	return nil
+}
 
-		if user.ID != ctx.Author.ID {
-			return nil
+// Formats the line listing the selected values of a select message, empty if nothing is selected
+// Only as many values as fit into maxSelectedLength are listed
+func formatSelected(selected []string) string {
+	if len(selected) == 0 {
+		return ""
+	}
+	values := []string{}
+	length := len(selectedPrefix)
+	for i, value := range selected {
+		value = "`" + value + "`"
+		more := fmt.Sprintf("and %d more", len(selected)-i)
+		if length+len(value)+len(more)+4 > maxSelectedLength {
+			values = append(values, more)
+			break
 		}
+		values = append(values, value)
+		length += len(value) + 2
+	}
+	return "\n" + selectedPrefix + strings.Join(values, ", ")
+}
 
-		s.SelectedOptions[interactionId] = interaction.MessageComponentData().Values
-		return nil
+// Returns the content of a select message without the line listing the selected values
+func stripSelected(content string) string {
+	if index := strings.LastIndex(content, "\n"+selectedPrefix); index != -1 {
+		return content[:index]
 	}
+	return content
+}
 
-	// Callback for submit button
-	constants.Handlers.MessageComponents["todo.select-item-message-submit:"+ctx.Message.ID] = func(interaction *discord.Interaction) error {
-		bot.InteractionRespond(interaction, &discord.InteractionResponse{
-			Type: discord.InteractionResponseDeferredMessageUpdate,
-		})
+// Replaces the selected values of the select message among the ones on its current page and returns the whole selection
+func (s Todo) updateSelection(messageId, userId string, onPage, selected []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	all := []string{}
+	if err := s.DB.QueryRowContext(ctx, `INSERT INTO todo.selection (message, discord_user, selected) VALUES ($1, $2, $4)
+		ON CONFLICT (message) DO UPDATE SET selected=ARRAY(
+			SELECT v.value FROM UNNEST(todo.selection.selected) WITH ORDINALITY AS v(value, position)
+			WHERE v.value <> ALL($3) ORDER BY v.position
+		) || $4::TEXT[], updated_at=now()
+		RETURNING selected`,
+		messageId,
+		userId,
+		pq.Array(onPage),
+		pq.Array(selected),
+	).Scan(pq.Array(&all)); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Returns the selected values of the select message
+func (s Todo) getSelection(messageId string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	selected := []string{}
+	err := s.DB.QueryRowContext(ctx, `SELECT selected FROM todo.selection WHERE message=$1`, messageId).Scan(pq.Array(&selected))
+	if err == sql.ErrNoRows {
+		return []string{}, nil
+	}
+	return selected, err
+}
+
+// Forgets the selection of the select message
+func (s Todo) deleteSelection(messageId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.selection WHERE message=$1`, messageId)
+	return err
+}
+
+// Deletes the selections of select messages which expired by now
+func (s Todo) purgeSelections(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.selection WHERE updated_at<$1`, now.Add(-selectMessageTimeout))
+	return err
+}
+
+// Registers the handlers of all components whose state is encoded in their CustomIDs
+// As they don't depend on the message that created them, they keep working after restarts
+func (s Todo) InitialiseHandlers() {
+	selectActions = map[string]selectAction{
+		"done":        s.doneSelected,
+		"delete":      s.deleteSelected,
+		"archive":     s.archiveSelected,
+		"edit":        s.editSelected,
+		"subscribe":   s.subscribeSelected,
+		"unsubscribe": s.unsubscribeSelected,
+		// Search results may be in any list, only act on the ones where it is possible
+		"search-done":    s.searchAction(s.doneSelected, "active"),
+		"search-archive": s.searchAction(s.archiveSelected, "active", "completed"),
+		"search-delete":  s.deleteSelected,
+		"import":         s.importSelected,
+	}
+	selectOptions = map[string]selectOptionsFunc{
+		"done":        s.itemOptions(s.getActiveTodos),
+		"delete":      s.itemOptions(s.getAllTodos),
+		"archive":     s.itemOptions(s.getArchivableTodos),
+		"edit":        s.itemOptions(s.getAllTodos),
+		"subscribe":   ownerOptions(s.subscribeOptions),
+		"unsubscribe": ownerOptions(s.unsubscribeOptions),
+		"import":      s.pendingImportOptions,
+	}
+
+	listPaginator = paginator.New(listPagePrefix, selectMessageTimeout, s.renderListPage)
+	selectPaginator = paginator.New(selectPagePrefix, selectMessageTimeout, s.renderSelectPage)
+	subscriptionsPaginator = paginator.New(subscriptionsPrefix, selectMessageTimeout, s.renderSubscriptionsPage)
+	searchPaginator = paginator.New(searchPagePrefix, selectMessageTimeout, s.renderSearchPage)
+	groupPaginator = paginator.New(groupPagePrefix, selectMessageTimeout, s.renderGroupPage)
+	repeatPaginator = paginator.New(repeatPagePrefix, selectMessageTimeout, s.renderRepeatPage)
+	for _, p := range []*paginator.Paginator{listPaginator, selectPaginator, subscriptionsPaginator, searchPaginator, groupPaginator, repeatPaginator} {
+		p.Register(constants.Handlers.MessageComponents, constants.Handlers.ModalSubmit)
+	}
 
-		user := interaction.User
-		if user == nil {
-			user = interaction.Member.User
+	constants.Handlers.MessageComponents.RegisterPrefix(selectMenuPrefix, s.handleSelectMenu, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(selectSubmitPrefix, s.handleSelectSubmit, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(selectCancelPrefix, s.handleSelectCancel, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(addButtonPrefix, s.handleAddButton, 0, nil)
+	constants.Handlers.ModalSubmit.RegisterPrefix(addModalPrefix, s.handleAddModal, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(editButtonPrefix, s.handleEditButton, 0, nil)
+	constants.Handlers.ModalSubmit.RegisterPrefix(editModalPrefix, s.handleEditModal, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(groupJoinPrefix, s.handleGroupJoin, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(undoButtonPrefix, s.handleUndoButton, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(timetableConfirmPrefix, s.handleTimetableConfirm, 0, nil)
+	constants.Handlers.MessageComponents.RegisterPrefix(timetableCancelPrefix, s.handleTimetableCancel, 0, nil)
+
+	c.Schedule(cron.Every(time.Hour), cron.FuncJob(func() {
+		if err := s.purgeSelections(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to delete expired selections:", err)
+		}
+		if err := s.purgePendingImports(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to delete expired pending imports:", err)
 		}
+		if err := s.purgePendingTimetables(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to delete expired pending timetables:", err)
+		}
+	}))
+	c.Start()
+}
 
-		if user.ID != ctx.Author.ID {
-			return nil
+// Returns an options function offering the items returned by getItems
+func (s Todo) itemOptions(getItems func(userId string) ([]todoItem, error)) selectOptionsFunc {
+	return func(userId, _ string) ([]discord.SelectMenuOption, error) {
+		items, err := getItems(userId)
+		if err != nil {
+			return nil, err
 		}
+		return itemsToOptions(items), nil
+	}
+}
 
-		err := submit(s.SelectedOptions[interactionId], msg)
+// Returns an options function offering the options of the owner, the same for all of their select messages
+func ownerOptions(getOptions func(userId string) ([]discord.SelectMenuOption, error)) selectOptionsFunc {
+	return func(userId, _ string) ([]discord.SelectMenuOption, error) {
+		return getOptions(userId)
+	}
+}
 
-		delete(s.SelectedOptions, interactionId)
-		delete(constants.Handlers.MessageComponents, interactionId)
-		delete(constants.Handlers.MessageComponents, "todo.done-message-submit:"+ctx.Message.ID)
-		delete(constants.Handlers.MessageComponents, "todo.done-message-cancel:"+ctx.Message.ID)
+// Returns the user who created the interaction
+func interactionUser(interaction *discord.Interaction) *discord.User {
+	if interaction.User != nil {
+		return interaction.User
+	}
+	return interaction.Member.User
+}
 
-		return err
+// Splits the state encoded in the CustomID of a select message component into its action and owner
+func parseSelectState(customId string) (action, owner string) {
+	split := strings.Split(customId, ":")
+	if len(split) != 3 {
+		return "", ""
+	}
+	return split[1], split[2]
+}
+
+// Returns whether the message of the interaction is too old to be interacted with
+func isExpired(interaction *discord.Interaction) bool {
+	return interaction.Message == nil || time.Since(interaction.Message.Timestamp) > selectMessageTimeout
+}
+
+// Callback for the select menu, remembers the selection by marking the selected options as default
+// and storing the selection of all pages, which the content of the message lists
+func (s Todo) handleSelectMenu(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
 	}
 
-	// Callback for cancel button
-	constants.Handlers.MessageComponents["todo.select-item-message-cancel:"+ctx.Message.ID] = func(interaction *discord.Interaction) error {
-		bot.InteractionRespond(interaction, &discord.InteractionResponse{
+	_, owner := parseSelectState(interaction.MessageComponentData().CustomID)
+	if interactionUser(interaction).ID != owner {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
 			Type: discord.InteractionResponseDeferredMessageUpdate,
 		})
+	}
 
-		user := interaction.User
-		if user == nil {
-			user = interaction.Member.User
-		}
+	menu := findSelectMenu(interaction.Message)
+	if menu == nil {
+		return fmt.Errorf("select message %s has no select menu", interaction.Message.ID)
+	}
 
-		if user.ID != ctx.Author.ID {
-			return nil
+	// Keep the selection of the other pages and replace the one of this page
+	onPage := []string{}
+	for _, option := range menu.Options {
+		onPage = append(onPage, option.Value)
+	}
+	selected, err := s.updateSelection(interaction.Message.ID, owner, onPage, interaction.MessageComponentData().Values)
+	if err != nil {
+		return err
+	}
+
+	for i := range menu.Options {
+		menu.Options[i].Default = false
+		for _, value := range interaction.MessageComponentData().Values {
+			if menu.Options[i].Value == value {
+				menu.Options[i].Default = true
+				break
+			}
 		}
+	}
 
-		err := cancel(s.SelectedOptions[interactionId], msg)
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseUpdateMessage,
+		Data: &discord.InteractionResponseData{
+			Content:    stripSelected(interaction.Message.Content) + formatSelected(selected),
+			Components: interaction.Message.Components,
+		},
+	})
+}
+
+// Callback for the submit button of a select message, calls the select action with the selected values
+func (s Todo) handleSelectSubmit(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
 
-		delete(s.SelectedOptions, interactionId)
-		delete(constants.Handlers.MessageComponents, interactionId)
-		delete(constants.Handlers.MessageComponents, "todo.done-message-submit:"+ctx.Message.ID)
-		delete(constants.Handlers.MessageComponents, "todo.done-message-cancel:"+ctx.Message.ID)
+	bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredMessageUpdate,
+	})
+
+	action, owner := parseSelectState(interaction.MessageComponentData().CustomID)
+	user := interactionUser(interaction)
+	if user.ID != owner {
+		return nil
+	}
 
+	fun, found := selectActions[action]
+	if !found {
+		return fmt.Errorf("unknown select action %s", action)
+	}
+
+	// The selection isn't needed anymore once the action got it
+	selected, err := s.getSelection(interaction.Message.ID)
+	if err != nil {
 		return err
 	}
+	if err := s.deleteSelection(interaction.Message.ID); err != nil {
+		return err
+	}
+
+	return fun(bot, user, selected, interaction.Message)
+}
 
+// Callback for the cancel button of a select message
+func (s Todo) handleSelectCancel(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredMessageUpdate,
+	})
+
+	_, owner := parseSelectState(interaction.MessageComponentData().CustomID)
+	if interactionUser(interaction).ID != owner {
+		return nil
+	}
+
+	if err := s.deleteSelection(interaction.Message.ID); err != nil {
+		return err
+	}
+	finishSelectMessage(bot, interaction.Message, "Cancelled")
 	return nil
 }
+
+// Replaces the components of a select message with the content and deletes it after a delay
+// The components replacing them, such as an undo button, keep the message around for longer
+func finishSelectMessage(bot *discord.Session, msg *discord.Message, content string, components ...discord.MessageComponent) {
+	delay := undoMessageDelay
+	if len(components) == 0 {
+		components = []discord.MessageComponent{}
+		delay = messageDeleteDelay
+	}
+	bot.ChannelMessageEditComplex(&discord.MessageEdit{
+		Content:    &content,
+		Components: components,
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+	})
+
+	time.Sleep(delay)
+	bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
+}
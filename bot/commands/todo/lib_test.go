@@ -3,9 +3,12 @@ package todo
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	discord "github.com/bwmarrin/discordgo"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,12 +52,21 @@ func TestParseIds(t *testing.T) {
 		{[]string{"1,", "", "2"}, []string{"1", "2"}, nil},
 		{[]string{"1", ",", "2"}, []string{"1", "2"}, nil},
 		{[]string{"1", "", ",", "2"}, []string{"1", "2"}, nil},
+		{[]string{"12.3"}, []string{"12.3"}, nil},
+		{[]string{"12.3", "12.3"}, []string{"12.3"}, nil},
+		{[]string{"12.3,", "12.4"}, []string{"12.3", "12.4"}, nil},
+		{[]string{"12", "12.3"}, []string{"12", "12.3"}, nil},
+		{[]string{"1.1", ",2"}, []string{"1.1", "2"}, nil},
 
 		{[]string{"a"}, nil, fmt.Errorf("invalid id format")},
 		{[]string{"1,", ",2"}, nil, fmt.Errorf("invalid id format")},
 		{[]string{",1", "2"}, nil, fmt.Errorf("invalid id format")},
 		{[]string{"1", ",,", "2"}, nil, fmt.Errorf("invalid id format")},
 		{[]string{"1", ",", "", ",", "2"}, nil, fmt.Errorf("invalid id format")},
+		{[]string{"12."}, nil, fmt.Errorf("invalid id format")},
+		{[]string{".3"}, nil, fmt.Errorf("invalid id format")},
+		{[]string{"12.3.4"}, nil, fmt.Errorf("invalid id format")},
+		{[]string{"12.a"}, nil, fmt.Errorf("invalid id format")},
 	}
 
 	for _, test := range tests {
@@ -117,11 +129,19 @@ func TestCheckUserPresenceNotInDB(t *testing.T) {
 func TestCreateTask(t *testing.T) {
 	taskId := 1
 
+	due := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
 	dbMock.ExpectQuery(`INSERT INTO todo.task`).
-		WithArgs("taskAuthor", "taskTitle", "taskDescription").
+		WithArgs("taskAuthor", "taskTitle", "taskDescription", due, pq.Array([]string{"exam"}), priorityHigh, "").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(taskId))
 
-	result, err := mockTodo.CreateTask("taskAuthor", "taskTitle", "taskDescription")
+	result, err := mockTodo.CreateTask("taskAuthor", todoItem{
+		Title:       "taskTitle",
+		Description: "taskDescription",
+		Due:         &due,
+		Tags:        []string{"exam"},
+		Priority:    priorityHigh,
+	})
 
 	assert.Equal(t, taskId, result)
 	assert.Nil(t, err)
@@ -129,11 +149,11 @@ func TestCreateTask(t *testing.T) {
 }
 
 func TestGetUserTODOsEmpty(t *testing.T) {
-	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN todo.x AS a`).
-		WithArgs("userId").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "creator", "title", "description"}))
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN (.+) WHERE l.discord_user=\$1 AND l.list=ANY\(\$2\) ORDER BY`).
+		WithArgs("userId", pq.Array([]string{"x"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "creator", "title", "description", "due", "tags", "priority", "subtasks", "subtasks_done"}))
 
-	items, err := mockTodo.getUserTODOs("userId", "x")
+	items, err := mockTodo.getUserTODOs("userId", listsFilter("x"))
 
 	assert.Empty(t, items)
 	assert.Nil(t, err)
@@ -141,13 +161,15 @@ func TestGetUserTODOsEmpty(t *testing.T) {
 }
 
 func TestGetUserTODOsNonEmpty(t *testing.T) {
-	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN todo.x AS a`).
-		WithArgs("userId").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "creator", "title", "description"}).AddRow(0, "c0", "t0", "d0").AddRow(1, "c1", "t1", "d1"))
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.task AS t JOIN (.+) WHERE l.discord_user=\$1 AND l.list=ANY\(\$2\) ORDER BY`).
+		WithArgs("userId", pq.Array([]string{"x"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "creator", "title", "description", "due", "tags", "priority", "subtasks", "subtasks_done"}).
+			AddRow(0, "c0", "t0", "d0", nil, "{}", priorityNone, 0, 0).
+			AddRow(1, "c1", "t1", "d1", testNow, "{exam,ana}", priorityHigh, 5, 2))
 
-	items, err := mockTodo.getUserTODOs("userId", "x")
+	items, err := mockTodo.getUserTODOs("userId", listsFilter("x"))
 
-	assert.Equal(t, []todoItem{{0, "c0", "t0", "d0"}, {1, "c1", "t1", "d1"}}, items)
+	assert.Equal(t, []todoItem{{0, "c0", "t0", "d0", nil, []string{}, priorityNone, "", 0, 0}, {1, "c1", "t1", "d1", &testNow, []string{"exam", "ana"}, priorityHigh, "", 5, 2}}, items)
 	assert.Nil(t, err)
 	assert.Nil(t, dbMock.ExpectationsWereMet())
 }
@@ -159,19 +181,23 @@ func TestChangeItemStatus(t *testing.T) {
 
 	dbMock.ExpectBegin()
 
-	dbMock.ExpectExec(`DELETE FROM todo.x`).
+	dbMock.ExpectQuery(`DELETE FROM todo.x (.+) RETURNING task`).
 		WithArgs("0", pq.Array([]string{"1", "2"})).
-		WillReturnResult(sqlmock.NewResult(1, 2))
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("1").AddRow("2"))
 
 	dbMock.ExpectExec(`INSERT INTO todo.y`).
 		WithArgs("0", pq.Array([]string{"1", "2"})).
 		WillReturnResult(sqlmock.NewResult(1, 2))
 
+	expectRecordOperation("0", "move", 7, []int64{1, 2}, []string{"x", "x"}, []string{"y", "y"})
+
 	dbMock.ExpectCommit()
 
-	err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
+	operation, err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
 
+	assert.Equal(t, 7, operation)
 	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
 }
 
 func TestChangeItemStatusWrongIDs(t *testing.T) {
@@ -180,7 +206,7 @@ func TestChangeItemStatusWrongIDs(t *testing.T) {
 		WithArgs("0", pq.Array([]string{"1", "2"})).
 		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("1"))
 
-	err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
+	_, err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
 
 	assert.IsType(t, &InvalidIDError{}, err)
 }
@@ -190,7 +216,130 @@ func TestChangeItemStatusAllWrongIDs(t *testing.T) {
 		WithArgs("0", pq.Array([]string{"1", "2"})).
 		WillReturnRows(sqlmock.NewRows([]string{"task"}))
 
-	err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
+	_, err := mockTodo.changeItemsStatus("0", []string{"1", "2"}, "x", "y")
 
 	assert.IsType(t, &InvalidIDError{}, err)
 }
+
+func TestParseSelectState(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedAction string
+		expectedOwner  string
+	}{
+		{selectMenuPrefix + "done:123", "done", "123"},
+		{selectSubmitPrefix + "unsubscribe:456", "unsubscribe", "456"},
+		{selectCancelPrefix + "archive:", "archive", ""},
+
+		{"todo.select-item-message:123", "", ""},
+		{"todo.select-item-message:done:123:4", "", ""},
+	}
+
+	for _, test := range tests {
+		action, owner := parseSelectState(test.input)
+		assert.Equal(t, test.expectedAction, action)
+		assert.Equal(t, test.expectedOwner, owner)
+	}
+}
+
+func TestStripSelected(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedContent string
+	}{
+		{"Select items", "Select items"},
+		{"Select items\nSelected: `1`", "Select items"},
+		{"Select\nitems\nSelected: `1`, `401-0212-16L`", "Select\nitems"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedContent, stripSelected(test.input))
+	}
+}
+
+func TestFormatSelected(t *testing.T) {
+	assert.Equal(t, "", formatSelected([]string{}))
+	assert.Equal(t, "\nSelected: `1`, `401-0212-16L`", formatSelected([]string{"1", "401-0212-16L"}))
+
+	// Large selections are only listed partially
+	selected := []string{}
+	for i := 0; i < 500; i++ {
+		selected = append(selected, fmt.Sprint(i))
+	}
+	line := formatSelected(selected)
+	assert.LessOrEqual(t, len(line), maxSelectedLength)
+	assert.True(t, strings.HasSuffix(line, "more"))
+	assert.Equal(t, "Select items", stripSelected("Select items"+line))
+}
+
+func TestUpdateSelection(t *testing.T) {
+	dbMock.ExpectQuery(`INSERT INTO todo.selection (.+) ON CONFLICT \(message\) DO UPDATE`).
+		WithArgs("42", "0", pq.Array([]string{"1", "2"}), pq.Array([]string{"2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"selected"}).AddRow("{26,2}"))
+
+	selected, err := mockTodo.updateSelection("42", "0", []string{"1", "2"}, []string{"2"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"26", "2"}, selected)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetSelectionNone(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT selected FROM todo.selection`).
+		WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"selected"}))
+
+	selected, err := mockTodo.getSelection("42")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, selected)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSelectPage(t *testing.T) {
+	options := []discord.SelectMenuOption{}
+	for i := 0; i < 30; i++ {
+		options = append(options, selectOption(fmt.Sprint(i), fmt.Sprint("Item ", i), ""))
+	}
+
+	page := selectPage(options, []string{"1", "26"}, "Select items", "Items", "done", "owner", 1)
+
+	assert.Equal(t, 2, page.Pages)
+	assert.Equal(t, "Select items\nSelected: `1`, `26`", page.Content)
+	menu := page.Components[0].(discord.ActionsRow).Components[0].(discord.SelectMenu)
+	assert.Equal(t, selectMenuPrefix+"done:owner", menu.CustomID)
+	assert.Len(t, menu.Options, 5)
+	assert.Equal(t, 5, menu.MaxValues)
+	for _, option := range menu.Options {
+		assert.Equal(t, option.Value == "26", option.Default)
+	}
+	// The options passed in are left untouched
+	assert.False(t, options[26].Default)
+}
+
+func TestShorten(t *testing.T) {
+	assert.Equal(t, "short", shorten("short", 5))
+	assert.Equal(t, "lo...", shorten("longer", 5))
+	// Characters aren't split
+	assert.Equal(t, "a...", shorten("aääb", 5))
+	assert.Equal(t, "", shorten("longer", 2))
+}
+
+func TestTodosToEmbedLimits(t *testing.T) {
+	todos := []todoItem{}
+	for i := 0; i < listPageSize; i++ {
+		todos = append(todos, todoItem{ID: i, Title: strings.Repeat("t", 300), Description: strings.Repeat("d", 2000)})
+	}
+
+	embed := todosToEmbed(todos, 0, &discord.User{ID: "0", Username: "user"})
+
+	length := len(embed.Author.Name) + len(embed.Footer.Text)
+	for _, field := range embed.Fields {
+		length += len(field.Name) + len(field.Value)
+		assert.LessOrEqual(t, len(field.Name), embedFieldNameLength)
+		assert.LessOrEqual(t, len(field.Value), embedFieldLength)
+		assert.True(t, strings.HasSuffix(field.Value, "..."))
+	}
+	assert.LessOrEqual(t, length, embedTotalLength)
+	assert.Len(t, embed.Fields, listPageSize)
+}
@@ -1,48 +1,88 @@
 package todo
 
 import (
+	"strings"
+
+	"github.com/DominicWuest/Alphie/bot/paginator"
+
 	discord "github.com/bwmarrin/discordgo"
 	_ "github.com/lib/pq"
 )
 
+// Start of the description of list embeds showing the filter the list was invoked with
+const filterPrefix = "Filter: "
+
 func (s Todo) listHelp() string {
-	return "Usage: `todo list [all|active|archived|done]`"
+	return "Usage: `todo list [all|active|archived|done] [#tag..] [prio<op><priority>] [due<op><date>] [sort:due|prio|title|id]`\n" +
+		"E.g. `todo list #exam prio>=high due<7d sort:due` lists the active items tagged with `#exam` of high priority which are due within a week.\n" +
+		"Priorities are `none`, `low`, `medium` and `high`, comparisons are `<`, `<=`, `=`, `>=` and `>`."
 }
 
 func (s Todo) List(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
 	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
 		return err
 	}
-	var todos []todoItem
-	var err error
-	if len(args) == 0 {
-		todos, err = s.getActiveTodos(ctx.Author.ID)
-	} else if len(args) > 1 {
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.listHelp())
+		return nil
+	}
+
+	now, err := s.userNow(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	filter, err := parseListFilter(args, now)
+	if err != nil {
 		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.listHelp())
 		return nil
-	} else {
-		switch args[0] {
-		case "all":
-			todos, err = s.getAllTodos(ctx.Author.ID)
-		case "active":
-			todos, err = s.getActiveTodos(ctx.Author.ID)
-		case "archive", "archived":
-			todos, err = s.getArchivedTodos(ctx.Author.ID)
-		case "done", "checked", "check":
-			todos, err = s.getDoneTodos(ctx.Author.ID)
-		case "help":
-			bot.ChannelMessageSend(ctx.ChannelID, s.listHelp())
-			return nil
-		default:
-			bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.listHelp())
-			return nil
-		}
 	}
 
+	page, err := s.listPage(ctx.Author, strings.Join(args, " "), filter, 0)
 	if err != nil {
 		return err
 	} else {
-		bot.ChannelMessageSendEmbed(ctx.ChannelID, todosToEmbed(todos, ctx))
+		bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+			Embeds:     page.Embeds,
+			Components: listPaginator.Components(page, ctx.Author.ID, 0),
+		})
 	}
 	return nil
 }
+
+// Returns a page of the items of the user matching the filter, which was parsed from rawFilter
+func (s Todo) listPage(user *discord.User, rawFilter string, filter listFilter, page int) (paginator.Page, error) {
+	todos, err := s.getUserTODOs(user.ID, filter)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+
+	start, end := paginator.Bounds(len(todos), listPageSize, page)
+	embed := todosToEmbed(todos[start:end], start, user)
+	if rawFilter != "" {
+		embed.Description = filterPrefix + "`" + rawFilter + "`"
+	}
+
+	return paginator.Page{
+		Embeds: []*discord.MessageEmbed{embed},
+		Pages:  paginator.Pages(len(todos), listPageSize),
+	}, nil
+}
+
+// Renders a page of a list message, using the filter shown in its embed
+func (s Todo) renderListPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	rawFilter := ""
+	if len(msg.Embeds) != 0 {
+		rawFilter = strings.Trim(strings.TrimPrefix(msg.Embeds[0].Description, filterPrefix), "`")
+	}
+
+	now, err := s.userNow(user.ID)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+	filter, err := parseListFilter(strings.Fields(rawFilter), now)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+
+	return s.listPage(user, rawFilter, filter, page)
+}
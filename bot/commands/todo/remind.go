@@ -0,0 +1,275 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+	"github.com/robfig/cron"
+)
+
+// How often the bot checks for reminders to send
+const reminderInterval = time.Minute
+
+func (s Todo) remindHelp() string {
+	return "Usage: `todo remind [lead time..]`\nSets how long before the due date of an item you get reminded by DM, e.g. `todo remind 1d 2h` or `todo remind 30m`.\nUse `todo remind off` to disable reminders and `todo remind` to show your current settings."
+}
+
+func (s Todo) Remind(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.remindHelp())
+		return nil
+	}
+
+	if len(args) == 0 { // Show the current lead times
+		leads, err := s.getReminderLeads(ctx.Author.ID)
+		if err != nil {
+			return err
+		}
+		content := "You don't get reminded of your items."
+		if len(leads) != 0 {
+			content = "You get reminded of your items " + formatLeadTimes(leads) + " before they are due."
+		}
+		bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+		return nil
+	}
+
+	leads := []time.Duration{}
+	if !(len(args) == 1 && args[0] == "off") {
+		rawLeads := strings.FieldsFunc(strings.Join(args, " "), func(r rune) bool { return r == ' ' || r == ',' })
+		for _, arg := range rawLeads {
+			lead, err := parseLeadTime(arg)
+			if err != nil || lead <= 0 {
+				bot.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Couldn't parse the lead time `%s`.\n%s", arg, s.remindHelp()))
+				return nil
+			}
+			leads = append(leads, lead)
+		}
+	}
+
+	if err := s.setReminderLeads(ctx.Author.ID, leads); err != nil {
+		return err
+	}
+
+	content := "You won't get reminded of your items anymore."
+	if len(leads) != 0 {
+		content = "You will get reminded of your items " + formatLeadTimes(leads) + " before they are due."
+	}
+	msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+	return nil
+}
+
+// Formats lead times as a readable list
+func formatLeadTimes(leads []time.Duration) string {
+	formatted := []string{}
+	for _, lead := range leads {
+		formatted = append(formatted, "`"+formatLeadTime(lead)+"`")
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// Returns how long before the due date of an item the user wants to be reminded
+func (s Todo) getReminderLeads(userId string) ([]time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var minutes []int64
+	if err := s.DB.QueryRowContext(ctx,
+		`SELECT reminder_leads FROM todo.discord_user WHERE id=$1`,
+		userId,
+	).Scan(pq.Array(&minutes)); err != nil {
+		return nil, err
+	}
+
+	leads := []time.Duration{}
+	for _, minute := range minutes {
+		leads = append(leads, time.Duration(minute)*time.Minute)
+	}
+	return leads, nil
+}
+
+// Sets the lead times of the user and reschedules their reminders
+func (s Todo) setReminderLeads(userId string, leads []time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	minutes := []int64{}
+	for _, lead := range leads {
+		minutes = append(minutes, int64(lead/time.Minute))
+	}
+
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE todo.discord_user SET reminder_leads=$2 WHERE id=$1`,
+		userId,
+		pq.Array(minutes),
+	); err != nil {
+		return err
+	}
+
+	log.Println(constants.Blue, "Set reminder lead times of user", userId, "to", minutes)
+
+	return s.scheduleReminders(userId)
+}
+
+// Recreates the upcoming reminders of all active items of the user with a due date
+func (s Todo) scheduleReminders(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todo.reminder WHERE discord_user=$1 AND remind_at > now()`, userId); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO todo.reminder (discord_user, task, remind_at)
+		(
+			SELECT a.discord_user, t.id, t.due - make_interval(mins => lead)
+			FROM todo.active AS a
+			JOIN todo.task AS t ON a.task=t.id
+			JOIN todo.discord_user AS u ON a.discord_user=u.id,
+			UNNEST(u.reminder_leads) AS lead
+			WHERE a.discord_user=$1 AND t.due IS NOT NULL AND t.due - make_interval(mins => lead) > now()
+		) ON CONFLICT DO NOTHING`,
+		userId,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Starts checking for reminders which have to be sent
+// Reminders which should have been sent while the bot was down get sent right away
+func (s Todo) InitialiseReminders() {
+	c.Schedule(cron.Every(reminderInterval), cron.FuncJob(func() {
+		if err := s.sendDueReminders(); err != nil {
+			log.Println(constants.Red, "Failed to send reminders:", err)
+		}
+	}))
+	c.Start()
+
+	go func() {
+		if err := s.sendDueReminders(); err != nil {
+			log.Println(constants.Red, "Failed to send reminders:", err)
+		}
+	}()
+}
+
+// Sends all reminders which are due by DM and removes the ones sent
+// Reminders of items which aren't active anymore are removed without being sent,
+// the ones which couldn't be sent are tried again until their item is due
+func (s Todo) sendDueReminders() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT r.discord_user, r.remind_at, t.id, t.title, t.description, t.due,
+		EXISTS (SELECT * FROM todo.active AS a WHERE a.discord_user=r.discord_user AND a.task=r.task)
+		FROM todo.reminder AS r JOIN todo.task AS t ON r.task=t.id
+		WHERE r.remind_at <= now()`,
+	)
+	if err != nil {
+		return err
+	}
+
+	type dueReminder struct {
+		userId   string
+		remindAt time.Time
+		item     todoItem
+		active   bool
+	}
+	due := []dueReminder{}
+	for rows.Next() {
+		var reminder dueReminder
+		var dueAt sql.NullTime
+		if err := rows.Scan(&reminder.userId, &reminder.remindAt, &reminder.item.ID, &reminder.item.Title, &reminder.item.Description, &dueAt, &reminder.active); err != nil {
+			rows.Close()
+			return err
+		}
+		if dueAt.Valid {
+			reminder.item.Due = &dueAt.Time
+		}
+		due = append(due, reminder)
+	}
+	rows.Close()
+
+	for _, reminder := range due {
+		if reminder.active && reminder.item.Due != nil {
+			if err := s.sendReminder(reminder.userId, reminder.item); err != nil {
+				if reminder.item.Due.After(time.Now()) {
+					log.Println(constants.Red, "Couldn't send reminder for item", reminder.item.ID, "to user", reminder.userId, "trying again:", err)
+					continue
+				}
+				log.Println(constants.Red, "Couldn't send reminder for item", reminder.item.ID, "to user", reminder.userId, "before it was due:", err)
+			}
+		}
+
+		if err := s.deleteReminder(reminder.userId, reminder.item.ID, reminder.remindAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reminds the user of the item by DM
+func (s Todo) sendReminder(userId string, item todoItem) error {
+	channel, err := s.Bot.UserChannelCreate(userId)
+	if err != nil {
+		return err
+	}
+
+	embed := &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: "TODO Reminder",
+		},
+		Color: todoEmbedColor,
+		Fields: []*discord.MessageEmbedField{
+			{
+				Name:  item.Title,
+				Value: "`ID: " + fmt.Sprint(item.ID, "` ", formatDue(*item.Due), "\n", item.Description),
+			},
+		},
+		Footer: &discord.MessageEmbedFooter{
+			Text: "Use todo remind to change when you get reminded",
+		},
+	}
+	if _, err := s.Bot.ChannelMessageSendEmbed(channel.ID, embed); err != nil {
+		return err
+	}
+
+	log.Println(constants.Blue, "Sent reminder for item", item.ID, "to user", userId)
+	return nil
+}
+
+// Removes the reminder, once it was sent or isn't needed anymore
+func (s Todo) deleteReminder(userId string, taskId int, remindAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.reminder WHERE discord_user=$1 AND task=$2 AND remind_at=$3`, userId, taskId, remindAt)
+	return err
+}
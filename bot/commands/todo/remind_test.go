@@ -0,0 +1,26 @@
+package todo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendDueRemindersInactive(t *testing.T) {
+	remindAt := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	due := remindAt.Add(time.Hour)
+
+	// Reminders stay until they were sent, so due ones are only selected
+	dbMock.ExpectQuery(`SELECT r.discord_user, r.remind_at, (.+) FROM todo.reminder AS r JOIN todo.task AS t ON r.task=t.id\s+WHERE r.remind_at <= now\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"discord_user", "remind_at", "id", "title", "description", "due", "exists"}).
+			AddRow("0", remindAt, 5, "Exercise sheet", "", due, false))
+	// Items which aren't active anymore don't get a reminder
+	dbMock.ExpectExec(`DELETE FROM todo.reminder WHERE discord_user=\$1 AND task=\$2 AND remind_at=\$3`).
+		WithArgs("0", 5, remindAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, mockTodo.sendDueReminders())
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,547 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/paginator"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+	"github.com/robfig/cron"
+)
+
+// How often to check for recurring items which have to be created
+const recurringInterval = time.Minute
+
+// Time of day of schedules given without a time
+const (
+	defaultRepeatHour   = 8
+	defaultRepeatMinute = 0
+)
+
+// Most words a schedule can be made up of, as many as the fields of the cronjob format
+const maxScheduleWords = 5
+
+var monthDayRegex = regexp.MustCompile(`^(\d{1,2})\.$`)
+
+// A recurring item a user created for themselves
+type recurringItem struct {
+	ID       int
+	Item     todoItem // Title, tags and priority of the items created
+	Schedule string   // Cronjob format in the timezone of the user
+	Phrase   string   // Schedule as the user entered it
+	Paused   bool
+	NextRun  time.Time
+}
+
+func (s Todo) repeatHelp() string {
+	return "Usage: `todo repeat [title] every [schedule]` or `todo repeat [list|pause|resume|delete]`\n`todo repeat Laundry #home every sun 10:00` adds an item with a title of `Laundry` to your active items every sunday at 10:00.\nSchedules are in your timezone, see `todo timezone`, and such as `every day 18:00`, `every weekday 7:30`, `every mon,thu 12:00`, `every 1. 9:00` for the first of every month or the cronjob format, e.g. `every 0 10 * * 0`. Items can be added at most once a day.\nUse `todo repeat pause [id[,id..]]` and `todo repeat resume [id[,id..]]` to stop and restart recurring items and `todo repeat delete [id[,id..]]` to delete them."
+}
+
+func (s Todo) Repeat(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.repeatHelp())
+		return nil
+	}
+
+	// Replies to invalid IDs instead of failing
+	handleErr := func(err error) error {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("You supplied an invalid ID: %v", err))
+			return nil
+		default:
+			return err
+		}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "list":
+		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+		_, err := repeatPaginator.Send(bot, ctx.ChannelID, ctx.Author)
+		return err
+	case "pause", "resume", "delete", "remove":
+		ids, err := parseIds(args[1:])
+		if err == nil {
+			err = checkNoSubtaskIds(ids)
+		}
+		if err != nil {
+			s.replyAndDelete(bot, ctx, "Error parsing IDs.\n"+s.repeatHelp())
+			return nil
+		}
+		result := "deleted"
+		switch strings.ToLower(args[0]) {
+		case "pause":
+			result = "paused"
+			err = s.setRecurringPaused(ctx.Author.ID, ids, true, time.Now())
+		case "resume":
+			result = "resumed"
+			err = s.setRecurringPaused(ctx.Author.ID, ids, false, time.Now())
+		default:
+			err = s.deleteRecurring(ctx.Author.ID, ids)
+		}
+		if err != nil {
+			return handleErr(err)
+		}
+		s.replyAndDelete(bot, ctx, "Successfully "+result+" "+strings.Join(ids, ", ")+".")
+	default:
+		if strings.ToLower(args[0]) == "add" {
+			args = args[1:]
+		}
+		recurring, err := parseRepeat(args)
+		if err != nil {
+			s.replyAndDelete(bot, ctx, "Couldn't parse the recurring item: "+err.Error()+".\n"+s.repeatHelp())
+			return nil
+		}
+		if recurring, err = s.addRecurring(ctx.Author.ID, recurring, time.Now()); err != nil {
+			return err
+		}
+		s.replyAndDelete(bot, ctx, fmt.Sprintf("Successfully added recurring item %d, %s gets added to your items %s, next <t:%d:R>.",
+			recurring.ID,
+			recurring.Item.Title,
+			recurring.Phrase,
+			recurring.NextRun.Unix(),
+		))
+	}
+	return nil
+}
+
+// Parses a recurring item such as `Laundry #home every sun 10:00`
+// Without every, such as when given by the slash command, the schedule is made up of the most trailing words forming one
+func parseRepeat(args []string) (recurringItem, error) {
+	// The title may contain every itself, so the schedule starts after the last one
+	every := -1
+	for i, arg := range args {
+		if strings.ToLower(arg) == "every" {
+			every = i
+		}
+	}
+
+	var schedule string
+	var err error
+	if every != -1 {
+		schedule, err = parseRepeatSchedule(args[every+1:])
+	} else {
+		every, schedule, err = splitTrailingSchedule(args)
+		args = append(append(args[:every:every], "every"), args[every:]...)
+	}
+	if err != nil {
+		return recurringItem{}, err
+	}
+
+	rest, tags, priority := splitTagsAndPriority(args[:every])
+	title := strings.Trim(strings.Join(rest, " "), `"`)
+	if title == "" {
+		return recurringItem{}, fmt.Errorf("the title is missing")
+	}
+
+	return recurringItem{
+		Item:     todoItem{Title: title, Tags: tags, Priority: priority},
+		Schedule: schedule,
+		Phrase:   "every " + strings.Join(args[every+1:], " "),
+	}, nil
+}
+
+// Returns where the schedule at the end of the arguments starts and the schedule, taking as many words as form one
+// At least the first word is left for the title
+func splitTrailingSchedule(args []string) (int, string, error) {
+	err := fmt.Errorf("the schedule is missing")
+	for start := len(args) - maxScheduleWords; start < len(args); start++ {
+		if start < 1 {
+			continue
+		}
+		var schedule string
+		if schedule, err = parseRepeatSchedule(args[start:]); err == nil {
+			return start, schedule, nil
+		}
+	}
+	return 0, "", err
+}
+
+// Parses the schedule following every, such as `sun 10:00`, into the cronjob format
+// Schedules firing more than once a day are rejected
+func parseRepeatSchedule(words []string) (string, error) {
+	raw := strings.Join(words, " ")
+	// Schedules in the cronjob format are taken as they are
+	if len(words) == maxScheduleWords {
+		if schedule, err := scheduleParser.Parse(raw); err == nil {
+			if !firesOncePerDay(schedule) {
+				return "", fmt.Errorf("the schedule %q fires more than once a day", raw)
+			}
+			return raw, nil
+		}
+	}
+
+	fields := strings.Fields(strings.ToLower(raw))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("the schedule is missing")
+	}
+
+	hour, minute := defaultRepeatHour, defaultRepeatMinute
+	// The time is always the last field
+	if match := timeRegex.FindStringSubmatch(fields[len(fields)-1]); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		minute = 0
+		if match[2] != "" {
+			minute, _ = strconv.Atoi(match[2])
+		}
+		if hour > 23 || minute > 59 {
+			return "", fmt.Errorf("invalid time in schedule %q", raw)
+		}
+		fields = fields[:len(fields)-1]
+	}
+
+	dayOfMonth, dayOfWeek := "*", "*"
+	switch {
+	case len(fields) == 0: // Only a time was supplied, repeat every day
+	case len(fields) > 1:
+		return "", fmt.Errorf("invalid schedule %q", raw)
+	case fields[0] == "day":
+	case fields[0] == "weekday" || fields[0] == "weekdays":
+		dayOfWeek = "1-5"
+	case monthDayRegex.MatchString(fields[0]):
+		day, _ := strconv.Atoi(monthDayRegex.FindStringSubmatch(fields[0])[1])
+		if day < 1 || day > 31 {
+			return "", fmt.Errorf("invalid day of the month in schedule %q", raw)
+		}
+		dayOfMonth = fmt.Sprint(day)
+	default:
+		days := []string{}
+		for _, day := range strings.Split(fields[0], ",") {
+			weekday, found := weekdays[day]
+			if !found {
+				return "", fmt.Errorf("invalid schedule %q", raw)
+			}
+			days = append(days, fmt.Sprint(int(weekday)))
+		}
+		dayOfWeek = strings.Join(deduplicate(days), ",")
+	}
+
+	return fmt.Sprintf("%d %d %s * %s", minute, hour, dayOfMonth, dayOfWeek), nil
+}
+
+// Returns whether the schedule fires at a single time of day, so at most once a day
+func firesOncePerDay(schedule cron.Schedule) bool {
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		return false
+	}
+	// The highest bit marks fields given as *
+	const minutes, hours = 1<<60 - 1, 1<<24 - 1
+	return bits.OnesCount64(spec.Minute&minutes) == 1 && bits.OnesCount64(spec.Hour&hours) == 1
+}
+
+// Renders a page of the recurring items of the user
+func (s Todo) renderRepeatPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	recurring, err := s.getRecurring(user.ID)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+
+	fields := []*discord.MessageEmbedField{}
+	start, end := paginator.Bounds(len(recurring), listPageSize, page)
+	for _, item := range recurring[start:end] {
+		value := "`" + item.Phrase + "`"
+		if labels := formatLabels(item.Item); labels != "" {
+			value += " " + labels
+		}
+		if item.Paused {
+			value += "\n**Paused**"
+		} else {
+			value += fmt.Sprintf("\nNext <t:%d:f> (<t:%d:R>)", item.NextRun.Unix(), item.NextRun.Unix())
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  fmt.Sprintf("%d: %s", item.ID, item.Item.Title),
+			Value: value,
+		})
+	}
+
+	embed := &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: user.Username + "s recurring TODOs",
+		},
+		Color:  todoEmbedColor,
+		Fields: fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text:    "Invoked by " + user.Username,
+			IconURL: user.AvatarURL(""),
+		},
+	}
+	if len(recurring) == 0 {
+		embed.Description = "You have no recurring items, add one with `todo repeat Laundry every sun 10:00`."
+	}
+
+	return paginator.Page{
+		Embeds: []*discord.MessageEmbed{embed},
+		Pages:  paginator.Pages(len(recurring), listPageSize),
+	}, nil
+}
+
+// Adds the recurring item to the user and returns it with its ID and next run after now in their timezone
+func (s Todo) addRecurring(userId string, recurring recurringItem, now time.Time) (recurringItem, error) {
+	schedule, err := scheduleParser.Parse(recurring.Schedule)
+	if err != nil {
+		return recurringItem{}, err
+	}
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return recurringItem{}, err
+	}
+	recurring.NextRun = schedule.Next(now.In(location))
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tags := recurring.Item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	if err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO todo.recurring (discord_user, title, tags, priority, schedule, phrase, next_run) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		userId,
+		recurring.Item.Title,
+		pq.Array(tags),
+		recurring.Item.Priority,
+		recurring.Schedule,
+		recurring.Phrase,
+		recurring.NextRun,
+	).Scan(&recurring.ID); err != nil {
+		return recurringItem{}, err
+	}
+
+	log.Printf("%s Added recurring item %d of user %s with schedule %s\n", constants.Blue, recurring.ID, userId, recurring.Schedule)
+	return recurring, nil
+}
+
+// Returns the recurring items of the user
+func (s Todo) getRecurring(userId string) ([]recurringItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, title, tags, priority, schedule, phrase, paused, next_run FROM todo.recurring WHERE discord_user=$1 ORDER BY id`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []recurringItem{}
+	for rows.Next() {
+		next := recurringItem{}
+		var tags pq.StringArray
+		rows.Scan(&next.ID, &next.Item.Title, &tags, &next.Item.Priority, &next.Schedule, &next.Phrase, &next.Paused, &next.NextRun)
+		next.Item.Tags = tags
+		items = append(items, next)
+	}
+	return items, nil
+}
+
+// Pauses or resumes the users recurring items with the IDs, resumed ones next run after now in their timezone
+// Returns an InvalidIDError if invalid IDs were supplied
+func (s Todo) setRecurringPaused(userId string, ids []string, paused bool, now time.Time) error {
+	userItems, err := s.getRecurring(userId)
+	if err != nil {
+		return err
+	}
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return err
+	}
+
+	items := []recurringItem{}
+	invalid := []string{}
+	for _, id := range ids {
+		found := false
+		for _, item := range userItems {
+			if fmt.Sprint(item.ID) == id {
+				items = append(items, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) != 0 {
+		return &InvalidIDError{invalid}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// Runs missed while paused are skipped
+		if !paused {
+			schedule, err := scheduleParser.Parse(item.Schedule)
+			if err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return err1
+				}
+				return err
+			}
+			item.NextRun = schedule.Next(now.In(location))
+		}
+		if _, err := tx.Exec(`UPDATE todo.recurring SET paused=$2, next_run=$3 WHERE id=$1`,
+			item.ID,
+			paused,
+			item.NextRun,
+		); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return err1
+			}
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Set paused of users %s recurring items %v to %t\n", constants.Blue, userId, ids, paused)
+	return nil
+}
+
+// Deletes the users recurring items with the IDs, the items already created stay
+// Returns an InvalidIDError if invalid IDs were supplied
+func (s Todo) deleteRecurring(userId string, ids []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`DELETE FROM todo.recurring WHERE discord_user=$1 AND id=ANY($2) RETURNING id`,
+		userId,
+		pq.Array(ids),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		found[id] = true
+	}
+	rows.Close()
+
+	invalid := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) != 0 {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return &InvalidIDError{invalid}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Deleted users %s recurring items %v\n", constants.Blue, userId, ids)
+	return nil
+}
+
+// Starts checking for recurring items which have to be created
+// Runs missed while the bot was down are caught up once right away
+func (s Todo) InitialiseRecurring() {
+	c.Schedule(cron.Every(recurringInterval), cron.FuncJob(func() {
+		if err := s.createDueRecurringItems(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to create recurring items:", err)
+		}
+	}))
+	c.Start()
+
+	go func() {
+		if err := s.createDueRecurringItems(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to create recurring items:", err)
+		}
+	}()
+}
+
+// Adds the items of all recurring items due by now to the active items of their users and schedules their next run
+func (s Todo) createDueRecurringItems(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT r.id, r.discord_user, r.title, r.tags, r.priority, r.schedule, u.timezone
+		FROM todo.recurring AS r JOIN todo.discord_user AS u ON u.id=r.discord_user WHERE NOT r.paused AND r.next_run <= $1`,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	type dueItem struct {
+		userId    string
+		recurring recurringItem
+		location  *time.Location
+	}
+	due := []dueItem{}
+	for rows.Next() {
+		next := dueItem{}
+		var tags pq.StringArray
+		var timezone sql.NullString
+		rows.Scan(&next.recurring.ID, &next.userId, &next.recurring.Item.Title, &tags, &next.recurring.Item.Priority, &next.recurring.Schedule, &timezone)
+		next.recurring.Item.Tags = tags
+		next.location = loadLocation(timezone.String)
+		due = append(due, next)
+	}
+	rows.Close()
+
+	for _, item := range due {
+		schedule, err := scheduleParser.Parse(item.recurring.Schedule)
+		if err != nil {
+			log.Println(constants.Red, "Invalid schedule of recurring item", item.recurring.ID, err)
+			continue
+		}
+
+		// Schedule the next run first, so a failure doesn't create the item over and over
+		if _, err := s.DB.ExecContext(ctx, `UPDATE todo.recurring SET next_run=$2 WHERE id=$1`,
+			item.recurring.ID,
+			schedule.Next(now.In(item.location)),
+		); err != nil {
+			return err
+		}
+
+		task := item.recurring.Item
+		task.Description = fmt.Sprint("Automatically created for your recurring item ", item.recurring.ID)
+		if _, err := s.addItem(item.userId, task, recurringAction); err != nil {
+			return err
+		}
+
+		log.Println(constants.Blue, "Created item of recurring item", item.recurring.ID, "for user", item.userId)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepeatSchedule(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+		expectError    bool
+	}{
+		{"sun 10:00", "0 10 * * 0", false},
+		{"Sunday 10", "0 10 * * 0", false},
+		{"mon,thu 12:30", "30 12 * * 1,4", false},
+		{"mon,mon", "0 8 * * 1", false},
+		{"day 18:00", "0 18 * * *", false},
+		{"day", "0 8 * * *", false},
+		{"7:30", "30 7 * * *", false},
+		{"weekday 7:30", "30 7 * * 1-5", false},
+		{"weekdays", "0 8 * * 1-5", false},
+		{"1. 9:00", "0 9 1 * *", false},
+		{"0 10 * * 0", "0 10 * * 0", false},
+		{"30 6,18 * * *", "", true},
+		{"*/15 * * * *", "", true},
+		{"* * * * *", "", true},
+
+		{"", "", true},
+		{"someday", "", true},
+		{"sun mon", "", true},
+		{"sun 25:00", "", true},
+		{"32.", "", true},
+		{"0.", "", true},
+		{"mon,funday", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := parseRepeatSchedule(strings.Fields(test.input))
+		assert.Equal(t, test.expectedOutput, result, test.input)
+		assert.Equal(t, test.expectError, err != nil, test.input)
+	}
+}
+
+func TestParseRepeat(t *testing.T) {
+	recurring, err := parseRepeat([]string{`"Laundry`, `every`, `week"`, "#home", "prio:low", "every", "sun", "10:00"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, recurringItem{
+		Item:     todoItem{Title: "Laundry every week", Tags: []string{"home"}, Priority: priorityLow},
+		Schedule: "0 10 * * 0",
+		Phrase:   "every sun 10:00",
+	}, recurring)
+
+	// The slash command passes the schedule without every
+	recurring, err = parseRepeat([]string{"Laundry", "#home", "sun", "10:00"})
+	assert.Nil(t, err)
+	assert.Equal(t, recurringItem{
+		Item:     todoItem{Title: "Laundry", Tags: []string{"home"}},
+		Schedule: "0 10 * * 0",
+		Phrase:   "every sun 10:00",
+	}, recurring)
+
+	recurring, err = parseRepeat([]string{"Backup", "0", "3", "*", "*", "*"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0 3 * * *", recurring.Schedule)
+
+	_, err = parseRepeat([]string{"Laundry", "someday"})
+	assert.NotNil(t, err)
+
+	_, err = parseRepeat([]string{"sun"})
+	assert.NotNil(t, err)
+
+	_, err = parseRepeat([]string{"every", "sun"})
+	assert.NotNil(t, err)
+}
+
+func TestAddRecurring(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC) // A Wednesday
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	// The schedule fires in the timezone of the user
+	next := time.Date(2022, 10, 16, 10, 0, 0, 0, zurich)
+
+	dbMock.ExpectQuery(`SELECT timezone FROM todo.discord_user`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Zurich"))
+	dbMock.ExpectQuery(`INSERT INTO todo.recurring (.+) RETURNING id`).
+		WithArgs("0", "Laundry", pq.Array([]string{}), priorityNone, "0 10 * * 0", "every sun 10:00", next).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	recurring, err := mockTodo.addRecurring("0", recurringItem{
+		Item:     todoItem{Title: "Laundry"},
+		Schedule: "0 10 * * 0",
+		Phrase:   "every sun 10:00",
+	}, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, recurring.ID)
+	assert.Equal(t, next, recurring.NextRun)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateDueRecurringItems(t *testing.T) {
+	now := time.Date(2022, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.recurring AS r JOIN todo.discord_user AS u ON u.id=r.discord_user WHERE NOT r.paused AND r.next_run <= \$1`).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "discord_user", "title", "tags", "priority", "schedule", "timezone"}).
+			AddRow(3, "0", "Laundry", "{home}", priorityLow, "0 10 * * 0", "UTC"))
+	dbMock.ExpectExec(`UPDATE todo.recurring SET next_run=\$2 WHERE id=\$1`).
+		WithArgs(3, now.AddDate(0, 0, 7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WithArgs("0", "Laundry", "Automatically created for your recurring item 3", nil, pq.Array([]string{"home"}), priorityLow, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	dbMock.ExpectExec(`INSERT INTO todo.active`).
+		WithArgs("0", 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordOperation("0", recurringAction, 5, []int64{12}, []string{""}, []string{"active"})
+	dbMock.ExpectCommit()
+
+	err := mockTodo.createDueRecurringItems(now)
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteRecurringWrongIDs(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.recurring (.+) RETURNING id`).
+		WithArgs("0", pq.Array([]string{"1", "2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	dbMock.ExpectRollback()
+
+	err := mockTodo.deleteRecurring("0", []string{"1", "2"})
+
+	assert.Equal(t, &InvalidIDError{[]string{"2"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,368 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron"
+)
+
+const retentionInterval = time.Hour // How often the retention policies get applied
+
+// Retention policy of a user
+type retentionSettings struct {
+	ArchiveAfter        time.Duration // 0 if completed items are kept
+	ExpireSubscriptions bool
+}
+
+// Items which get archived by the next application of the retention policy
+type retentionPlan struct {
+	Completed []todoItem // Completed items kept for longer than ArchiveAfter
+	Expired   []todoItem // Unfinished subscription items whose semester has ended
+}
+
+func (s Todo) retentionHelp() string {
+	return "Usage: `todo retention [archive <duration|off>] [expire <on|off>]`\n" +
+		"Archives your completed items automatically, e.g. `todo retention archive 7d` archives them a week after completing them.\n" +
+		"`todo retention expire on` archives the subscription items you didn't complete once their semester has ended.\n" +
+		"`todo retention` shows your settings and which items would be archived right now. Automatic archival isn't reverted by `todo undo`, the items stay in `todo list archived`."
+}
+
+func (s Todo) Retention(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.retentionHelp())
+		return nil
+	}
+
+	if len(args) == 0 { // Show the settings and a dry-run report
+		plan, err := s.planRetention(ctx.Author.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		settings, err := s.getRetention(ctx.Author.ID)
+		if err != nil {
+			return err
+		}
+		bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+			Embeds:    []*discord.MessageEmbed{retentionToEmbed(settings, plan)},
+			Reference: ctx.Reference(),
+		})
+		return nil
+	}
+
+	settings, err := s.getRetention(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	settings, err = parseRetention(settings, args)
+	if err != nil {
+		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret the settings.\n"+s.retentionHelp())
+		return nil
+	}
+	if err := s.setRetention(ctx.Author.ID, settings, time.Now()); err != nil {
+		return err
+	}
+
+	msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, formatRetention(settings), ctx.Reference())
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+	return nil
+}
+
+// Applies the changes of the arguments, such as archive 7d or expire on, to the settings
+func parseRetention(settings retentionSettings, args []string) (retentionSettings, error) {
+	if len(args)%2 != 0 {
+		return settings, fmt.Errorf("expected pairs of settings and values, got %v", args)
+	}
+	for i := 0; i < len(args); i += 2 {
+		switch value := args[i+1]; args[i] {
+		case "archive":
+			if value == "off" {
+				settings.ArchiveAfter = 0
+				continue
+			}
+			after, err := parseLeadTime(value)
+			if err != nil || after <= 0 {
+				return settings, fmt.Errorf("invalid duration %q", value)
+			}
+			settings.ArchiveAfter = after
+		case "expire":
+			if value != "on" && value != "off" {
+				return settings, fmt.Errorf("invalid value %q for expire", value)
+			}
+			settings.ExpireSubscriptions = value == "on"
+		default:
+			return settings, fmt.Errorf("unknown setting %q", args[i])
+		}
+	}
+	return settings, nil
+}
+
+// Describes the settings in a sentence
+func formatRetention(settings retentionSettings) string {
+	content := "Your completed items are kept until you archive them"
+	if settings.ArchiveAfter != 0 {
+		content = "Your completed items get archived `" + formatLeadTime(settings.ArchiveAfter) + "` after completing them"
+	}
+	if settings.ExpireSubscriptions {
+		return content + " and unfinished subscription items get archived once their semester has ended."
+	}
+	return content + " and unfinished subscription items are kept."
+}
+
+func retentionToEmbed(settings retentionSettings, plan retentionPlan) *discord.MessageEmbed {
+	fields := []*discord.MessageEmbedField{}
+	for _, section := range []struct {
+		name  string
+		items []todoItem
+	}{{"Completed items to archive", plan.Completed}, {"Subscription items to expire", plan.Expired}} {
+		if len(section.items) != 0 {
+			fields = append(fields, &discord.MessageEmbedField{
+				Name:  fmt.Sprintf("%s (%d)", section.name, len(section.items)),
+				Value: itemLines(section.items),
+			})
+		}
+	}
+
+	footer := "Nothing would be archived right now"
+	if len(fields) != 0 {
+		footer = "These items will be archived within the next hour"
+	}
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: "TODO Retention",
+		},
+		Description: formatRetention(settings),
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text: footer,
+		},
+	}
+}
+
+// Returns the retention policy of the user
+func (s Todo) getRetention(userId string) (retentionSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var archiveAfter sql.NullInt64
+	settings := retentionSettings{}
+	if err := s.DB.QueryRowContext(ctx,
+		`SELECT archive_after, expire_subscriptions FROM todo.discord_user WHERE id=$1`,
+		userId,
+	).Scan(&archiveAfter, &settings.ExpireSubscriptions); err != nil {
+		return retentionSettings{}, err
+	}
+	settings.ArchiveAfter = time.Duration(archiveAfter.Int64) * time.Minute
+	return settings, nil
+}
+
+// Sets the retention policy of the user
+func (s Todo) setRetention(userId string, settings retentionSettings, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var archiveAfter interface{}
+	if settings.ArchiveAfter != 0 {
+		archiveAfter = int64(settings.ArchiveAfter / time.Minute)
+	}
+
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE todo.discord_user SET archive_after=$2, expire_subscriptions=$3, retention_set_at=$4 WHERE id=$1`,
+		userId,
+		archiveAfter,
+		settings.ExpireSubscriptions,
+		now,
+	); err != nil {
+		return err
+	}
+
+	log.Printf("%s Set users %s retention policy to %+v\n", constants.Blue, userId, settings)
+	return nil
+}
+
+// Returns the items the retention policy of the user archives by now
+func (s Todo) planRetention(userId string, now time.Time) (retentionPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	plan := retentionPlan{Completed: []todoItem{}, Expired: []todoItem{}}
+
+	// Items count as completed when they were last moved into the list, either by completing them or undoing a change out of it
+	rows, err := s.DB.QueryContext(ctx, `SELECT t.id, t.title FROM todo.completed AS c
+		JOIN todo.task AS t ON t.id=c.task JOIN todo.discord_user AS u ON u.id=c.discord_user
+		WHERE c.discord_user=$1 AND u.archive_after IS NOT NULL AND COALESCE((
+			SELECT MAX(GREATEST(
+				CASE WHEN oc.to_list='completed' THEN o.created_at END,
+				CASE WHEN oc.from_list='completed' THEN o.undone_at END
+			)) FROM todo.operation_change AS oc JOIN todo.operation AS o ON o.id=oc.operation
+			WHERE o.discord_user=c.discord_user AND oc.task=c.task
+		), u.retention_set_at) + u.archive_after * INTERVAL '1 minute' <= $2
+		ORDER BY t.id`,
+		userId,
+		now,
+	)
+	if err != nil {
+		return retentionPlan{}, err
+	}
+	for rows.Next() {
+		item := todoItem{}
+		rows.Scan(&item.ID, &item.Title)
+		plan.Completed = append(plan.Completed, item)
+	}
+	rows.Close()
+
+	// Subscription items added before the journal existed are kept, as it is unknown when they were added
+	rows, err = s.DB.QueryContext(ctx, `SELECT t.id, t.title, MIN(o.created_at) FROM todo.active AS a
+		JOIN todo.task AS t ON t.id=a.task JOIN todo.discord_user AS u ON u.id=a.discord_user
+		JOIN todo.operation_change AS oc ON oc.task=a.task
+		JOIN todo.operation AS o ON o.id=oc.operation AND o.discord_user=a.discord_user
+		WHERE a.discord_user=$1 AND u.expire_subscriptions AND o.action=$2
+		GROUP BY t.id, t.title ORDER BY t.id`,
+		userId,
+		subscriptionAction,
+	)
+	if err != nil {
+		return retentionPlan{}, err
+	}
+	items, added := []todoItem{}, []time.Time{}
+	for rows.Next() {
+		item := todoItem{}
+		var addedAt time.Time
+		rows.Scan(&item.ID, &item.Title, &addedAt)
+		items = append(items, item)
+		added = append(added, addedAt)
+	}
+	rows.Close()
+	if len(items) == 0 {
+		return plan, nil
+	}
+
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return retentionPlan{}, err
+	}
+	for i, item := range items {
+		// Items are kept as long as the calendar doesn't know when their semester ends
+		if end, found := academicCalendar.SemesterEnd(added[i]); found && !end.After(now) {
+			plan.Expired = append(plan.Expired, item)
+		}
+	}
+
+	return plan, nil
+}
+
+// Starts applying the retention policies periodically
+func (s Todo) InitialiseRetention() {
+	c.Schedule(cron.Every(retentionInterval), cron.FuncJob(func() {
+		if err := s.applyRetention(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to apply retention policies:", err)
+		}
+	}))
+	c.Start()
+
+	go func() {
+		if err := s.applyRetention(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to apply retention policies:", err)
+		}
+	}()
+}
+
+// Archives the items of all users due by their retention policy by now
+func (s Todo) applyRetention(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT id FROM todo.discord_user WHERE archive_after IS NOT NULL OR expire_subscriptions`)
+	if err != nil {
+		return err
+	}
+	users := []string{}
+	for rows.Next() {
+		var user string
+		rows.Scan(&user)
+		users = append(users, user)
+	}
+	rows.Close()
+
+	for _, user := range users {
+		plan, err := s.planRetention(user, now)
+		if err != nil {
+			log.Println(constants.Red, "Failed to plan the retention of user", user, err)
+			continue
+		}
+		for _, change := range []struct {
+			items []todoItem
+			from  string
+		}{{plan.Completed, "completed"}, {plan.Expired, "active"}} {
+			if len(change.items) == 0 {
+				continue
+			}
+			ids := []string{}
+			for _, item := range change.items {
+				ids = append(ids, strconv.Itoa(item.ID))
+			}
+			archived, err := s.archiveRetained(user, ids, change.from)
+			if err != nil {
+				log.Println(constants.Red, "Failed to archive items", ids, "of user", user, err)
+				continue
+			}
+			if len(archived) != 0 {
+				log.Printf("%s Archived users %s %s items %v by their retention policy\n", constants.Blue, user, change.from, archived)
+			}
+		}
+	}
+	return nil
+}
+
+// Archives the items of the user which are still in the list, returns the IDs of the archived ones
+// Items the user changed in the meantime are skipped, they get archived on the next run if they are still due
+// The archival is journaled as done by the retention policy, so todo undo reverts the changes of the user instead
+func (s Todo) archiveRetained(userId string, ids []string, from string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := moveItems(tx, userId, ids, from, "archived")
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, err1
+		}
+		return nil, err
+	}
+	if len(archived) == 0 {
+		return archived, tx.Rollback()
+	}
+
+	if _, err := recordOperation(tx, userId, retentionAction, moveChanges(archived, from, "archived")); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, err1
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	notifyBoards(userId)
+	return archived, nil
+}
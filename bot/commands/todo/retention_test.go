@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetention(t *testing.T) {
+	current := retentionSettings{ArchiveAfter: 24 * time.Hour}
+	tests := []struct {
+		input    []string
+		expected retentionSettings
+		valid    bool
+	}{
+		{[]string{"archive", "7d"}, retentionSettings{ArchiveAfter: 7 * 24 * time.Hour}, true},
+		{[]string{"archive", "off"}, retentionSettings{}, true},
+		{[]string{"expire", "on"}, retentionSettings{ArchiveAfter: 24 * time.Hour, ExpireSubscriptions: true}, true},
+		{[]string{"archive", "2w", "expire", "on"}, retentionSettings{ArchiveAfter: 14 * 24 * time.Hour, ExpireSubscriptions: true}, true},
+		{[]string{"archive"}, retentionSettings{}, false},
+		{[]string{"archive", "0d"}, retentionSettings{}, false},
+		{[]string{"archive", "soon"}, retentionSettings{}, false},
+		{[]string{"expire", "yes"}, retentionSettings{}, false},
+		{[]string{"delete", "7d"}, retentionSettings{}, false},
+	}
+
+	for _, test := range tests {
+		settings, err := parseRetention(current, test.input)
+		if test.valid {
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, settings)
+		} else {
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestFormatRetention(t *testing.T) {
+	assert.Equal(t, "Your completed items are kept until you archive them and unfinished subscription items are kept.",
+		formatRetention(retentionSettings{}))
+	assert.Equal(t, "Your completed items get archived `1w` after completing them and unfinished subscription items get archived once their semester has ended.",
+		formatRetention(retentionSettings{ArchiveAfter: 7 * 24 * time.Hour, ExpireSubscriptions: true}))
+}
+
+func TestPlanRetention(t *testing.T) {
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectQuery(`SELECT t.id, t.title FROM todo.completed`).
+		WithArgs("0", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "Exercise sheet"))
+	dbMock.ExpectQuery(`SELECT t.id, t.title, MIN\(o.created_at\) FROM todo.active`).
+		WithArgs("0", subscriptionAction).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "min"}).
+			AddRow(2, "Analysis Serie", time.Date(2022, 5, 2, 8, 0, 0, 0, time.UTC)).
+			AddRow(3, "Summer project", time.Date(2022, 6, 27, 8, 0, 0, 0, time.UTC)))
+	expectCalendar()
+
+	plan, err := mockTodo.planRetention("0", now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []todoItem{{ID: 1, Title: "Exercise sheet"}}, plan.Completed)
+	assert.Equal(t, []todoItem{{ID: 2, Title: "Analysis Serie"}}, plan.Expired)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestArchiveRetained(t *testing.T) {
+	// Item 2 was moved by the user in the meantime, the others get archived anyway
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.completed WHERE discord_user=\$1 AND task=ANY\(\$2\) RETURNING task`).
+		WithArgs("0", pq.Array([]string{"1", "2", "3"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow(1).AddRow(3))
+	dbMock.ExpectExec(`INSERT INTO todo.archived`).
+		WithArgs("0", pq.Array([]string{"1", "3"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	// Journaled on behalf of the user, so it can't be undone
+	expectRecordOperation("0", retentionAction, 4, []int64{1, 3}, []string{"completed", "completed"}, []string{"archived", "archived"})
+	dbMock.ExpectCommit()
+
+	archived, err := mockTodo.archiveRetained("0", []string{"1", "2", "3"}, "completed")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "3"}, archived)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestArchiveRetainedNothingLeft(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.active`).
+		WithArgs("0", pq.Array([]string{"1"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}))
+	dbMock.ExpectRollback()
+
+	archived, err := mockTodo.archiveRetained("0", []string{"1"}, "active")
+
+	assert.Nil(t, err)
+	assert.Empty(t, archived)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
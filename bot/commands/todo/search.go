@@ -0,0 +1,238 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/paginator"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	searchPageSize = 10
+	searchPrefix   = "Search: " // Start of the description of search embeds showing the query
+)
+
+// Labels of the lists as shown in search results
+var listLabels = map[string]string{
+	"active":    "Active",
+	"completed": "Done",
+	"archived":  "Archived",
+}
+
+type searchResult struct {
+	item todoItem
+	list string // List the item is in
+}
+
+func (s Todo) searchHelp() string {
+	return "Usage: `todo search <query>`\nSearches the titles and descriptions of all your items, e.g. `todo search analysis -exam` or `todo search \"exercise sheet\"`.\nSelect results to mark them as done, archive or delete them."
+}
+
+func (s Todo) Search(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 || (len(args) == 1 && args[0] == "help") {
+		bot.ChannelMessageSend(ctx.ChannelID, s.searchHelp())
+		return nil
+	}
+
+	query := strings.Join(args, " ")
+	page, err := s.searchPage(ctx.Author, query, nil, 0)
+	if err != nil {
+		return err
+	}
+	if page.Pages == 0 {
+		msg, _ := bot.ChannelMessageSend(ctx.ChannelID, "No items match `"+query+"`.")
+		time.Sleep(messageDeleteDelay)
+		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+		return nil
+	}
+
+	bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Embeds:     page.Embeds,
+		Components: searchPaginator.Components(page, ctx.Author.ID, 0),
+	})
+	return nil
+}
+
+// Returns a page of the search results of the user, zero pages if nothing matches
+// The selected results are marked in the select menu and listed in the content
+func (s Todo) searchPage(user *discord.User, query string, selected []string, page int) (paginator.Page, error) {
+	results, err := s.searchItems(user.ID, query)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+	if len(results) == 0 {
+		return paginator.Page{Content: "No items match `" + query + "` anymore."}, nil
+	}
+
+	total := len(results)
+	start, end := paginator.Bounds(total, searchPageSize, page)
+	results = results[start:end]
+
+	fields := []*discord.MessageEmbedField{}
+	options := []discord.SelectMenuOption{}
+	for i, result := range results {
+		item := result.item
+		fields = append(fields, itemEmbedField(start+i+1, item, "**"+listLabels[result.list]+"**"))
+
+		option := selectOption(fmt.Sprint(item.ID), item.Title, listLabels[result.list])
+		for _, value := range selected {
+			option.Default = option.Default || value == option.Value
+		}
+		options = append(options, option)
+	}
+
+	embed := &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: user.Username + "s search results",
+		},
+		Description: searchPrefix + "`" + query + "`",
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text:    "Invoked by " + user.Username,
+			IconURL: user.AvatarURL(""),
+		},
+	}
+	fitEmbed(embed)
+
+	state := ":" + user.ID
+	return paginator.Page{
+		Content: formatSelected(selected),
+		Embeds:  []*discord.MessageEmbed{embed},
+		Components: []discord.MessageComponent{
+			discord.ActionsRow{
+				Components: []discord.MessageComponent{
+					discord.SelectMenu{
+						CustomID:    selectMenuPrefix + "search" + state,
+						Placeholder: "Results to act on",
+						MinValues:   new(int), // 0
+						MaxValues:   len(options),
+						Options:     options,
+					},
+				},
+			},
+			discord.ActionsRow{
+				Components: []discord.MessageComponent{
+					discord.Button{
+						Label:    "Done",
+						Style:    discord.SuccessButton,
+						CustomID: selectSubmitPrefix + "search-done" + state,
+					},
+					discord.Button{
+						Label:    "Archive",
+						Style:    discord.PrimaryButton,
+						CustomID: selectSubmitPrefix + "search-archive" + state,
+					},
+					discord.Button{
+						Label:    "Delete",
+						Style:    discord.DangerButton,
+						CustomID: selectSubmitPrefix + "search-delete" + state,
+					},
+					discord.Button{
+						Label:    constants.Emojis["fail"],
+						Style:    discord.SecondaryButton,
+						CustomID: selectCancelPrefix + "search" + state,
+					},
+				},
+			},
+		},
+		Pages: paginator.Pages(total, searchPageSize),
+	}, nil
+}
+
+// Renders a page of a search message, using the query shown in its embed
+func (s Todo) renderSearchPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	if len(msg.Embeds) == 0 {
+		return paginator.Page{}, fmt.Errorf("search message %s has no embed", msg.ID)
+	}
+	query := strings.Trim(strings.TrimPrefix(msg.Embeds[0].Description, searchPrefix), "`")
+	selected, err := s.getSelection(msg.ID)
+	if err != nil {
+		return paginator.Page{}, err
+	}
+
+	return s.searchPage(user, query, selected, page)
+}
+
+// Returns the items of the user matching the query in any list, the best matches first
+func (s Todo) searchItems(userId, query string) ([]searchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT t.id, t.creator, t.title, t.description, t.due, t.tags, t.priority, `+subtaskProgressColumns+`, l.list
+		FROM todo.task AS t JOIN `+userListsQuery+` AS l ON l.task=t.id,
+		websearch_to_tsquery('simple', $2) AS query
+		WHERE l.discord_user=$1 AND to_tsvector('simple', t.title || ' ' || t.description) @@ query
+		ORDER BY ts_rank(to_tsvector('simple', t.title || ' ' || t.description), query) DESC, t.id DESC`,
+		userId,
+		query,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []searchResult{}
+	for rows.Next() {
+		result := searchResult{}
+		var due sql.NullTime
+		var tags pq.StringArray
+		rows.Scan(&result.item.ID, &result.item.Creator, &result.item.Title, &result.item.Description, &due, &tags, &result.item.Priority, &result.item.Subtasks, &result.item.SubtasksDone, &result.list)
+		if due.Valid {
+			result.item.Due = &due.Time
+		}
+		result.item.Tags = tags
+		results = append(results, result)
+	}
+	log.Printf("%s Searched users TODOs; User: %s, Query: %s, Results: %d\n", constants.Blue, userId, query, len(results))
+
+	return results, nil
+}
+
+// Returns the selected items which are in one of the lists
+func (s Todo) itemsInLists(userId string, items []string, lists ...string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT task FROM `+userListsQuery+` AS l WHERE discord_user=$1 AND task=ANY($2) AND list=ANY($3) ORDER BY task`,
+		userId,
+		pq.Array(items),
+		pq.Array(lists),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []string{}
+	for rows.Next() {
+		var task string
+		rows.Scan(&task)
+		found = append(found, task)
+	}
+	return found, nil
+}
+
+// Returns a select action for search results, applying action to the selected results in one of the lists
+func (s Todo) searchAction(action selectAction, lists ...string) selectAction {
+	return func(bot *discord.Session, user *discord.User, selected []string, msg *discord.Message) error {
+		items, err := s.itemsInLists(user.ID, selected, lists...)
+		if err != nil {
+			return err
+		}
+		return action(bot, user, items, msg)
+	}
+}
@@ -0,0 +1,38 @@
+package todo
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchItems(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT (.+) websearch_to_tsquery\('simple', \$2\) (.+) ORDER BY ts_rank`).
+		WithArgs("userId", "exercise").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "creator", "title", "description", "due", "tags", "priority", "subtasks", "subtasks_done", "list"}).
+			AddRow(2, "0", "Exercise 2", "", nil, "{ana}", priorityNone, 0, 0, "active").
+			AddRow(1, "0", "Exercise 1", "", testNow, "{}", priorityHigh, 3, 3, "archived"))
+
+	results, err := mockTodo.searchItems("userId", "exercise")
+
+	assert.Equal(t, []searchResult{
+		{todoItem{2, "0", "Exercise 2", "", nil, []string{"ana"}, priorityNone, "", 0, 0}, "active"},
+		{todoItem{1, "0", "Exercise 1", "", &testNow, []string{}, priorityHigh, "", 3, 3}, "archived"},
+	}, results)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestItemsInLists(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT task FROM (.+) WHERE discord_user=\$1 AND task=ANY\(\$2\) AND list=ANY\(\$3\)`).
+		WithArgs("userId", pq.Array([]string{"1", "2", "3"}), pq.Array([]string{"active"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("1").AddRow("3"))
+
+	items, err := mockTodo.itemsInLists("userId", []string{"1", "2", "3"}, "active")
+
+	assert.Equal(t, []string{"1", "3"}, items)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,391 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	statsWeeks       = 8 // Weeks shown in the chart of todo stats
+	statsChartWidth  = 800
+	statsChartHeight = 400
+	maxStatsLines    = 15 // Subscriptions listed in the stats, keeps the embed field below Discords size limit
+)
+
+// Colors of the stats chart
+var (
+	statsBackground     = color.RGBA{0x2F, 0x31, 0x36, 0xFF} // Discords dark theme
+	statsTextColor      = color.RGBA{0xDC, 0xDD, 0xDE, 0xFF}
+	statsCreatedColor   = color.RGBA{0x72, 0x76, 0x7D, 0xFF}
+	statsCompletedColor = color.RGBA{0x0B, 0xEE, 0xF0, 0xFF} // todoEmbedColor
+)
+
+// Change of the list of a task as recorded in the journal
+type journalChange struct {
+	At     time.Time
+	Action string
+	listChange
+}
+
+type weekStats struct {
+	Start     time.Time
+	Created   int
+	Completed int
+}
+
+type subscriptionStats struct {
+	Subscription string
+	Items        int
+	Completed    int
+}
+
+type todoStats struct {
+	Weeks             []weekStats   // The last statsWeeks weeks, oldest first
+	AverageCompletion time.Duration // 0 if no item whose creation was journaled got completed
+	Streak            int           // Consecutive days with a completion up to today, or yesterday if there was none today yet
+	Subscriptions     []subscriptionStats
+}
+
+func (s Todo) statsHelp() string {
+	return fmt.Sprintf("Usage: `todo stats`\nShows how many items you created and completed in the last %d weeks, how long it takes you to complete them, your streak of days with a completion and how many items of each of your subscriptions you completed.", statsWeeks)
+}
+
+func (s Todo) Stats(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		bot.ChannelMessageSend(ctx.ChannelID, s.statsHelp())
+		return nil
+	}
+
+	location, err := s.getLocation(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	stats, err := s.getStats(ctx.Author.ID, time.Now().In(location))
+	if err != nil {
+		return err
+	}
+
+	chart := bytes.Buffer{}
+	if err := renderStatsChart(&chart, stats.Weeks); err != nil {
+		return err
+	}
+
+	embed := statsToEmbed(stats, ctx.Author)
+	embed.Image = &discord.MessageEmbedImage{URL: "attachment://stats.png"}
+	if _, err := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Embeds: []*discord.MessageEmbed{embed},
+		Files: []*discord.File{
+			{
+				Name:        "stats.png",
+				ContentType: "image/png",
+				Reader:      &chart,
+			},
+		},
+		Reference: ctx.Reference(),
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("%s Sent users %s stats\n", constants.Blue, ctx.Author.ID)
+	return nil
+}
+
+// Returns an embed summarising the stats of the user
+func statsToEmbed(stats todoStats, user *discord.User) *discord.MessageEmbed {
+	thisWeek := stats.Weeks[len(stats.Weeks)-1]
+	average := "No completed items yet"
+	if stats.AverageCompletion != 0 {
+		average = formatStatsDuration(stats.AverageCompletion)
+	}
+	streak := fmt.Sprintf("%d days", stats.Streak)
+	if stats.Streak == 1 {
+		streak = "1 day"
+	}
+
+	fields := []*discord.MessageEmbedField{
+		{
+			Name:   "This week",
+			Value:  fmt.Sprintf("Created %d, completed %d", thisWeek.Created, thisWeek.Completed),
+			Inline: true,
+		},
+		{
+			Name:   "Average time to completion",
+			Value:  average,
+			Inline: true,
+		},
+		{
+			Name:   "Streak",
+			Value:  streak,
+			Inline: true,
+		},
+	}
+
+	if len(stats.Subscriptions) != 0 {
+		lines := []string{}
+		for i, subscription := range stats.Subscriptions {
+			if i == maxStatsLines {
+				lines = append(lines, fmt.Sprintf("And %d more", len(stats.Subscriptions)-maxStatsLines))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("`%s` %d/%d (%d%%)",
+				subscription.Subscription,
+				subscription.Completed,
+				subscription.Items,
+				100*subscription.Completed/subscription.Items,
+			))
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  "Completion rate per subscription",
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: user.Username + "s TODO stats",
+		},
+		Color:  todoEmbedColor,
+		Fields: fields,
+		Footer: &discord.MessageEmbedFooter{
+			Text:    "Invoked by " + user.Username,
+			IconURL: user.AvatarURL(""),
+		},
+	}
+}
+
+// Formats a duration in days and hours, or hours and minutes if it's shorter than a day
+func formatStatsDuration(duration time.Duration) string {
+	if duration >= 24*time.Hour {
+		duration = duration.Round(time.Hour)
+		return fmt.Sprintf("%dd %dh", duration/(24*time.Hour), duration%(24*time.Hour)/time.Hour)
+	}
+	duration = duration.Round(time.Minute)
+	return fmt.Sprintf("%dh %dm", duration/time.Hour, duration%time.Hour/time.Minute)
+}
+
+// Returns the start of the week of the time, Monday at midnight
+func weekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// Returns the day of the time at midnight
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Computes the stats of the journaled changes, which have to be ordered by time
+// Days and weeks are in the location of now
+func computeStats(changes []journalChange, now time.Time) todoStats {
+	stats := todoStats{Weeks: []weekStats{}}
+	start := weekStart(now).AddDate(0, 0, -7*(statsWeeks-1))
+	for i := 0; i < statsWeeks; i++ {
+		stats.Weeks = append(stats.Weeks, weekStats{Start: start.AddDate(0, 0, 7*i)})
+	}
+
+	added := map[int]time.Time{}
+	completionDays := map[time.Time]bool{}
+	var completionTime time.Duration
+	completions := 0
+	for _, change := range changes {
+		at := change.At.In(now.Location())
+		week := -1
+		if !at.Before(start) {
+			week = int(weekStart(at).Sub(start).Hours()+12) / (7 * 24) // Rounded, as weeks with DST changes aren't exactly 7 days
+		}
+
+		if change.From == "" {
+			if _, found := added[change.Task]; !found {
+				added[change.Task] = at
+			}
+			// Items of subscriptions weren't created by the user
+			if week >= 0 && week < statsWeeks && change.Action != subscriptionAction {
+				stats.Weeks[week].Created++
+			}
+		}
+		if change.To == "completed" {
+			if week >= 0 && week < statsWeeks {
+				stats.Weeks[week].Completed++
+			}
+			completionDays[dayStart(at)] = true
+			if addedAt, found := added[change.Task]; found {
+				completionTime += at.Sub(addedAt)
+				completions++
+			}
+		}
+	}
+
+	if completions != 0 {
+		stats.AverageCompletion = completionTime / time.Duration(completions)
+	}
+
+	day := dayStart(now)
+	if !completionDays[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for completionDays[day] {
+		stats.Streak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return stats
+}
+
+// Returns the stats of the user
+func (s Todo) getStats(userId string, now time.Time) (todoStats, error) {
+	changes, err := s.getJournal(userId)
+	if err != nil {
+		return todoStats{}, err
+	}
+	stats := computeStats(changes, now)
+
+	stats.Subscriptions, err = s.getSubscriptionStats(userId)
+	if err != nil {
+		return todoStats{}, err
+	}
+	return stats, nil
+}
+
+// Returns the changes of the user which weren't undone, ordered by time
+func (s Todo) getJournal(userId string) ([]journalChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT o.created_at, o.action, c.task, COALESCE(c.from_list, ''), COALESCE(c.to_list, '')
+		FROM todo.operation AS o JOIN todo.operation_change AS c ON c.operation=o.id
+		WHERE o.discord_user=$1 AND o.undone_at IS NULL ORDER BY o.created_at, o.id`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []journalChange{}
+	for rows.Next() {
+		var change journalChange
+		if err := rows.Scan(&change.At, &change.Action, &change.Task, &change.From, &change.To); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Returns how many items of each subscription the user got and how many of them they completed
+// Archived items count as completed if they were completed before
+func (s Todo) getSubscriptionStats(userId string) ([]subscriptionStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT t.subscription,
+		COUNT(*),
+		COUNT(*) FILTER (WHERE l.list='completed' OR EXISTS (
+			SELECT * FROM todo.operation_change AS c JOIN todo.operation AS o ON o.id=c.operation
+			WHERE o.discord_user=l.discord_user AND o.undone_at IS NULL AND c.task=t.id AND c.to_list='completed'
+		))
+		FROM todo.task AS t JOIN `+userListsQuery+` AS l ON l.task=t.id
+		WHERE l.discord_user=$1 AND t.subscription IS NOT NULL
+		GROUP BY t.subscription ORDER BY t.subscription`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []subscriptionStats{}
+	for rows.Next() {
+		var subscription subscriptionStats
+		if err := rows.Scan(&subscription.Subscription, &subscription.Items, &subscription.Completed); err != nil {
+			return nil, err
+		}
+		stats = append(stats, subscription)
+	}
+	return stats, nil
+}
+
+// Draws a bar chart of the items created and completed per week as a PNG
+func renderStatsChart(w io.Writer, weeks []weekStats) error {
+	const (
+		marginLeft   = 20.0
+		marginRight  = 20.0
+		marginTop    = 50.0
+		marginBottom = 40.0
+	)
+
+	dc := gg.NewContext(statsChartWidth, statsChartHeight)
+	dc.SetColor(statsBackground)
+	dc.Clear()
+	dc.SetFontFace(basicfont.Face7x13)
+
+	maxCount := 1
+	for _, week := range weeks {
+		if week.Created > maxCount {
+			maxCount = week.Created
+		}
+		if week.Completed > maxCount {
+			maxCount = week.Completed
+		}
+	}
+
+	// Legend
+	for i, entry := range []struct {
+		label string
+		color color.Color
+	}{{"Created", statsCreatedColor}, {"Completed", statsCompletedColor}} {
+		x := marginLeft + float64(i)*120
+		dc.SetColor(entry.color)
+		dc.DrawRectangle(x, 15, 14, 14)
+		dc.Fill()
+		dc.SetColor(statsTextColor)
+		dc.DrawStringAnchored(entry.label, x+20, 22, 0, 0.5)
+	}
+
+	plotHeight := statsChartHeight - marginTop - marginBottom
+	groupWidth := (statsChartWidth - marginLeft - marginRight) / float64(len(weeks))
+	barWidth := groupWidth * 0.35
+	baseline := statsChartHeight - marginBottom
+
+	for i, week := range weeks {
+		groupX := marginLeft + float64(i)*groupWidth
+		for j, bar := range []struct {
+			count int
+			color color.Color
+		}{{week.Created, statsCreatedColor}, {week.Completed, statsCompletedColor}} {
+			x := groupX + groupWidth*0.15 + float64(j)*barWidth
+			height := plotHeight * float64(bar.count) / float64(maxCount)
+			dc.SetColor(bar.color)
+			dc.DrawRectangle(x, baseline-height, barWidth-2, height)
+			dc.Fill()
+			if bar.count != 0 {
+				dc.SetColor(statsTextColor)
+				dc.DrawStringAnchored(fmt.Sprint(bar.count), x+barWidth/2, baseline-height-8, 0.5, 0.5)
+			}
+		}
+		dc.SetColor(statsTextColor)
+		dc.DrawStringAnchored(week.Start.Format("2.1."), groupX+groupWidth/2, baseline+18, 0.5, 0.5)
+	}
+
+	dc.SetColor(statsTextColor)
+	dc.SetLineWidth(1)
+	dc.DrawLine(marginLeft, baseline, statsChartWidth-marginRight, baseline)
+	dc.Stroke()
+
+	return dc.EncodePNG(w)
+}
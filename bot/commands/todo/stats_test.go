@@ -0,0 +1,119 @@
+package todo
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeekStart(t *testing.T) {
+	monday := time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input          time.Time
+		expectedOutput time.Time
+	}{
+		{time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC), monday},
+		{time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC), monday},
+		{time.Date(2022, 10, 16, 23, 59, 0, 0, time.UTC), monday},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, weekStart(test.input))
+	}
+}
+
+func TestFormatStatsDuration(t *testing.T) {
+	tests := []struct {
+		input          time.Duration
+		expectedOutput string
+	}{
+		{90 * time.Minute, "1h 30m"},
+		{26*time.Hour + 40*time.Minute, "1d 3h"},
+		{20 * time.Second, "0h 0m"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, formatStatsDuration(test.input))
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC) // A Wednesday
+	day := func(days int, hour int) time.Time {
+		return time.Date(2022, 10, 12+days, hour, 0, 0, 0, time.UTC)
+	}
+	changes := []journalChange{
+		// Too old for the chart, but counted for the average
+		{day(-70, 8), "add", listChange{1, "", "active"}},
+		{day(-69, 8), "move", listChange{1, "active", "completed"}},
+		{day(-7, 10), "add", listChange{2, "", "active"}},
+		{day(-2, 10), subscriptionAction, listChange{3, "", "active"}},
+		{day(-1, 9), "move", listChange{3, "active", "completed"}},
+		{day(0, 9), "move", listChange{2, "active", "completed"}},
+		// Items whose creation wasn't journaled don't count for the average
+		{day(0, 10), "move", listChange{4, "active", "completed"}},
+	}
+
+	stats := computeStats(changes, now)
+
+	assert.Len(t, stats.Weeks, statsWeeks)
+	assert.Equal(t, time.Date(2022, 8, 22, 0, 0, 0, 0, time.UTC), stats.Weeks[0].Start)
+	assert.Equal(t, weekStats{time.Date(2022, 10, 3, 0, 0, 0, 0, time.UTC), 1, 0}, stats.Weeks[statsWeeks-2])
+	assert.Equal(t, weekStats{time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC), 0, 3}, stats.Weeks[statsWeeks-1])
+	assert.Equal(t, (24*time.Hour+23*time.Hour+7*24*time.Hour-time.Hour)/3, stats.AverageCompletion)
+	assert.Equal(t, 2, stats.Streak)
+}
+
+func TestComputeStatsStreak(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	completion := func(t time.Time) journalChange {
+		return journalChange{t, "move", listChange{1, "active", "completed"}}
+	}
+
+	tests := []struct {
+		input          []journalChange
+		expectedStreak int
+	}{
+		{[]journalChange{}, 0},
+		// A streak isn't broken before the end of today
+		{[]journalChange{completion(time.Date(2022, 10, 10, 23, 0, 0, 0, time.UTC)), completion(time.Date(2022, 10, 11, 1, 0, 0, 0, time.UTC))}, 2},
+		{[]journalChange{completion(time.Date(2022, 10, 10, 23, 0, 0, 0, time.UTC))}, 0},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedStreak, computeStats(test.input, now).Streak)
+	}
+}
+
+func TestRenderStatsChart(t *testing.T) {
+	stats := computeStats([]journalChange{}, time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC))
+	stats.Weeks[3].Created = 5
+	stats.Weeks[3].Completed = 2
+
+	chart := bytes.Buffer{}
+	err := renderStatsChart(&chart, stats.Weeks)
+	assert.Nil(t, err)
+
+	image, err := png.Decode(&chart)
+	assert.Nil(t, err)
+	assert.Equal(t, statsChartWidth, image.Bounds().Dx())
+	assert.Equal(t, statsChartHeight, image.Bounds().Dy())
+}
+
+func TestGetSubscriptionStats(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT t.subscription, (.+) FROM todo.task AS t JOIN (.+) WHERE l.discord_user=\$1 AND t.subscription IS NOT NULL GROUP BY t.subscription`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "count", "completed"}).
+			AddRow("252-0025-01L", 12, 9).
+			AddRow("401-0212-16L", 4, 0))
+
+	stats, err := mockTodo.getSubscriptionStats("0")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []subscriptionStats{{"252-0025-01L", 12, 9}, {"401-0212-16L", 4, 0}}, stats)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/bot/paginator"
 
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/lib/pq"
@@ -17,19 +18,17 @@ import (
 
 var c *cron.Cron = cron.New()
 
-const (
-	// Calendar weeks of semester start / end
-	springSemesterStart = 8
-	springSemesterEnd   = 22
+// Parser of the schedules of subscriptions and recurring items, in the cronjob format without seconds
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
 
-	fallSemesterStart = 38
-	fallSemesterEnd   = 51
-)
+// Lines per page of todo subscribe list
+const subscriptionsPageSize = 20
 
 type subscriptionItem struct {
 	id       string
 	name     string
 	schedule string
+	semester string
 }
 
 type subscriptionItemNode struct {
@@ -41,8 +40,14 @@ type subscriptionItemNode struct {
 
 var subscriptionForest []*subscriptionItemNode
 
+// Runs the jobs creating the subscription items, replaced whenever the subscriptions change
+var subscriptionCron *cron.Cron
+
+// Guards subscriptionForest and subscriptionCron, which get replaced when the subscriptions are reloaded
+var subscriptionsMutex sync.RWMutex
+
 func (s Todo) subscribeHelp() string {
-	return "Usage: `todo subscribe [list|add|delete]`"
+	return "Usage: `todo subscribe [list|add|delete|admin]`"
 }
 
 func (s Todo) Subscribe(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
@@ -59,6 +64,8 @@ func (s Todo) Subscribe(bot *discord.Session, ctx *discord.MessageCreate, args [
 			return s.subscriptionAdd(bot, ctx, args[1:])
 		case "delete", "remove", "unsubscribe":
 			return s.subscriptionDelete(bot, ctx, args[1:])
+		case "admin": // Manages the subscriptions themselves
+			return s.subscriptionAdmin(bot, ctx, args[1:])
 		default:
 			bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.subscribeHelp())
 			return nil
@@ -69,12 +76,18 @@ func (s Todo) Subscribe(bot *discord.Session, ctx *discord.MessageCreate, args [
 
 func (s Todo) subscriptionList(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
 	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
-	content := ctx.Author.Mention() + "'s subscriptions. Items in green are in your subscription list.\nAll schedules are displayed in the cronjob format.\n```bash\n"
+	_, err := subscriptionsPaginator.Send(bot, ctx.ChannelID, ctx.Author)
+	return err
+}
+
+// Renders a page of the subscription list of the user
+func (s Todo) renderSubscriptionsPage(msg *discord.Message, user *discord.User, page int) (paginator.Page, error) {
+	content := user.Mention() + "'s subscriptions. Items in green are in your subscription list.\nAll schedules are displayed in the cronjob format, `todo upcoming` shows when your next items get created.\n```bash\n"
 
 	// Fold the users subscription forest to make it more presentable
-	userForest, err := s.getUserSubscriptionForest(ctx.Author.ID)
+	userForest, err := s.getUserSubscriptionForest(user.ID)
 	if err != nil {
-		return err
+		return paginator.Page{}, err
 	}
 	formattedItems := s.foldSubscriptionForest(userForest, func(acc []todoItem, curr subscriptionItemNode) []todoItem {
 		rootItem := todoItem{
@@ -95,193 +108,179 @@ func (s Todo) subscriptionList(bot *discord.Session, ctx *discord.MessageCreate,
 		return append(acc, rootItem)
 	})
 
-	for _, item := range formattedItems {
+	start, end := paginator.Bounds(len(formattedItems), subscriptionsPageSize, page)
+	for _, item := range formattedItems[start:end] {
 		content += item.Title + "\n"
 	}
 
 	content += "\n```"
-	bot.ChannelMessageSend(ctx.ChannelID, content)
-	return nil
+	return paginator.Page{
+		Content: content,
+		Pages:   paginator.Pages(len(formattedItems), subscriptionsPageSize),
+	}, nil
 }
 
 func (s Todo) subscriptionAdd(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
 	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 
-	// Fold the users subscription forest to make it more presentable
-	userForest, err := s.getUserSubscriptionForest(ctx.Author.ID)
+	options, err := s.subscribeOptions(ctx.Author.ID)
 	if err != nil {
 		return err
 	}
-	listedItems := s.foldSubscriptionForest(userForest, func(acc []todoItem, curr subscriptionItemNode) []todoItem {
-		stringIndexes := []string{}
-		for _, index := range curr.nodeIndexes {
-			stringIndexes = append(stringIndexes, fmt.Sprint(index))
-		}
-		rootItem := todoItem{
-			ID:          len(acc),
-			Title:       strings.Join(stringIndexes, ".") + ". " + curr.value.name,
-			Description: curr.value.id,
-		}
-		// Mark item if the user is subscribed
-		if curr.subscribed {
-			rootItem.Description = constants.Emojis["success"] + " " + rootItem.Description
-		}
 
-		return append(acc, rootItem)
-	})
 	return s.sendItemSelectMessage(
 		bot,
 		ctx,
-		listedItems,
+		options,
 		ctx.Author.Mention()+`, which schedules to you want to subscribe to?
 If you choose one schedule, you will automatically be subscribed to all its children.
 Items marked with `+constants.Emojis["success"]+" are already in your subscription list.",
 		"Schedules to subscribe to",
-		func(items []string, msg *discord.Message) error {
-
-			selectedSubscriptions := []string{}
-			for _, index := range items {
-				// TODO: Definitely have to refactor sendItemSelectMessage to accept non-int ids
-				index, _ := strconv.Atoi(index)
-				listedItem := listedItems[index]
-				split := strings.Split(listedItem.Description, " ")
-				selectedSubscriptions = append(selectedSubscriptions, split[len(split)-1])
-			}
+		"subscribe",
+	)
+}
 
-			newlySubscribed, err := s.addSubscriptions(ctx.Author.ID, selectedSubscriptions)
-			if err != nil {
-				return err
-			}
+// Returns the options of all subscriptions, marking the ones the user is subscribed to
+func (s Todo) subscribeOptions(userId string) ([]discord.SelectMenuOption, error) {
+	// Fold the users subscription forest to make it more presentable
+	userForest, err := s.getUserSubscriptionForest(userId)
+	if err != nil {
+		return nil, err
+	}
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return nil, err
+	}
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
-			content := "Successfully subscribed to " + strings.Join(newlySubscribed, ", ") + "."
-			if len(newlySubscribed) == 0 {
-				content = "Didn't add any new subscriptions."
-			}
+	options := []discord.SelectMenuOption{}
+	for _, node := range s.flattenSubscriptionForest(userForest) {
+		description := node.value.id
+		// Preview when the subscription creates its next item
+		if preview := subscriptionPreview(node.value, academicCalendar, now, location); preview != "" {
+			description += ", " + preview
+		}
+		// Mark item if the user is subscribed
+		if node.subscribed {
+			description = constants.Emojis["success"] + " " + description
+		}
+		options = append(options, selectOption(node.value.id, subscriptionLabel(node), description))
+	}
+	return options, nil
+}
 
-			log.Println(constants.Yellow, "User", ctx.Author.Username, "newly subscribed to", newlySubscribed)
+// Select action subscribing the user to the selected subscriptions
+func (s Todo) subscribeSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	newlySubscribed, err := s.addSubscriptions(user.ID, items)
+	if err != nil {
+		return err
+	}
 
-			bot.ChannelMessageEditComplex(&discord.MessageEdit{
-				Content:    &content,
-				Components: []discord.MessageComponent{},
-				ID:         msg.ID,
-				Channel:    ctx.ChannelID,
-			})
+	content := "Successfully subscribed to " + strings.Join(newlySubscribed, ", ") + "."
+	if len(newlySubscribed) == 0 {
+		content = "Didn't add any new subscriptions."
+	}
 
-			time.Sleep(messageDeleteDelay)
-			bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-			return nil
-		},
-		func(items []string, msg *discord.Message) error {
-			content := "Cancelled"
-			bot.ChannelMessageEditComplex(&discord.MessageEdit{
-				Content:    &content,
-				Components: []discord.MessageComponent{},
-				ID:         msg.ID,
-				Channel:    ctx.ChannelID,
-			})
-
-			time.Sleep(messageDeleteDelay)
-			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
-			return nil
-		},
-	)
+	log.Println(constants.Yellow, "User", user.Username, "newly subscribed to", newlySubscribed)
+
+	finishSelectMessage(bot, msg, content)
+	return nil
 }
 
 func (s Todo) subscriptionDelete(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
 	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
 
-	// Fold the users subscription forest to make it more presentable
-	userForest, err := s.getUserSubscriptionForest(ctx.Author.ID)
+	options, err := s.unsubscribeOptions(ctx.Author.ID)
 	if err != nil {
 		return err
 	}
-	listedItems := s.foldSubscriptionForest(userForest, func(acc []todoItem, curr subscriptionItemNode) []todoItem {
-		stringIndexes := []string{}
-		for _, index := range curr.nodeIndexes {
-			stringIndexes = append(stringIndexes, fmt.Sprint(index))
-		}
-		rootItem := todoItem{
-			ID:          len(acc),
-			Title:       strings.Join(stringIndexes, ".") + ". " + curr.value.name,
-			Description: curr.value.id,
-		}
-		// Mark item if the user is subscribed
-		if curr.subscribed {
-			return append(acc, rootItem)
-		}
-		return acc
-	})
 
-	if len(listedItems) == 0 {
+	if len(options) == 0 {
 		bot.ChannelMessageSend(ctx.ChannelID, ctx.Author.Mention()+" doesn't have any active subscriptions.")
+		return nil
 	}
 
 	return s.sendItemSelectMessage(
 		bot,
 		ctx,
-		listedItems,
+		options,
 		ctx.Author.Mention()+`, which schedules to you want to unsubscribe from?
 If you unsubscribe from an item, you will automatically be unsubscribed from all its children too`,
 		"Schedules to unsubscribe from",
-		func(items []string, msg *discord.Message) error {
-
-			selectedSubscriptions := []string{}
-			for _, index := range items {
-				// TODO: Definitely have to refactor sendItemSelectMessage to accept non-int ids
-				index, _ := strconv.Atoi(index)
-				listedItem := listedItems[index]
-				split := strings.Split(listedItem.Description, " ")
-				selectedSubscriptions = append(selectedSubscriptions, split[len(split)-1])
-			}
+		"unsubscribe",
+	)
+}
 
-			unsubscribed, err := s.deleteSubscriptions(ctx.Author.ID, selectedSubscriptions)
-			if err != nil {
-				return err
-			}
+// Returns the options of the subscriptions the user is subscribed to
+func (s Todo) unsubscribeOptions(userId string) ([]discord.SelectMenuOption, error) {
+	// Fold the users subscription forest to make it more presentable
+	userForest, err := s.getUserSubscriptionForest(userId)
+	if err != nil {
+		return nil, err
+	}
+	options := []discord.SelectMenuOption{}
+	for _, node := range s.flattenSubscriptionForest(userForest) {
+		// Only list items the user is subscribed to
+		if node.subscribed {
+			options = append(options, selectOption(node.value.id, subscriptionLabel(node), node.value.id))
+		}
+	}
+	return options, nil
+}
 
-			content := "Successfully unsubscribed from " + strings.Join(unsubscribed, ", ") + "."
-			if len(unsubscribed) == 0 {
-				content = "Didn't delete any subscriptions."
-			}
+// Select action unsubscribing the user from the selected subscriptions
+func (s Todo) unsubscribeSelected(bot *discord.Session, user *discord.User, items []string, msg *discord.Message) error {
+	unsubscribed, err := s.deleteSubscriptions(user.ID, items)
+	if err != nil {
+		return err
+	}
 
-			log.Println(constants.Yellow, "User", ctx.Author.Username, "unsubscribed from", unsubscribed)
+	content := "Successfully unsubscribed from " + strings.Join(unsubscribed, ", ") + "."
+	if len(unsubscribed) == 0 {
+		content = "Didn't delete any subscriptions."
+	}
 
-			bot.ChannelMessageEditComplex(&discord.MessageEdit{
-				Content:    &content,
-				Components: []discord.MessageComponent{},
-				ID:         msg.ID,
-				Channel:    ctx.ChannelID,
-			})
+	log.Println(constants.Yellow, "User", user.Username, "unsubscribed from", unsubscribed)
 
-			time.Sleep(messageDeleteDelay)
-			bot.ChannelMessageDelete(msg.ChannelID, msg.ID)
-			return nil
-		},
-		func(items []string, msg *discord.Message) error {
-			content := "Cancelled"
-			bot.ChannelMessageEditComplex(&discord.MessageEdit{
-				Content:    &content,
-				Components: []discord.MessageComponent{},
-				ID:         msg.ID,
-				Channel:    ctx.ChannelID,
-			})
-
-			time.Sleep(messageDeleteDelay)
-			bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
-			return nil
-		},
-	)
+	finishSelectMessage(bot, msg, content)
+	return nil
+}
+
+// Label of a subscription in a select message, prefixed by its position in the forest
+func subscriptionLabel(node subscriptionItemNode) string {
+	stringIndexes := []string{}
+	for _, index := range node.nodeIndexes {
+		stringIndexes = append(stringIndexes, fmt.Sprint(index))
+	}
+	return strings.Join(stringIndexes, ".") + ". " + node.value.name
 }
 
 // Parse all subscriptions and create their structs
 func (s Todo) InitialiseSubscriptions() error {
+	if err := s.reloadSubscriptions(); err != nil {
+		return err
+	}
+
+	// The jobs only fire from now on, create the items of the firings missed while the bot was down
+	go func() {
+		if err := s.catchUpSubscriptions(time.Now()); err != nil {
+			log.Println(constants.Red, "Failed to catch up on subscriptions:", err)
+		}
+	}()
+	return nil
+}
 
-	// Initialise the subscription tree for listing subscriptions and
+// Reloads the subscription forest and replaces the subscription cronjobs with the ones of the current subscriptions
+func (s Todo) reloadSubscriptions() error {
+	// Initialise the subscription tree for listing subscriptions
 	subscriptionForestLocal, err := s.getSubscriptionForest()
 	if err != nil {
 		return err
 	}
-	subscriptionForest = subscriptionForestLocal
 
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
@@ -292,9 +291,9 @@ func (s Todo) InitialiseSubscriptions() error {
 		log.Println(constants.Red, "Couldn't get subscriptions", err)
 		return err
 	}
+	defer rows.Close()
 
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-
+	jobs := cron.New()
 	for rows.Next() {
 		var id string
 		var schedule string
@@ -302,33 +301,40 @@ func (s Todo) InitialiseSubscriptions() error {
 		rows.Scan(&id, &schedule, &semester)
 
 		if len(schedule) != 0 {
-			schedule, err := parser.Parse(schedule)
+			schedule, err := scheduleParser.Parse(schedule)
 			if err != nil {
 				log.Println(constants.Red, "Failed to add new schedule: ", err)
+				continue
 			}
-			c.Schedule(schedule, cron.FuncJob(func() {
-				// Don't creat the subscription item if the task is for another semester
-				_, calendarWeek := time.Now().ISOWeek()
-				if semester == "F" &&
-					(calendarWeek < springSemesterStart || calendarWeek > springSemesterEnd) {
-					return
-				}
-				if semester == "H" &&
-					(calendarWeek < fallSemesterStart || calendarWeek > fallSemesterEnd) {
+			jobs.Schedule(schedule, cron.FuncJob(func() {
+				// Jobs fire at the start of the minute of the schedule
+				firedAt := time.Now().Truncate(time.Minute)
+				// Don't create the subscription item outside of its semester or during breaks
+				active, err := s.semesterActive(semester, firedAt)
+				if err != nil {
+					log.Println(constants.Red, "Failed to get the academic calendar:", err)
 					return
 				}
-				if semester == "B" &&
-					(calendarWeek < springSemesterStart || calendarWeek > springSemesterEnd) &&
-					(calendarWeek < fallSemesterStart || calendarWeek > fallSemesterEnd) {
+				if !active {
 					return
 				}
-				if err := s.createSubscriptionItem(id); err != nil {
+				if err := s.createSubscriptionItem(id, firedAt); err != nil {
 					log.Println(constants.Red, "failed to create subscription item: ", err)
 				}
 			}))
 		}
 	}
-	c.Start()
+
+	subscriptionsMutex.Lock()
+	defer subscriptionsMutex.Unlock()
+
+	subscriptionForest = subscriptionForestLocal
+	// Jobs which are already running finish, but the old jobs don't fire anymore
+	if subscriptionCron != nil {
+		subscriptionCron.Stop()
+	}
+	subscriptionCron = jobs
+	subscriptionCron.Start()
 
 	return nil
 }
@@ -540,8 +546,9 @@ func (s Todo) deleteSubscription(userId, subscription string) error {
 	return nil
 }
 
-// Adds an active subscription item with an id of id to all users subscribed to it
-func (s Todo) createSubscriptionItem(id string) error {
+// Adds an active subscription item with an id of id to all users subscribed to it, for the firing of its schedule at firedAt
+// Nothing is created if the items of the firing were created already
+func (s Todo) createSubscriptionItem(id string, firedAt time.Time) error {
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
@@ -550,6 +557,21 @@ func (s Todo) createSubscriptionItem(id string) error {
 		return err
 	}
 
+	// Recording the firing first makes sure only one job or catch up creates its items
+	result, err := tx.Exec(`INSERT INTO todo.subscription_run (subscription, fired_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`, id, firedAt)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	if recorded, err := result.RowsAffected(); err != nil || recorded == 0 {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
 	var name string
 	rows, err := tx.Query(`SELECT subscription_name FROM todo.subscription WHERE id=$1`, id)
 	if err != nil {
@@ -563,15 +585,27 @@ func (s Todo) createSubscriptionItem(id string) error {
 	rows.Scan(&name)
 	rows.Close()
 
-	// Create the task with a userid of the bot
-	taskId, err := s.CreateTask("0", name, "Automatically created for subscription "+id)
+	// Create the task with a userid of the bot, tagged with the subscription
+	// It is created within the transaction, so failures don't leave tasks behind which nobody has
+	taskId, err := createTask(ctx, tx, "0", todoItem{
+		Title:        name,
+		Description:  "Automatically created for subscription " + id,
+		Tags:         []string{strings.ToLower(id)},
+		Subscription: id,
+	})
 	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
 		return err
 	}
 
 	// Get all subscriptions which are ancestors of the subscription
 	ancestors, err := s.getAncestors(id)
 	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
 		return err
 	}
 
@@ -584,10 +618,34 @@ func (s Todo) createSubscriptionItem(id string) error {
 		taskId,
 		pq.Array(ancestors),
 	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	// Journal the addition for every user, for their stats
+	if _, err := tx.Exec(`WITH operations AS (
+			INSERT INTO todo.operation (discord_user, action)
+			SELECT DISTINCT discord_user, $3 FROM todo.subscribed_to WHERE subscription=ANY($2)
+			RETURNING id
+		) INSERT INTO todo.operation_change (operation, task, to_list) SELECT id, $1, 'active' FROM operations`,
+		taskId,
+		pq.Array(ancestors),
+		subscriptionAction,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
 	log.Println(constants.Blue, "Created new subscription item with id", id, "and name", name)
+	notifyAllBoards()
 	return nil
 }
 
@@ -625,7 +683,7 @@ func (s Todo) getSubscriptionForest() ([]*subscriptionItemNode, error) {
 	defer cancel()
 
 	// Get the roots
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, subscription_name, schedule FROM todo.subscription 
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, subscription_name, schedule, semester FROM todo.subscription 
 	WHERE id NOT IN (SELECT child FROM todo.subscription_child)
 	ORDER BY id ASC`)
 	if err != nil {
@@ -635,9 +693,9 @@ func (s Todo) getSubscriptionForest() ([]*subscriptionItemNode, error) {
 	var roots []*subscriptionItemNode
 	index := 1
 	for rows.Next() {
-		var id, subscription_name, schedule string
+		var id, subscription_name, schedule, semester string
 
-		rows.Scan(&id, &subscription_name, &schedule)
+		rows.Scan(&id, &subscription_name, &schedule, &semester)
 
 		indexes := []int{index}
 		index++
@@ -652,6 +710,7 @@ func (s Todo) getSubscriptionForest() ([]*subscriptionItemNode, error) {
 				id:       id,
 				name:     subscription_name,
 				schedule: schedule,
+				semester: semester,
 			},
 			nodeIndexes: indexes,
 			// Get the children of the root
@@ -671,7 +730,7 @@ func (s Todo) getChildren(nodeId string) ([]*subscriptionItemNode, error) {
 	defer cancel()
 
 	rows, err := s.DB.QueryContext(ctx,
-		`SELECT id, subscription_name, schedule FROM todo.subscription 
+		`SELECT id, subscription_name, schedule, semester FROM todo.subscription 
 		JOIN 
 		todo.subscription_child ON id=child WHERE parent=$1`,
 		nodeId,
@@ -682,9 +741,9 @@ func (s Todo) getChildren(nodeId string) ([]*subscriptionItemNode, error) {
 
 	var children []*subscriptionItemNode
 	for rows.Next() {
-		var id, subscription_name, schedule string
+		var id, subscription_name, schedule, semester string
 
-		rows.Scan(&id, &subscription_name, &schedule)
+		rows.Scan(&id, &subscription_name, &schedule, &semester)
 
 		curChildren, err := s.getChildren(id)
 		if err != nil {
@@ -696,6 +755,7 @@ func (s Todo) getChildren(nodeId string) ([]*subscriptionItemNode, error) {
 				id:       id,
 				name:     subscription_name,
 				schedule: schedule,
+				semester: semester,
 			},
 			// Get the children
 			children: curChildren,
@@ -740,10 +800,14 @@ func (s Todo) getUserSubscriptionForest(userId string) ([]*subscriptionItemNode,
 		})
 	}
 
+	subscriptionsMutex.RLock()
+	roots := subscriptionForest
+	subscriptionsMutex.RUnlock()
+
 	// Create the users forest
 	forest := []*subscriptionItemNode{}
 	// Iterate over the roots
-	for _, root := range subscriptionForest {
+	for _, root := range roots {
 		// Get the tree from the root and insert it into the forest
 		tree := s.getUserSubscriptionTree(subscriptions, root, false)
 		forest = append(forest, tree)
@@ -800,3 +864,18 @@ func (s Todo) foldSubscriptionTree(root *subscriptionItemNode, acc []todoItem, f
 
 	return acc
 }
+
+// Returns all nodes of a subscription forest in the order they get displayed in
+func (s Todo) flattenSubscriptionForest(roots []*subscriptionItemNode) []subscriptionItemNode {
+	nodes := []subscriptionItemNode{}
+	var flatten func([]*subscriptionItemNode)
+	flatten = func(layer []*subscriptionItemNode) {
+		for _, node := range layer {
+			nodes = append(nodes, *node)
+			flatten(node.children)
+		}
+	}
+	flatten(roots)
+
+	return nodes
+}
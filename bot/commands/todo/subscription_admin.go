@@ -0,0 +1,382 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// Error of an admin command which can be shown to the admin as is
+type SubscriptionError struct {
+	Reason string
+}
+
+func (e *SubscriptionError) Error() string { return e.Reason }
+
+func (s Todo) subscriptionAdminHelp() string {
+	return "Usage: `todo subscribe admin [create|rename|schedule|delete|link|unlink|import|reload]`\n" +
+		"`todo subscribe admin create <id> <name>` creates a subscription without a schedule and `todo subscribe admin rename <id> <name>` renames it.\n" +
+		"`todo subscribe admin schedule <id> <F|H|B|N> <cron>` sets when items get created, e.g. `todo subscribe admin schedule 401-0212-16L F 0 18 * * FRI` during the spring semester. Use `none` instead of the semester to remove the schedule.\n" +
+		"`todo subscribe admin delete <id>` deletes a subscription and unsubscribes everyone from it.\n" +
+		"`todo subscribe admin [link|unlink] <parent> <child>` adds or removes a subscription as a child of another one.\n" +
+		"`todo subscribe admin import [F|H|B|N] [parent]` imports the courses of an attached timetable as subscriptions and lecture clippers, see `todo subscribe admin import help`.\n" +
+		"`todo subscribe admin reload` reloads the subscriptions from the database. Only owners of the bot may use these commands."
+}
+
+func (s Todo) subscriptionAdmin(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if !constants.IsOwner(ctx.Author.ID) {
+		s.replyAndDelete(bot, ctx, "Only owners of the bot may manage subscriptions.")
+		return nil
+	}
+	if len(args) == 0 || len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.subscriptionAdminHelp())
+		return nil
+	}
+
+	var err error
+	var content string
+	switch {
+	case args[0] == "create" && len(args) >= 3:
+		err = s.createSubscription(args[1], strings.Join(args[2:], " "))
+		content = "Created the subscription `" + args[1] + "`."
+	case args[0] == "rename" && len(args) >= 3:
+		err = s.renameSubscription(args[1], strings.Join(args[2:], " "))
+		content = "Renamed the subscription `" + args[1] + "`."
+	case args[0] == "schedule" && len(args) >= 3:
+		schedule, semester, parseErr := parseSubscriptionSchedule(args[2:])
+		if parseErr != nil {
+			s.replyAndDelete(bot, ctx, "Couldn't parse the schedule: "+parseErr.Error()+".")
+			return nil
+		}
+		err = s.setSubscriptionSchedule(args[1], schedule, semester)
+		content = "Set the schedule of the subscription `" + args[1] + "`."
+	case args[0] == "delete" && len(args) == 2:
+		err = s.removeSubscription(args[1])
+		content = "Deleted the subscription `" + args[1] + "`."
+	case args[0] == "link" && len(args) == 3:
+		err = s.linkSubscriptions(args[1], args[2])
+		content = "`" + args[2] + "` is now a child of `" + args[1] + "`."
+	case args[0] == "unlink" && len(args) == 3:
+		err = s.unlinkSubscriptions(args[1], args[2])
+		content = "`" + args[2] + "` is no longer a child of `" + args[1] + "`."
+	case args[0] == "import":
+		if len(args) == 2 && args[1] == "help" {
+			bot.ChannelMessageSend(ctx.ChannelID, s.timetableHelp())
+			return nil
+		}
+		return s.timetableImport(bot, ctx, args[1:])
+	case args[0] == "reload" && len(args) == 1:
+		content = "Reloaded the subscriptions."
+	default:
+		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.subscriptionAdminHelp())
+		return nil
+	}
+
+	if err != nil {
+		switch err := err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, "There is no subscription with the ID `"+err.Error()+"`.")
+			return nil
+		case *SubscriptionError:
+			s.replyAndDelete(bot, ctx, err.Reason)
+			return nil
+		default:
+			return err
+		}
+	}
+
+	// Changes take effect immediately, without restarting the bot
+	if err := s.reloadSubscriptions(); err != nil {
+		return err
+	}
+
+	log.Println(constants.Yellow, "User", ctx.Author.Username, "changed the subscriptions:", args)
+	bot.ChannelMessageSendReply(ctx.ChannelID, content, ctx.Reference())
+	return nil
+}
+
+// Parses a schedule of the form <semester> <cron>, or none for no schedule, and returns the schedule and semester
+func parseSubscriptionSchedule(args []string) (string, string, error) {
+	if len(args) == 1 && strings.ToLower(args[0]) == "none" {
+		return "", "", nil
+	}
+	if len(args) < 2 {
+		return "", "", fmt.Errorf("expected a semester and a schedule")
+	}
+
+	semester := strings.ToUpper(args[0])
+	if !contains([]string{"F", "H", "B", "N"}, semester) {
+		return "", "", fmt.Errorf("the semester has to be one of F, H, B and N")
+	}
+	schedule := strings.Join(args[1:], " ")
+	if len(schedule) > 64 {
+		return "", "", fmt.Errorf("the schedule is longer than 64 characters")
+	}
+	// The same parser creates the cronjobs, so any schedule it accepts works
+	if _, err := scheduleParser.Parse(schedule); err != nil {
+		return "", "", err
+	}
+	return schedule, semester, nil
+}
+
+// Checks that the ID and name of a subscription fit into the database
+func checkSubscriptionFields(id, name string) error {
+	if id == "" || len(id) > 20 {
+		return &SubscriptionError{"The ID of a subscription has to be between 1 and 20 characters long."}
+	}
+	if name == "" || len(name) > 128 {
+		return &SubscriptionError{"The name of a subscription has to be between 1 and 128 characters long."}
+	}
+	return nil
+}
+
+// Inserts the subscription with the ID, name, schedule and semester unless it exists already, affecting no rows then
+// Subscriptions without runs are never caught up on, so a run at their creation marks them as caught up until then
+const insertSubscriptionQuery = `WITH created AS (
+		INSERT INTO todo.subscription (id, subscription_name, schedule, semester) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING RETURNING id
+	)
+	INSERT INTO todo.subscription_run (subscription, fired_at) SELECT id, now() FROM created`
+
+// Creates a subscription without a schedule
+func (s Todo) createSubscription(id, name string) error {
+	if err := checkSubscriptionFields(id, name); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, insertSubscriptionQuery, id, name, "", "")
+	if err != nil {
+		return err
+	}
+	if created, err := result.RowsAffected(); err != nil {
+		return err
+	} else if created == 0 {
+		return &SubscriptionError{"There already is a subscription with the ID `" + id + "`."}
+	}
+
+	log.Printf("%s Created subscription %s; name: %s\n", constants.Blue, id, name)
+	return nil
+}
+
+// Renames the subscription
+// Returns an InvalidIDError if there is no such subscription
+func (s Todo) renameSubscription(id, name string) error {
+	if err := checkSubscriptionFields(id, name); err != nil {
+		return err
+	}
+	return s.updateSubscription(id, `UPDATE todo.subscription SET subscription_name=$2 WHERE id=$1`, name)
+}
+
+// Sets when the subscription creates items, never if the schedule is empty
+// A run marks it as caught up until now, so firings the old schedule missed aren't caught up on with the new one
+// Returns an InvalidIDError if there is no such subscription
+func (s Todo) setSubscriptionSchedule(id, schedule, semester string) error {
+	return s.updateSubscription(id, `WITH run AS (
+			INSERT INTO todo.subscription_run (subscription, fired_at) SELECT id, now() FROM todo.subscription WHERE id=$1 ON CONFLICT DO NOTHING
+		)
+		UPDATE todo.subscription SET schedule=$2, semester=$3 WHERE id=$1`, schedule, semester)
+}
+
+// Executes the update of the subscription with the ID as the first argument
+// Returns an InvalidIDError if there is no such subscription
+func (s Todo) updateSubscription(id, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if changed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if changed == 0 {
+		return &InvalidIDError{[]string{id}}
+	}
+
+	log.Printf("%s Updated subscription %s: %v\n", constants.Blue, id, args)
+	return nil
+}
+
+// Deletes the subscription together with its links, unsubscribing all users from it
+// Items it created are kept
+// Returns an InvalidIDError if there is no such subscription
+func (s Todo) removeSubscription(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM todo.subscribed_to WHERE subscription=$1`,
+		`DELETE FROM todo.subscription_child WHERE parent=$1 OR child=$1`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return err1
+			}
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM todo.subscription WHERE id=$1`, id)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		if err != nil {
+			return err
+		}
+		return &InvalidIDError{[]string{id}}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Deleted subscription %s\n", constants.Blue, id)
+	return nil
+}
+
+// Makes the child a child of the parent
+// Returns an InvalidIDError if one of them doesn't exist and a SubscriptionError if the link would create a cycle
+func (s Todo) linkSubscriptions(parent, child string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent links could create a cycle together
+	if _, err := tx.Exec(`LOCK TABLE todo.subscription_child IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM todo.subscription WHERE id=$1 OR id=$2`, parent, child)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	missing := deduplicate([]string{parent, child})
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		for i := range missing {
+			if missing[i] == id {
+				missing = append(missing[:i], missing[i+1:]...)
+				break
+			}
+		}
+	}
+	rows.Close()
+	if len(missing) != 0 {
+		if err := tx.Rollback(); err != nil {
+			return err
+		}
+		return &InvalidIDError{missing}
+	}
+
+	rows, err = tx.Query(`SELECT parent, child FROM todo.subscription_child`)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	children := map[string][]string{}
+	for rows.Next() {
+		var from, to string
+		rows.Scan(&from, &to)
+		children[from] = append(children[from], to)
+	}
+	rows.Close()
+
+	if createsCycle(children, parent, child) {
+		if err := tx.Rollback(); err != nil {
+			return err
+		}
+		return &SubscriptionError{"`" + child + "` can't be a child of `" + parent + "`, as `" + parent + "` is one of its descendants."}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO todo.subscription_child (parent, child) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		parent,
+		child,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Linked subscription %s as a child of %s\n", constants.Blue, child, parent)
+	return nil
+}
+
+// Returns whether adding the link from parent to child creates a cycle, given the children of each subscription
+func createsCycle(children map[string][]string, parent, child string) bool {
+	visited := map[string]bool{}
+	stack := []string{child}
+	for len(stack) != 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if node == parent {
+			return true
+		}
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+		stack = append(stack, children[node]...)
+	}
+	return false
+}
+
+// Removes the child from the children of the parent
+// Returns a SubscriptionError if it isn't a child of the parent
+func (s Todo) unlinkSubscriptions(parent, child string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx,
+		`DELETE FROM todo.subscription_child WHERE parent=$1 AND child=$2`,
+		parent,
+		child,
+	)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return &SubscriptionError{"`" + child + "` isn't a child of `" + parent + "`."}
+	}
+
+	log.Printf("%s Unlinked subscription %s from %s\n", constants.Blue, child, parent)
+	return nil
+}
@@ -0,0 +1,132 @@
+package todo
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatesCycle(t *testing.T) {
+	children := map[string][]string{
+		"Sem-02": {"401-0212-16L", "252-0028-00L"},
+		"Sem-04": {"252-0063-00L"},
+	}
+	tests := []struct {
+		parent, child string
+		expected      bool
+	}{
+		{"Sem-02", "Sem-04", false},
+		{"Sem-04", "252-0028-00L", false},
+		{"401-0212-16L", "Sem-02", true},
+		{"252-0063-00L", "Sem-04", true},
+		{"Sem-02", "Sem-02", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, createsCycle(children, test.parent, test.child), test)
+	}
+
+	// Cycles through several levels are found as well
+	children["Sem-04"] = append(children["Sem-04"], "Sem-02")
+	assert.True(t, createsCycle(children, "252-0028-00L", "Sem-04"))
+}
+
+func TestParseSubscriptionSchedule(t *testing.T) {
+	tests := []struct {
+		input                      []string
+		expectedSchedule, semester string
+		valid                      bool
+	}{
+		{[]string{"none"}, "", "", true},
+		{[]string{"F", "0", "18", "*", "*", "FRI"}, "0 18 * * FRI", "F", true},
+		{[]string{"b", "30", "8", "*", "*", "1-5"}, "30 8 * * 1-5", "B", true},
+		{[]string{"X", "0", "18", "*", "*", "FRI"}, "", "", false},
+		{[]string{"F", "0", "18", "*", "*"}, "", "", false},
+		{[]string{"F", "61", "18", "*", "*", "FRI"}, "", "", false},
+		{[]string{"F"}, "", "", false},
+	}
+
+	for _, test := range tests {
+		schedule, semester, err := parseSubscriptionSchedule(test.input)
+		if test.valid {
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedSchedule, schedule)
+			assert.Equal(t, test.semester, semester)
+		} else {
+			assert.NotNil(t, err, test.input)
+		}
+	}
+}
+
+func expectLinkChecks(parent, child string, existing []string, edges [][2]string) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`LOCK TABLE todo.subscription_child`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range existing {
+		rows.AddRow(id)
+	}
+	dbMock.ExpectQuery(`SELECT id FROM todo.subscription WHERE`).WithArgs(parent, child).WillReturnRows(rows)
+	if len(existing) != 2 {
+		return
+	}
+	edgeRows := sqlmock.NewRows([]string{"parent", "child"})
+	for _, edge := range edges {
+		edgeRows.AddRow(edge[0], edge[1])
+	}
+	dbMock.ExpectQuery(`SELECT parent, child FROM todo.subscription_child`).WillReturnRows(edgeRows)
+}
+
+func TestLinkSubscriptions(t *testing.T) {
+	expectLinkChecks("Sem-02", "401-0212-16L", []string{"Sem-02", "401-0212-16L"}, nil)
+	dbMock.ExpectExec(`INSERT INTO todo.subscription_child`).
+		WithArgs("Sem-02", "401-0212-16L").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
+
+	assert.Nil(t, mockTodo.linkSubscriptions("Sem-02", "401-0212-16L"))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestLinkSubscriptionsCycle(t *testing.T) {
+	expectLinkChecks("401-0212-16L", "Sem-02", []string{"Sem-02", "401-0212-16L"}, [][2]string{{"Sem-02", "401-0212-16L"}})
+	dbMock.ExpectRollback()
+
+	err := mockTodo.linkSubscriptions("401-0212-16L", "Sem-02")
+
+	assert.IsType(t, &SubscriptionError{}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestLinkSubscriptionsUnknown(t *testing.T) {
+	expectLinkChecks("Sem-02", "Sem-99", []string{"Sem-02"}, nil)
+	dbMock.ExpectRollback()
+
+	err := mockTodo.linkSubscriptions("Sem-02", "Sem-99")
+
+	assert.Equal(t, &InvalidIDError{[]string{"Sem-99"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateSubscription(t *testing.T) {
+	// New subscriptions get a run, so their missed firings get caught up on
+	dbMock.ExpectExec(`WITH created AS \(\s+INSERT INTO todo.subscription .* RETURNING id\s+\)\s+INSERT INTO todo.subscription_run`).
+		WithArgs("Sem-02", "2nd Semester", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, mockTodo.createSubscription("Sem-02", "2nd Semester"))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateSubscriptionExisting(t *testing.T) {
+	dbMock.ExpectExec(`INSERT INTO todo.subscription`).
+		WithArgs("Sem-02", "2nd Semester", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := mockTodo.createSubscription("Sem-02", "2nd Semester")
+
+	assert.IsType(t, &SubscriptionError{}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+
+	assert.IsType(t, &SubscriptionError{}, mockTodo.createSubscription("an-id-which-is-far-too-long", "Name"))
+}
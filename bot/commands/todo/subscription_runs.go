@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/calendar"
+
+	"github.com/robfig/cron"
+)
+
+// Creates the items of the firings of the subscriptions missed since their last recorded firing, within their semesters
+// Subscriptions get a run when they are created or their schedule changes, so they are caught up on from then on
+func (s Todo) catchUpSubscriptions(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	// Subscriptions created outside of the bot, such as the ones of the seed files, get their first run now
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO todo.subscription_run (subscription, fired_at)
+		SELECT s.id, $1 FROM todo.subscription s
+		WHERE NOT EXISTS (SELECT 1 FROM todo.subscription_run r WHERE r.subscription=s.id)`, now); err != nil {
+		return err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT s.id, s.schedule, s.semester, MAX(r.fired_at) FROM todo.subscription s
+		JOIN todo.subscription_run r ON r.subscription=s.id
+		WHERE s.schedule != ''
+		GROUP BY s.id`)
+	if err != nil {
+		return err
+	}
+
+	type lastRun struct {
+		id, schedule, semester string
+		firedAt                time.Time
+	}
+	runs := []lastRun{}
+	for rows.Next() {
+		run := lastRun{}
+		rows.Scan(&run.id, &run.schedule, &run.semester, &run.firedAt)
+		runs = append(runs, run)
+	}
+	rows.Close()
+	if len(runs) == 0 {
+		return nil
+	}
+
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		schedule, err := scheduleParser.Parse(run.schedule)
+		if err != nil {
+			log.Println(constants.Red, "Failed to parse the schedule of subscription", run.id, err)
+			continue
+		}
+		missed, dropped := missedRuns(schedule, academicCalendar, run.semester, run.firedAt, now)
+		if len(dropped) != 0 {
+			log.Printf("%s Dropped %d firings of subscription %s missed in earlier semesters; First: %s, Last: %s\n", constants.Blue, len(dropped), run.id, dropped[0].Format(time.RFC3339), dropped[len(dropped)-1].Format(time.RFC3339))
+		}
+		for _, firedAt := range missed {
+			if err := s.createSubscriptionItem(run.id, firedAt); err != nil {
+				return err
+			}
+			log.Printf("%s Caught up on subscription %s; Missed firing: %s\n", constants.Blue, run.id, firedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Returns the firings of the schedule after the last one until now which lie within the semester
+// Only firings of the latest semester, including the break after it, are caught up on and the ones of earlier semesters are dropped
+// Firings in the last days of a semester are thus still caught up on during the following break
+func missedRuns(schedule cron.Schedule, academicCalendar calendar.Calendar, semester string, last, now time.Time) (missed, dropped []time.Time) {
+	last = last.In(now.Location())
+	dropped = []time.Time{}
+	if start, found := academicCalendar.SemesterStart(now); found && last.Before(start) {
+		// Firings at the start itself belong to the latest semester
+		dropped = activeFirings(schedule, academicCalendar, semester, last, start.Add(-time.Nanosecond))
+		last = start.Add(-time.Nanosecond)
+	}
+	return activeFirings(schedule, academicCalendar, semester, last, now), dropped
+}
@@ -0,0 +1,97 @@
+package todo
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/calendar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissedRuns(t *testing.T) {
+	academicCalendar := calendar.New([]calendar.Period{
+		{Name: "Fall semester 2022", Semester: calendar.Fall, Start: time.Date(2022, 9, 19, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)},
+	})
+	friday, _ := scheduleParser.Parse("0 18 * * FRI")
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2022, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		semester string
+		last     time.Time
+		now      time.Time
+		expected []time.Time
+		dropped  []time.Time
+	}{
+		{"nothing missed", calendar.Fall, at(10, 7, 18), at(10, 10, 9), []time.Time{}, []time.Time{}},
+		{"missed firings", calendar.Fall, at(9, 30, 18), at(10, 14, 18), []time.Time{at(10, 7, 18), at(10, 14, 18)}, []time.Time{}},
+		{"all firings of the semester", calendar.Fall, at(9, 23, 18), at(11, 1, 9), []time.Time{at(9, 30, 18), at(10, 7, 18), at(10, 14, 18), at(10, 21, 18), at(10, 28, 18)}, []time.Time{}},
+		{"outside of the semester", calendar.Fall, at(9, 9, 18), at(9, 24, 9), []time.Time{at(9, 23, 18)}, []time.Time{}},
+		// Firings before the start of the semester are dropped
+		{"not restricted to semesters", calendar.None, at(9, 9, 18), at(9, 24, 9), []time.Time{at(9, 23, 18)}, []time.Time{at(9, 16, 18)}},
+		// The break belongs to the semester before it, whose last firings are still caught up on
+		{"during the break", calendar.None, at(12, 16, 18), time.Date(2023, 1, 10, 9, 0, 0, 0, time.UTC), []time.Time{at(12, 23, 18), at(12, 30, 18), time.Date(2023, 1, 6, 18, 0, 0, 0, time.UTC)}, []time.Time{}},
+		{"end of the semester during the break", calendar.Fall, at(12, 9, 18), time.Date(2023, 1, 10, 9, 0, 0, 0, time.UTC), []time.Time{at(12, 16, 18), at(12, 23, 18)}, []time.Time{}},
+	}
+
+	for _, test := range tests {
+		missed, dropped := missedRuns(friday, academicCalendar, test.semester, test.last, test.now)
+		assert.Equal(t, test.expected, missed, test.name)
+		assert.Equal(t, test.dropped, dropped, test.name)
+	}
+
+	// Without a semester before now, all missed firings are caught up on
+	missed, dropped := missedRuns(friday, calendar.New([]calendar.Period{}), calendar.None, at(8, 1, 9), at(10, 1, 9))
+	assert.Len(t, missed, 9)
+	assert.Empty(t, dropped)
+}
+
+func TestCreateSubscriptionItemOnce(t *testing.T) {
+	firedAt := time.Date(2022, 10, 14, 18, 0, 0, 0, time.UTC)
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`INSERT INTO todo.subscription_run`).
+		WithArgs("401-0212-16L", firedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectRollback()
+
+	assert.Nil(t, mockTodo.createSubscriptionItem("401-0212-16L", firedAt))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCatchUpSubscriptionsNeverRun(t *testing.T) {
+	now := time.Now()
+	// Subscriptions without runs get one, so they are caught up on from now on
+	dbMock.ExpectExec(`INSERT INTO todo.subscription_run \(subscription, fired_at\)\s+SELECT s.id, \$1 FROM todo.subscription s`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectQuery(`SELECT s.id, s.schedule, s.semester, MAX\(r.fired_at\) FROM todo.subscription s`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "schedule", "semester", "max"}))
+
+	assert.Nil(t, mockTodo.catchUpSubscriptions(now))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateSubscriptionItemRollback(t *testing.T) {
+	firedAt := time.Date(2022, 10, 14, 18, 0, 0, 0, time.UTC)
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`INSERT INTO todo.subscription_run`).
+		WithArgs("401-0212-16L", firedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery(`SELECT subscription_name FROM todo.subscription`).
+		WithArgs("401-0212-16L").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_name"}).AddRow("Analysis 1"))
+	// The task is created within the transaction, so it gets discarded as well
+	dbMock.ExpectQuery(`INSERT INTO todo.task`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	dbMock.ExpectQuery(`WITH RECURSIVE ids`).
+		WithArgs("401-0212-16L").
+		WillReturnError(sql.ErrConnDone)
+	dbMock.ExpectRollback()
+
+	assert.Equal(t, sql.ErrConnDone, mockTodo.createSubscriptionItem("401-0212-16L", firedAt))
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
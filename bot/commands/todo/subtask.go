@@ -0,0 +1,503 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+// Columns with the amount of subtasks of the task t and the amount of them the user of the list l checked off
+const subtaskProgressColumns = `(SELECT COUNT(*) FROM todo.subtask AS s WHERE s.task=t.id),
+	(SELECT COUNT(*) FROM todo.completed_subtask AS c WHERE c.task=t.id AND c.discord_user=l.discord_user)`
+
+// Maximum length of the title of a subtask
+const maxSubtaskTitleLength = 100
+
+// A step of a todo item, referred to as task.position, e.g. 12.3
+type subtask struct {
+	Task     int
+	Position int // Starting at 1, doesn't change when other subtasks get deleted
+	Title    string
+	Done     bool // Whether the user checked it off
+}
+
+func (s Todo) subtaskHelp() string {
+	return "Usage: `todo subtask [add|list|delete|uncheck]`\n`todo subtask add 12 Read script; Solve exercises` adds subtasks to the item with ID 12, separate multiple subtasks with `;`.\n`todo subtask list 12` lists the subtasks of the item with ID 12.\n`todo subtask delete 12.3` deletes its third subtask and `todo subtask uncheck 12.3` marks it as not done again.\nCheck off subtasks with `todo done 12.3`, the item is marked as done once all of its subtasks are."
+}
+
+func (s Todo) Subtask(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.subtaskHelp())
+		return nil
+	}
+
+	// Replies to invalid IDs instead of failing
+	handleErr := func(err error) error {
+		switch err.(type) {
+		case *InvalidIDError:
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("You supplied an invalid ID: %v", err))
+			return nil
+		default:
+			return err
+		}
+	}
+
+	switch args[0] {
+	case "add":
+		titles := []string{}
+		if len(args) > 2 {
+			for _, title := range strings.Split(strings.Join(args[2:], " "), ";") {
+				if title = strings.TrimSpace(title); title != "" {
+					titles = append(titles, title)
+				}
+			}
+		}
+		if len(titles) == 0 {
+			s.replyAndDelete(bot, ctx, "Please supply the ID of the item and the titles of the subtasks.\n"+s.subtaskHelp())
+			return nil
+		}
+		for _, title := range titles {
+			if len(title) > maxSubtaskTitleLength {
+				s.replyAndDelete(bot, ctx, fmt.Sprintf("Subtask titles can be at most %d characters long.", maxSubtaskTitleLength))
+				return nil
+			}
+		}
+
+		added, err := s.addSubtasks(ctx.Author.ID, args[1], titles)
+		if err != nil {
+			return handleErr(err)
+		}
+		s.replyAndDelete(bot, ctx, "Successfully added "+strings.Join(added, ", ")+".")
+	case "list", "show":
+		if len(args) != 2 {
+			s.replyAndDelete(bot, ctx, "Please supply the ID of the item.\n"+s.subtaskHelp())
+			return nil
+		}
+		items, err := s.getOwnItems(ctx.Author.ID, []string{args[1]})
+		if err != nil {
+			return handleErr(err)
+		}
+		subtasks, err := s.getSubtasks(ctx.Author.ID, items[0].ID)
+		if err != nil {
+			return err
+		}
+		bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+		bot.ChannelMessageSendEmbed(ctx.ChannelID, subtasksToEmbed(items[0], subtasks, ctx.Author))
+	case "delete", "remove", "uncheck":
+		ids, err := parseSubtaskIds(args[1:])
+		if err != nil {
+			s.replyAndDelete(bot, ctx, "Error parsing IDs, subtasks are given as `id.position`, e.g. `12.3`.\n"+s.subtaskHelp())
+			return nil
+		}
+		if args[0] == "uncheck" {
+			if err := s.uncheckSubtasks(ctx.Author.ID, ids); err != nil {
+				return handleErr(err)
+			}
+			s.replyAndDelete(bot, ctx, "Successfully marked "+strings.Join(ids, ", ")+" as not done.")
+		} else {
+			if err := s.deleteSubtasks(ctx.Author.ID, ids); err != nil {
+				return handleErr(err)
+			}
+			s.replyAndDelete(bot, ctx, "Successfully deleted "+strings.Join(ids, ", ")+".")
+		}
+	default:
+		bot.ChannelMessageSend(ctx.ChannelID, "Couldn't interpret command.\n"+s.subtaskHelp())
+	}
+	return nil
+}
+
+// Returns an embed listing the subtasks of the item and whether the user checked them off
+func subtasksToEmbed(item todoItem, subtasks []subtask, user *discord.User) *discord.MessageEmbed {
+	lines := []string{}
+	done := 0
+	for _, subtask := range subtasks {
+		check := "⬜"
+		if subtask.Done {
+			check = constants.Emojis["success"]
+			done++
+		}
+		lines = append(lines, fmt.Sprintf("%s `%d.%d` %s", check, subtask.Task, subtask.Position, subtask.Title))
+	}
+	description := "This item has no subtasks yet."
+	if len(lines) != 0 {
+		description = fmt.Sprintf("`%d/%d` done\n%s", done, len(subtasks), strings.Join(lines, "\n"))
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: user.Username + "s TODOs",
+		},
+		Title:       fmt.Sprintf("%d: %s", item.ID, item.Title),
+		Description: description,
+		Color:       todoEmbedColor,
+		Footer: &discord.MessageEmbedFooter{
+			Text:    "Invoked by " + user.Username,
+			IconURL: user.AvatarURL(""),
+		},
+	}
+}
+
+// Parses IDs which all have to be the ones of subtasks
+func parseSubtaskIds(rawArr []string) ([]string, error) {
+	ids, err := parseIds(rawArr)
+	if err != nil {
+		return nil, err
+	}
+	if tasks, _ := splitSubtaskIds(ids); len(tasks) != 0 {
+		return nil, fmt.Errorf("invalid subtask id format")
+	}
+	return ids, nil
+}
+
+// Splits parsed IDs into the ones of items and the ones of subtasks
+func splitSubtaskIds(ids []string) (tasks, subtasks []string) {
+	tasks, subtasks = []string{}, []string{}
+	for _, id := range ids {
+		if strings.Contains(id, ".") {
+			subtasks = append(subtasks, id)
+		} else {
+			tasks = append(tasks, id)
+		}
+	}
+	return tasks, subtasks
+}
+
+// Returns an InvalidIDError if any of the IDs is the one of a subtask, for actions only items support
+func checkNoSubtaskIds(ids []string) error {
+	if _, subtasks := splitSubtaskIds(ids); len(subtasks) != 0 {
+		return &InvalidIDError{subtasks}
+	}
+	return nil
+}
+
+// Returns the IDs of the items of the subtask IDs, without duplicates
+func subtaskParents(ids []string) []string {
+	parents := []string{}
+	for _, id := range ids {
+		parent, _, _ := strings.Cut(id, ".")
+		parents = append(parents, parent)
+	}
+	return deduplicate(parents)
+}
+
+// Returns the ID of the users task with the ID, copying it first if the user didn't create it,
+// so changes to its subtasks only affect the user
+// Returns an InvalidIDError if the task isn't in any of the users lists
+func (s Todo) ownTask(userId, taskId string) (int, error) {
+	items, err := s.getOwnItems(userId, []string{taskId})
+	if err != nil {
+		return 0, err
+	}
+	if items[0].Creator == userId {
+		return items[0].ID, nil
+	}
+	copyId, _, err := s.editItem(userId, items[0])
+	return copyId, err
+}
+
+// Returns the subtasks of the task, marked as done if the user checked them off
+func (s Todo) getSubtasks(userId string, taskId int) ([]subtask, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT s.position, s.title, c.discord_user IS NOT NULL FROM todo.subtask AS s
+		LEFT JOIN todo.completed_subtask AS c ON c.task=s.task AND c.position=s.position AND c.discord_user=$1
+		WHERE s.task=$2 ORDER BY s.position`,
+		userId,
+		taskId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subtasks := []subtask{}
+	for rows.Next() {
+		next := subtask{Task: taskId}
+		rows.Scan(&next.Position, &next.Title, &next.Done)
+		subtasks = append(subtasks, next)
+	}
+	return subtasks, nil
+}
+
+// Adds subtasks with the titles to the users item and returns their IDs
+// Returns an InvalidIDError if the item isn't in any of the users lists
+func (s Todo) addSubtasks(userId, rawTaskId string, titles []string) ([]string, error) {
+	taskId, err := s.ownTask(userId, rawTaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// New subtasks go after the existing ones
+	var last int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(position), 0) FROM todo.subtask WHERE task=$1`, taskId).Scan(&last); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, err1
+		}
+		return nil, err
+	}
+
+	ids := []string{}
+	for i, title := range titles {
+		if _, err := tx.Exec(`INSERT INTO todo.subtask (task, position, title) VALUES ($1, $2, $3)`,
+			taskId,
+			last+i+1,
+			title,
+		); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, err1
+			}
+			return nil, err
+		}
+		ids = append(ids, fmt.Sprintf("%d.%d", taskId, last+i+1))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("%s Added subtasks %v to users %s item %d\n", constants.Blue, ids, userId, taskId)
+	return ids, nil
+}
+
+// Deletes the subtasks with the IDs from the users items
+// Returns an InvalidIDError if invalid IDs were supplied
+func (s Todo) deleteSubtasks(userId string, ids []string) error {
+	parents := subtaskParents(ids)
+	items, err := s.getOwnItems(userId, parents)
+	if err != nil {
+		return err
+	}
+
+	// Check first if all subtasks exist
+	invalid := []string{}
+	for _, item := range items {
+		subtasks, err := s.getSubtasks(userId, item.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			parent, position, _ := strings.Cut(id, ".")
+			if parent != fmt.Sprint(item.ID) {
+				continue
+			}
+			found := false
+			for _, subtask := range subtasks {
+				if fmt.Sprint(subtask.Position) == position {
+					found = true
+					break
+				}
+			}
+			if !found {
+				invalid = append(invalid, id)
+			}
+		}
+	}
+	if len(invalid) != 0 {
+		return &InvalidIDError{invalid}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	for _, parent := range parents {
+		taskId, err := s.ownTask(userId, parent)
+		if err != nil {
+			return err
+		}
+		positions := []int{}
+		for _, id := range ids {
+			if rawParent, rawPosition, _ := strings.Cut(id, "."); rawParent == parent {
+				position, _ := strconv.Atoi(rawPosition)
+				positions = append(positions, position)
+			}
+		}
+		if _, err := s.DB.ExecContext(ctx, `DELETE FROM todo.subtask WHERE task=$1 AND position=ANY($2)`,
+			taskId,
+			pq.Array(positions),
+		); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%s Deleted users %s subtasks %v\n", constants.Blue, userId, ids)
+	return nil
+}
+
+// Checks off the subtasks with the IDs, which have to belong to active items of the user
+// Items whose subtasks are then all checked off are marked as done, their IDs and the ID of the journaled operation are returned
+// The operation is 0 if all subtasks were checked off already
+// Returns an InvalidIDError if invalid IDs were supplied
+func (s Todo) completeSubtasks(userId string, ids []string) ([]string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	// Check first if all IDs are valid
+	rows, err := s.DB.QueryContext(ctx, `SELECT s.task || '.' || s.position FROM todo.subtask AS s
+		JOIN todo.active AS a ON a.task=s.task WHERE a.discord_user=$1 AND (s.task || '.' || s.position)=ANY($2)`,
+		userId,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		found[id] = true
+	}
+	rows.Close()
+
+	invalid := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) != 0 {
+		return nil, 0, &InvalidIDError{invalid}
+	}
+
+	// Checking off the subtasks and completing their items is one operation, so undoing it also unchecks the subtasks
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err = tx.Query(`INSERT INTO todo.completed_subtask (discord_user, task, position)
+		SELECT $1, task, position FROM todo.subtask WHERE (task || '.' || position)=ANY($2) ON CONFLICT DO NOTHING
+		RETURNING task || '.' || position`,
+		userId,
+		pq.Array(ids),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, 0, err1
+		}
+		return nil, 0, err
+	}
+	checked := []string{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		checked = append(checked, id)
+	}
+	rows.Close()
+
+	// Find the items whose subtasks are all checked off now
+	rows, err = tx.Query(`SELECT s.task FROM todo.subtask AS s
+		LEFT JOIN todo.completed_subtask AS c ON c.task=s.task AND c.position=s.position AND c.discord_user=$1
+		WHERE s.task=ANY($2) GROUP BY s.task HAVING COUNT(*)=COUNT(c.discord_user) ORDER BY s.task`,
+		userId,
+		pq.Array(subtaskParents(ids)),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, 0, err1
+		}
+		return nil, 0, err
+	}
+	done := []string{}
+	for rows.Next() {
+		var task string
+		rows.Scan(&task)
+		done = append(done, task)
+	}
+	rows.Close()
+
+	completed := []string{}
+	if len(done) != 0 {
+		if completed, err = moveItems(tx, userId, done, "active", "completed"); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, 0, err1
+			}
+			return nil, 0, err
+		}
+	}
+
+	operation, err := recordCheck(tx, userId, checked, moveChanges(completed, "active", "completed"))
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, 0, err1
+		}
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+
+	log.Printf("%s Checked off users %s subtasks %v, completing items %v\n", constants.Blue, userId, ids, completed)
+	notifyBoards(userId)
+	return completed, operation, nil
+}
+
+// Marks the users subtasks with the IDs as not done
+// Returns an InvalidIDError if any of them wasn't checked off
+func (s Todo) uncheckSubtasks(userId string, ids []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`DELETE FROM todo.completed_subtask WHERE discord_user=$1 AND (task || '.' || position)=ANY($2)
+		RETURNING task || '.' || position`,
+		userId,
+		pq.Array(ids),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		found[id] = true
+	}
+	rows.Close()
+
+	invalid := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) != 0 {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return &InvalidIDError{invalid}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Unchecked users %s subtasks %v\n", constants.Blue, userId, ids)
+	return nil
+}
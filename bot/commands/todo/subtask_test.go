@@ -0,0 +1,172 @@
+package todo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSubtaskIds(t *testing.T) {
+	tests := []struct {
+		input            []string
+		expectedTasks    []string
+		expectedSubtasks []string
+	}{
+		{[]string{}, []string{}, []string{}},
+		{[]string{"1", "2"}, []string{"1", "2"}, []string{}},
+		{[]string{"1.1", "2.3"}, []string{}, []string{"1.1", "2.3"}},
+		{[]string{"1", "12.3", "4"}, []string{"1", "4"}, []string{"12.3"}},
+	}
+
+	for _, test := range tests {
+		tasks, subtasks := splitSubtaskIds(test.input)
+		assert.Equal(t, test.expectedTasks, tasks)
+		assert.Equal(t, test.expectedSubtasks, subtasks)
+	}
+}
+
+func TestParseSubtaskIds(t *testing.T) {
+	tests := []struct {
+		input          []string
+		expectedOutput []string
+		expectedError  error
+	}{
+		{[]string{"12.3"}, []string{"12.3"}, nil},
+		{[]string{"12.3,", "1.1"}, []string{"12.3", "1.1"}, nil},
+		{[]string{"12"}, nil, fmt.Errorf("invalid subtask id format")},
+		{[]string{"12.3", "4"}, nil, fmt.Errorf("invalid subtask id format")},
+		{[]string{"a"}, nil, fmt.Errorf("invalid id format")},
+	}
+
+	for _, test := range tests {
+		result, err := parseSubtaskIds(test.input)
+		assert.Equal(t, test.expectedOutput, result)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
+
+func TestSubtaskParents(t *testing.T) {
+	assert.Equal(t, []string{"12", "3"}, subtaskParents([]string{"12.1", "12.2", "3.1"}))
+}
+
+func TestChangeItemStatusSubtaskIDs(t *testing.T) {
+	_, err := mockTodo.changeItemsStatus("0", []string{"1", "2.1"}, "x", "y")
+
+	assert.Equal(t, &InvalidIDError{[]string{"2.1"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCompleteSubtasks(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.subtask AS s JOIN todo.active`).
+		WithArgs("0", pq.Array([]string{"1.1", "2.2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1.1").AddRow("2.2"))
+	dbMock.ExpectBegin()
+	// Subtask 1.1 was checked off already
+	dbMock.ExpectQuery(`INSERT INTO todo.completed_subtask (.+) ON CONFLICT DO NOTHING`).
+		WithArgs("0", pq.Array([]string{"1.1", "2.2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2.2"))
+	dbMock.ExpectQuery(`SELECT s.task FROM todo.subtask AS s LEFT JOIN (.+) HAVING COUNT\(\*\)=COUNT\(c.discord_user\)`).
+		WithArgs("0", pq.Array([]string{"1", "2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("2"))
+
+	// The item whose subtasks are all done gets marked as done in the same operation
+	dbMock.ExpectQuery(`DELETE FROM todo.active (.+) RETURNING task`).
+		WithArgs("0", pq.Array([]string{"2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}).AddRow("2"))
+	dbMock.ExpectExec(`INSERT INTO todo.completed`).
+		WithArgs("0", pq.Array([]string{"2"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery(`INSERT INTO todo.operation \(discord_user, action\)`).
+		WithArgs("0", checkAction).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	dbMock.ExpectExec(`INSERT INTO todo.operation_subtask`).
+		WithArgs(4, pq.Array([]string{"2.2"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO todo.operation_change`).
+		WithArgs(4, pq.Array([]int64{2}), pq.Array([]string{"active"}), pq.Array([]string{"completed"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	completed, operation, err := mockTodo.completeSubtasks("0", []string{"1.1", "2.2"})
+
+	assert.Equal(t, []string{"2"}, completed)
+	assert.Equal(t, 4, operation)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCompleteSubtasksAlreadyChecked(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.subtask AS s JOIN todo.active`).
+		WithArgs("0", pq.Array([]string{"1.1"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1.1"))
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`INSERT INTO todo.completed_subtask (.+) ON CONFLICT DO NOTHING`).
+		WithArgs("0", pq.Array([]string{"1.1"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	dbMock.ExpectQuery(`SELECT s.task FROM todo.subtask AS s LEFT JOIN`).
+		WithArgs("0", pq.Array([]string{"1"})).
+		WillReturnRows(sqlmock.NewRows([]string{"task"}))
+	// Nothing changed, so there is nothing to undo
+	dbMock.ExpectCommit()
+
+	completed, operation, err := mockTodo.completeSubtasks("0", []string{"1.1"})
+
+	assert.Empty(t, completed)
+	assert.Equal(t, 0, operation)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCompleteSubtasksWrongIDs(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT (.+) FROM todo.subtask AS s JOIN todo.active`).
+		WithArgs("0", pq.Array([]string{"1.1", "1.2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1.1"))
+
+	_, _, err := mockTodo.completeSubtasks("0", []string{"1.1", "1.2"})
+
+	assert.Equal(t, &InvalidIDError{[]string{"1.2"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUncheckSubtasks(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.completed_subtask (.+) RETURNING`).
+		WithArgs("0", pq.Array([]string{"1.1"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1.1"))
+	dbMock.ExpectCommit()
+
+	err := mockTodo.uncheckSubtasks("0", []string{"1.1"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUncheckSubtasksWrongIDs(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`DELETE FROM todo.completed_subtask (.+) RETURNING`).
+		WithArgs("0", pq.Array([]string{"1.1", "1.2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1.1"))
+	dbMock.ExpectRollback()
+
+	err := mockTodo.uncheckSubtasks("0", []string{"1.1", "1.2"})
+
+	assert.Equal(t, &InvalidIDError{[]string{"1.2"}}, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetSubtasks(t *testing.T) {
+	dbMock.ExpectQuery(`SELECT s.position, s.title, (.+) FROM todo.subtask AS s`).
+		WithArgs("0", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"position", "title", "done"}).
+			AddRow(1, "Read script", true).
+			AddRow(3, "Solve exercises", false))
+
+	subtasks, err := mockTodo.getSubtasks("0", 1)
+
+	assert.Equal(t, []subtask{{1, 1, "Read script", true}, {1, 3, "Solve exercises", false}}, subtasks)
+	assert.Nil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,454 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/commands/todo/timetable"
+	"github.com/DominicWuest/Alphie/bot/constants"
+	"github.com/DominicWuest/Alphie/calendar"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	maxTimetableCourses    = 25   // Maximum amount of courses imported at once, each is a field of the preview
+	embedFooterLength      = 2048 // Maximum length of the footer of an embed
+	timetableConfirmPrefix = "todo.timetable-confirm:"
+	timetableCancelPrefix  = "todo.timetable-cancel:"
+)
+
+// Names of the semesters in previews
+var semesterNames = map[string]string{
+	calendar.Spring: "the spring semester",
+	calendar.Fall:   "the fall semester",
+	calendar.Both:   "both semesters",
+	calendar.None:   "the whole year",
+}
+
+// Timetable waiting for the confirmation of the owner who imports it
+type pendingTimetable struct {
+	Courses  []timetable.Course
+	Semester string
+	Parent   string // Subscription the courses become children of, empty for none
+}
+
+// Outcome of importing a timetable
+type timetableImport struct {
+	Subscriptions []string // IDs of the created subscriptions
+	Clippers      []string // IDs of the created lecture clippers
+	Existing      []string // IDs of the subscriptions and clippers which existed already and were left as they are
+}
+
+func (s Todo) timetableHelp() string {
+	return "Usage: attach an iCalendar file to `todo subscribe admin import [F|H|B|N] [parent]`\n" +
+		"Turns the weekly events of a timetable, such as the one of myStudies, into subscriptions and lecture clippers. Events need the number of their course, e.g. `252-0217-00L Computer Systems V`.\n" +
+		"The semester is taken from the calendar if it isn't given, and the subscriptions become children of the parent if one is given. You can check the rows before they get added."
+}
+
+func (s Todo) timetableImport(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if len(ctx.Message.Attachments) == 0 || len(args) > 2 {
+		bot.ChannelMessageSend(ctx.ChannelID, s.timetableHelp())
+		return nil
+	}
+
+	pending := pendingTimetable{}
+	for _, arg := range args {
+		if semester := strings.ToUpper(arg); semesterNames[semester] != "" {
+			pending.Semester = semester
+		} else {
+			pending.Parent = arg
+		}
+	}
+
+	attachment := ctx.Message.Attachments[0]
+	if attachment.Size > maxImportSize {
+		s.replyAndDelete(bot, ctx, fmt.Sprintf("Files can be at most %d KB large.", maxImportSize/1024))
+		return nil
+	}
+	data, err := downloadImport(attachment.URL)
+	if err != nil {
+		return err
+	}
+	parsed, err := timetable.Parse(data, time.Local)
+	if err != nil {
+		s.replyAndDelete(bot, ctx, "Couldn't import the timetable: "+err.Error()+".\n"+s.timetableHelp())
+		return nil
+	}
+	switch {
+	case len(parsed.Courses) == 0:
+		s.replyAndDelete(bot, ctx, "The timetable doesn't contain any weekly events of courses.")
+		return nil
+	case len(parsed.Courses) > maxTimetableCourses:
+		s.replyAndDelete(bot, ctx, fmt.Sprintf("You can import at most %d courses at once, the timetable contains %d.", maxTimetableCourses, len(parsed.Courses)))
+		return nil
+	}
+	pending.Courses = parsed.Courses
+
+	if pending.Semester == "" {
+		academicCalendar, err := s.getCalendar()
+		if err != nil {
+			return err
+		}
+		semester, found := academicCalendar.Semester(timetableStart(pending.Courses))
+		if !found {
+			s.replyAndDelete(bot, ctx, "The timetable doesn't start during a semester of the calendar, give the semester as one of F, H, B and N.")
+			return nil
+		}
+		pending.Semester = semester.Semester
+	}
+	if pending.Parent != "" {
+		if exists, err := s.subscriptionExists(pending.Parent); err != nil {
+			return err
+		} else if !exists {
+			s.replyAndDelete(bot, ctx, "There is no subscription with the ID `"+pending.Parent+"`.")
+			return nil
+		}
+	}
+
+	file := bytes.Buffer{}
+	if err := timetable.WriteSQL(&file, pending.Courses, pending.Semester, pending.Parent); err != nil {
+		return err
+	}
+	msg, err := bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Content: ctx.Author.Mention() + ", check the subscriptions and lecture clippers of the timetable before importing them. The attached file contains their rows.",
+		Embeds:  []*discord.MessageEmbed{timetableToEmbed(pending, parsed.Skipped)},
+		Files: []*discord.File{
+			{
+				Name:        "timetable.sql",
+				ContentType: "application/sql",
+				Reader:      &file,
+			},
+		},
+		Components: []discord.MessageComponent{
+			discord.ActionsRow{
+				Components: []discord.MessageComponent{
+					discord.Button{
+						Label:    "Import",
+						Style:    discord.SuccessButton,
+						CustomID: timetableConfirmPrefix + ctx.Author.ID,
+					},
+					discord.Button{
+						Label:    "Cancel",
+						Style:    discord.DangerButton,
+						CustomID: timetableCancelPrefix + ctx.Author.ID,
+					},
+				},
+			},
+		},
+		Reference: ctx.Reference(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The timetable is kept per preview, so importing another one doesn't change what this one imports
+	return s.savePendingTimetable(msg.ID, ctx.Author.ID, pending)
+}
+
+// Returns the start of the first event of the courses
+func timetableStart(courses []timetable.Course) time.Time {
+	start := courses[0].Start
+	for _, course := range courses[1:] {
+		if course.Start.Before(start) {
+			start = course.Start
+		}
+	}
+	return start
+}
+
+// Shows each course of the timetable with the rows it creates, and the events which were left out in the footer
+func timetableToEmbed(pending pendingTimetable, skipped []string) *discord.MessageEmbed {
+	clippers := 0
+	fields := []*discord.MessageEmbedField{}
+	for _, course := range pending.Courses {
+		lines := []string{fmt.Sprintf("Creates items at `%s`", course.Schedule)}
+		for _, lecture := range course.Lectures {
+			lines = append(lines, fmt.Sprintf("Clips `%s` at `%s` for %d minutes", lecture.Room, lecture.Schedule, lecture.Duration))
+		}
+		if len(course.Lectures) != 0 {
+			clippers++
+			lines = append(lines, "Clips can be requested as "+strings.Join(course.Aliases, ", "))
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  course.ID + " " + course.Name,
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+
+	description := fmt.Sprintf("Imports %d subscriptions and %d lecture clippers for %s.", len(pending.Courses), clippers, semesterNames[pending.Semester])
+	if pending.Parent != "" {
+		description += " The subscriptions become children of `" + pending.Parent + "`."
+	}
+
+	var footer *discord.MessageEmbedFooter
+	if len(skipped) != 0 {
+		text := "Left out: "
+		for i, reason := range skipped {
+			if i != 0 {
+				text += "; "
+			}
+			more := fmt.Sprintf("and %d more", len(skipped)-i)
+			if len(text)+len(reason)+len(more)+2 > embedFooterLength {
+				text += more
+				break
+			}
+			text += reason
+		}
+		footer = &discord.MessageEmbedFooter{Text: text}
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: "Timetable Import",
+		},
+		Description: description,
+		Color:       todoEmbedColor,
+		Fields:      fields,
+		Footer:      footer,
+	}
+}
+
+// Callback for the button confirming the import of a timetable
+func (s Todo) handleTimetableConfirm(bot *discord.Session, interaction *discord.Interaction) error {
+	return s.handleTimetableButton(bot, interaction, timetableConfirmPrefix, func(owner, messageId string) (string, error) {
+		pending, found, err := s.getPendingTimetable(messageId)
+		if err != nil || !found {
+			return "This timetable can't be imported anymore.", err
+		}
+
+		imported, err := s.importTimetable(messageId, pending)
+		if err != nil {
+			return "", err
+		}
+		// The subscriptions take effect immediately, the clippers once the lecture clip server restarts
+		if err := s.reloadSubscriptions(); err != nil {
+			return "", err
+		}
+
+		log.Printf("%s User %s imported a timetable; Subscriptions: %v, Clippers: %v, Existing: %v\n", constants.Blue, owner, imported.Subscriptions, imported.Clippers, imported.Existing)
+		return formatTimetableImport(imported), nil
+	})
+}
+
+// Callback for the button cancelling the import of a timetable
+func (s Todo) handleTimetableCancel(bot *discord.Session, interaction *discord.Interaction) error {
+	return s.handleTimetableButton(bot, interaction, timetableCancelPrefix, func(owner, messageId string) (string, error) {
+		return "Cancelled the import of the timetable.", s.deletePendingTimetable(messageId)
+	})
+}
+
+// Runs the action of a button of a timetable preview if it was pressed by the owner who imports the timetable
+// and replaces the buttons with the returned content, the action gets the owner and the ID of the preview
+func (s Todo) handleTimetableButton(bot *discord.Session, interaction *discord.Interaction, prefix string, action func(owner, messageId string) (string, error)) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	owner := strings.TrimPrefix(interaction.MessageComponentData().CustomID, prefix)
+	if interactionUser(interaction).ID != owner || !constants.IsOwner(owner) {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: "Only the owner importing the timetable can confirm it.",
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		})
+	}
+
+	content, err := action(owner, interaction.Message.ID)
+	if err != nil {
+		return err
+	}
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseUpdateMessage,
+		Data: &discord.InteractionResponseData{
+			Content:    content,
+			Components: []discord.MessageComponent{},
+		},
+	})
+}
+
+func formatTimetableImport(imported timetableImport) string {
+	lines := []string{fmt.Sprintf("Imported %d subscriptions and %d lecture clippers.", len(imported.Subscriptions), len(imported.Clippers))}
+	if len(imported.Existing) != 0 {
+		lines = append(lines, "`"+strings.Join(imported.Existing, "`, `")+"` existed already and were left as they are.")
+	}
+	if len(imported.Clippers) != 0 {
+		lines = append(lines, "The lecture clippers start once the lecture clip server restarts.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns whether there is a subscription with the ID
+func (s Todo) subscriptionExists(id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM todo.subscription WHERE id=$1)`, id).Scan(&exists)
+	return exists, err
+}
+
+// Keeps the timetable until the owner confirmed the import in the preview
+func (s Todo) savePendingTimetable(messageId, userId string, pending pendingTimetable) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	encoded, err := json.Marshal(pending.Courses)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `INSERT INTO todo.pending_timetable (message, discord_user, courses, semester, parent) VALUES ($1, $2, $3, $4, $5)`,
+		messageId,
+		userId,
+		encoded,
+		pending.Semester,
+		sql.NullString{String: pending.Parent, Valid: pending.Parent != ""},
+	)
+	return err
+}
+
+// Returns the pending timetable of the preview and whether there is one
+func (s Todo) getPendingTimetable(messageId string) (pendingTimetable, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var encoded []byte
+	var parent sql.NullString
+	pending := pendingTimetable{}
+	err := s.DB.QueryRowContext(ctx, `SELECT courses, semester, parent FROM todo.pending_timetable WHERE message=$1`, messageId).
+		Scan(&encoded, &pending.Semester, &parent)
+	if err == sql.ErrNoRows {
+		return pending, false, nil
+	} else if err != nil {
+		return pending, false, err
+	}
+	pending.Parent = parent.String
+	return pending, true, json.Unmarshal(encoded, &pending.Courses)
+}
+
+func (s Todo) deletePendingTimetable(messageId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.pending_timetable WHERE message=$1`, messageId)
+	return err
+}
+
+// Deletes the pending timetables of previews which expired by now
+func (s Todo) purgePendingTimetables(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM todo.pending_timetable WHERE created_at<$1`, now.Add(-selectMessageTimeout))
+	return err
+}
+
+// Creates the subscriptions and lecture clippers of the timetable in a single transaction and removes the pending timetable of the preview
+// Subscriptions and clippers which exist already are left as they are
+func (s Todo) importTimetable(messageId string, pending pendingTimetable) (timetableImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	imported := timetableImport{Subscriptions: []string{}, Clippers: []string{}, Existing: []string{}}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return imported, err
+	}
+
+	for _, course := range pending.Courses {
+		created, err := insertCreated(tx, insertSubscriptionQuery,
+			course.ID,
+			course.Name,
+			course.Schedule,
+			pending.Semester,
+		)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return imported, err1
+			}
+			return imported, err
+		}
+		if !created {
+			imported.Existing = append(imported.Existing, course.ID)
+			continue
+		}
+		imported.Subscriptions = append(imported.Subscriptions, course.ID)
+
+		// New subscriptions have no children, so they can't create a cycle
+		if pending.Parent != "" {
+			if _, err := tx.Exec(`INSERT INTO todo.subscription_child (parent, child) VALUES ($1, $2)`, pending.Parent, course.ID); err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return imported, err1
+				}
+				return imported, err
+			}
+		}
+		if len(course.Lectures) == 0 {
+			continue
+		}
+
+		clipper := course.ClipperID()
+		created, err = insertCreated(tx, `INSERT INTO lecture_clippers.clippers (id, semester) VALUES ($1, $2) ON CONFLICT DO NOTHING`, clipper, pending.Semester)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return imported, err1
+			}
+			return imported, err
+		}
+		if !created {
+			imported.Existing = append(imported.Existing, clipper)
+			continue
+		}
+		imported.Clippers = append(imported.Clippers, clipper)
+
+		for _, lecture := range course.Lectures {
+			if _, err := tx.Exec(`INSERT INTO lecture_clippers.schedule (id, room_url, schedule, duration_minutes) VALUES ($1, $2, $3, $4)`,
+				clipper,
+				lecture.Room,
+				lecture.Schedule,
+				lecture.Duration,
+			); err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return imported, err1
+				}
+				return imported, err
+			}
+		}
+		// The lecture clip server expects aliases for every clipper
+		if _, err := tx.Exec(`INSERT INTO lecture_clippers.lecture_alias (id, aliases) VALUES ($1, $2)`, clipper, pq.Array(course.Aliases)); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return imported, err1
+			}
+			return imported, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todo.pending_timetable WHERE message=$1`, messageId); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return imported, err1
+		}
+		return imported, err
+	}
+
+	return imported, tx.Commit()
+}
+
+// Executes the insert and returns whether it created a row
+func insertCreated(tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	created, err := result.RowsAffected()
+	return created != 0, err
+}
@@ -0,0 +1,63 @@
+package timetable
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	sqlEscaper   = strings.NewReplacer("'", "''")
+	arrayEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// Writes the rows of the courses as SQL in the style of the files in db/init, so they can be reviewed or seeded
+// The subscriptions and clippers are restricted to the semester and the subscriptions become children of the parent, if it isn't empty
+func WriteSQL(w io.Writer, courses []Course, semester, parent string) error {
+	statements := []string{}
+	for _, course := range courses {
+		statements = append(statements, fmt.Sprintf(
+			"-- %s\nINSERT INTO todo.subscription (id, subscription_name, schedule, semester) VALUES (\n    %s,\n    %s,\n    %s,\n    %s\n);",
+			course.Name,
+			quote(course.ID),
+			quote(course.Name),
+			quote(course.Schedule),
+			quote(semester),
+		))
+		if parent != "" {
+			statements = append(statements, fmt.Sprintf(
+				"INSERT INTO todo.subscription_child (parent, child) VALUES (\n    %s,\n    %s\n);",
+				quote(parent),
+				quote(course.ID),
+			))
+		}
+		if len(course.Lectures) == 0 {
+			continue
+		}
+
+		clipper := quote(course.ClipperID())
+		statements = append(statements, fmt.Sprintf("INSERT INTO lecture_clippers.clippers VALUES\n    (%s, %s);", clipper, quote(semester)))
+		schedules := []string{}
+		for _, lecture := range course.Lectures {
+			schedules = append(schedules, fmt.Sprintf("    (%s, %s, %s, %d)", clipper, quote(lecture.Room), quote(lecture.Schedule), lecture.Duration))
+		}
+		statements = append(statements, "INSERT INTO lecture_clippers.schedule VALUES\n"+strings.Join(schedules, ",\n")+";")
+		statements = append(statements, fmt.Sprintf("INSERT INTO lecture_clippers.lecture_alias VALUES\n    (%s, %s);", clipper, quote(arrayLiteral(course.Aliases))))
+	}
+
+	_, err := io.WriteString(w, strings.Join(statements, "\n\n")+"\n")
+	return err
+}
+
+// Returns the Postgres array literal of the strings, such as {"computer systems", "cs"}
+func arrayLiteral(values []string) string {
+	quoted := []string{}
+	for _, value := range values {
+		quoted = append(quoted, `"`+arrayEscaper.Replace(value)+`"`)
+	}
+	return "{" + strings.Join(quoted, ", ") + "}"
+}
+
+func quote(value string) string {
+	return "'" + sqlEscaper.Replace(value) + "'"
+}
@@ -0,0 +1,409 @@
+package timetable
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/commands/todo/exporter"
+)
+
+// Limits of the columns of the subscription and lecture clipper tables
+const (
+	maxIDLength    = 20
+	maxNameLength  = 128
+	maxRoomLength  = 15
+	maxAliasLength = 64
+)
+
+var (
+	// Number of a course in the course catalogue, such as 252-0217-00L
+	courseIDRegex = regexp.MustCompile(`\b\d{3}-\d{4}-\d{2}[A-Z]?\b`)
+	durationRegex = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+	roomRegex     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Types of events as given after the name in the course catalogue, only lectures get clipped
+var eventTypes = map[string]bool{
+	"V": true,  // Lecture
+	"G": true,  // Lecture with exercise
+	"U": false, // Exercise
+	"P": false, // Practical
+	"S": false, // Seminar
+	"K": false, // Colloquium
+	"R": false, // Revision course
+	"A": false, // Other
+}
+
+// Words left out of the acronyms of course names
+var acronymStopWords = map[string]bool{
+	"and": true, "of": true, "the": true, "for": true, "in": true, "to": true, "with": true,
+	"und": true, "der": true, "die": true, "das": true, "für": true,
+}
+
+var cronDays = [...]string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+var icsDays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Weekly lecture which can be clipped, a row of lecture_clippers.schedule
+type Lecture struct {
+	Room     string // Room as in the URL of its livestream, such as cab-g-61
+	Schedule string // Start of the lecture in the cronjob format
+	Duration int    // Duration of the lecture in minutes
+}
+
+// Course of the timetable, a subscription which may have a lecture clipper
+type Course struct {
+	ID       string // Number of the course in the course catalogue, the ID of the subscription
+	Name     string
+	Schedule string    // When the subscription creates items, at the end of the first event of the course in the week
+	Lectures []Lecture // Clipped lectures, the course gets no clipper if there are none
+	Aliases  []string  // Names the clips of the lectures can be requested by
+	Start    time.Time // Start of the first event of the course, which tells its semester
+}
+
+// Returns the ID of the lecture clipper of the course, which is its number without the letter of its type
+func (c Course) ClipperID() string {
+	id := c.ID
+	if last := id[len(id)-1]; last >= 'A' && last <= 'Z' {
+		id = id[:len(id)-1]
+	}
+	return id
+}
+
+// Courses of a timetable together with the reasons why events of it were left out
+type Timetable struct {
+	Courses []Course
+	Skipped []string
+}
+
+// Event as given by the iCalendar file
+type event struct {
+	summary, description, location string
+	start, end                     time.Time
+	rrule                          string
+	allDay, recurrenceID           bool
+}
+
+// Weekly occurrence of an event
+type slot struct {
+	course    string
+	name      string
+	lecture   bool
+	room      string
+	weekday   time.Weekday
+	hour, min int
+	duration  int // Minutes
+	start     time.Time
+}
+
+// Parses the weekly events of an iCalendar file into courses, with the times in the location
+// Events need the number of their course in their summary or description, e.g. 252-0217-00L Computer Systems V
+// Events recur weekly either with an RRULE or by happening at the same time on the same weekday repeatedly
+func Parse(data []byte, location *time.Location) (Timetable, error) {
+	events, err := parseEvents(data, location)
+	if err != nil {
+		return Timetable{}, err
+	}
+
+	timetable := Timetable{Courses: []Course{}, Skipped: []string{}}
+	slots := []slot{}
+	// Occurrences of events without an RRULE, by their slot
+	single := map[slot][]time.Time{}
+	singleOrder := []slot{}
+	for _, e := range events {
+		switch {
+		case e.recurrenceID:
+			// Changes a single occurrence of a recurring event, which doesn't change its weekly slot
+			continue
+		case e.allDay:
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s lasts all day", e.summary))
+			continue
+		}
+
+		id := courseIDRegex.FindString(e.summary)
+		if id == "" {
+			id = courseIDRegex.FindString(e.description)
+		}
+		if id == "" {
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s has no course number", e.summary))
+			continue
+		}
+		name, lecture := courseName(e.summary, id)
+
+		base := slot{
+			course:   id,
+			name:     name,
+			lecture:  lecture,
+			room:     e.location,
+			hour:     e.start.Hour(),
+			min:      e.start.Minute(),
+			duration: int(e.end.Sub(e.start).Minutes()),
+			start:    e.start,
+		}
+		if e.rrule == "" {
+			base.weekday = e.start.Weekday()
+			key := base
+			key.start = time.Time{}
+			if _, found := single[key]; !found {
+				singleOrder = append(singleOrder, key)
+			}
+			single[key] = append(single[key], e.start)
+			continue
+		}
+
+		days, err := weeklyDays(e.rrule, e.start.Weekday())
+		if err != nil {
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s %s", e.summary, err.Error()))
+			continue
+		}
+		for _, day := range days {
+			daySlot := base
+			daySlot.weekday = day
+			slots = append(slots, daySlot)
+		}
+	}
+
+	for _, key := range singleOrder {
+		occurrences := single[key]
+		if len(occurrences) < 2 {
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s on %s only happens once", key.course, occurrences[0].Format("2006-01-02")))
+			continue
+		}
+		first := key
+		first.start = occurrences[0]
+		for _, occurrence := range occurrences[1:] {
+			if occurrence.Before(first.start) {
+				first.start = occurrence
+			}
+		}
+		slots = append(slots, first)
+	}
+
+	courses := map[string]*Course{}
+	for _, s := range sortSlots(slots) {
+		course, found := courses[s.course]
+		if !found {
+			if len(s.course) > maxIDLength || s.name == "" || len(s.name) > maxNameLength {
+				timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s has no name of at most %d characters", s.course, maxNameLength))
+				courses[s.course] = nil
+				continue
+			}
+			course = &Course{
+				ID:       s.course,
+				Name:     s.name,
+				Lectures: []Lecture{},
+				Aliases:  aliases(s.name),
+				Start:    s.start,
+				// Slots are sorted by their weekday, so the first one ends first
+				Schedule: cronSchedule(s.weekday, s.hour*60+s.min+s.duration),
+			}
+			courses[s.course] = course
+		}
+		if course == nil {
+			continue
+		}
+		if s.start.Before(course.Start) {
+			course.Start = s.start
+		}
+		if !s.lecture {
+			continue
+		}
+
+		room := roomURL(s.room)
+		schedule := cronSchedule(s.weekday, s.hour*60+s.min)
+		scheduled, found := scheduledLecture(course.Lectures, schedule)
+		switch {
+		case found && scheduled.Room == room:
+			// The same lecture is in the timetable several times
+		case room == "":
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s on %s has no room", s.course, schedule))
+		case len(room) > maxRoomLength:
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s on %s is in the room %s, whose name is too long", s.course, schedule, room))
+		case found:
+			timetable.Skipped = append(timetable.Skipped, fmt.Sprintf("%s on %s is in several rooms, only %s is clipped", s.course, schedule, scheduled.Room))
+		default:
+			course.Lectures = append(course.Lectures, Lecture{Room: room, Schedule: schedule, Duration: s.duration})
+		}
+	}
+
+	for _, course := range courses {
+		if course != nil {
+			timetable.Courses = append(timetable.Courses, *course)
+		}
+	}
+	sort.Slice(timetable.Courses, func(i, j int) bool { return timetable.Courses[i].ID < timetable.Courses[j].ID })
+	return timetable, nil
+}
+
+// Parses the VEVENT entries of the iCalendar file
+func parseEvents(data []byte, location *time.Location) ([]event, error) {
+	events := []event{}
+	var e *event
+	var duration string
+	for _, property := range exporter.ReadICS(data) {
+		var err error
+		switch name, value := property.Name, property.Value; {
+		case name == "BEGIN" && value == "VEVENT":
+			e = &event{}
+			duration = ""
+		case e == nil:
+		case name == "END" && value == "VEVENT":
+			if e.start.IsZero() {
+				return nil, fmt.Errorf("%s has no start", e.summary)
+			}
+			if e.end.IsZero() {
+				if e.end, err = addDuration(e.start, duration); err != nil {
+					return nil, fmt.Errorf("%s has %s", e.summary, err.Error())
+				}
+			}
+			events = append(events, *e)
+			e = nil
+		case name == "SUMMARY":
+			e.summary = strings.TrimSpace(property.Text())
+		case name == "DESCRIPTION":
+			e.description = property.Text()
+		case name == "LOCATION":
+			e.location = property.Text()
+		case name == "RRULE":
+			e.rrule = strings.ToUpper(value)
+		case name == "RECURRENCE-ID":
+			e.recurrenceID = true
+		case name == "DURATION":
+			duration = strings.ToUpper(value)
+		case name == "DTSTART":
+			e.start, e.allDay, err = property.Time(location)
+		case name == "DTEND":
+			e.end, _, err = property.Time(location)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Adds a duration of iCalendar such as PT1H45M to the time
+func addDuration(start time.Time, duration string) (time.Time, error) {
+	match := durationRegex.FindStringSubmatch(duration)
+	if duration == "" || match == nil {
+		return time.Time{}, fmt.Errorf("neither an end nor a duration")
+	}
+	parts := [5]int{}
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(match[i+1])
+	}
+	return start.AddDate(0, 0, parts[0]*7+parts[1]).
+		Add(time.Duration(parts[2])*time.Hour + time.Duration(parts[3])*time.Minute + time.Duration(parts[4])*time.Second), nil
+}
+
+// Returns the weekdays a weekly RRULE such as FREQ=WEEKLY;BYDAY=MO,TH recurs on, the weekday of the start if it has no BYDAY
+func weeklyDays(rrule string, start time.Weekday) ([]time.Weekday, error) {
+	days := []time.Weekday{start}
+	weekly := false
+	for _, part := range strings.Split(rrule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "FREQ":
+			weekly = value == "WEEKLY"
+		case "INTERVAL":
+			if value != "1" {
+				return nil, fmt.Errorf("only recurs every %s weeks", value)
+			}
+		case "BYDAY":
+			days = []time.Weekday{}
+			for _, day := range strings.Split(value, ",") {
+				weekday, found := icsDays[day]
+				if !found {
+					return nil, fmt.Errorf("recurs on the unsupported day %s", day)
+				}
+				days = append(days, weekday)
+			}
+		}
+	}
+	if !weekly {
+		return nil, fmt.Errorf("doesn't recur weekly")
+	}
+	return days, nil
+}
+
+// Returns the name of the course in the summary of the event and whether the event is a lecture
+// The number of the course and the type of the event, e.g. V in 252-0217-00L Computer Systems V, are removed
+func courseName(summary, id string) (string, bool) {
+	words := strings.Fields(strings.Replace(summary, id, "", 1))
+	lecture := true
+	if len(words) > 1 {
+		if isLecture, found := eventTypes[words[len(words)-1]]; found {
+			words = words[:len(words)-1]
+			lecture = isLecture
+		}
+	}
+	return strings.Join(words, " "), lecture
+}
+
+// Returns the aliases of a course, its lower case name and the acronym of its name
+func aliases(name string) []string {
+	aliases := []string{}
+	lower := strings.ToLower(name)
+	if len(lower) <= maxAliasLength {
+		aliases = append(aliases, lower)
+	}
+
+	acronym := ""
+	for _, word := range strings.Fields(lower) {
+		if !acronymStopWords[word] {
+			acronym += string([]rune(word)[0])
+		}
+	}
+	if len([]rune(acronym)) > 1 && acronym != lower {
+		aliases = append(aliases, acronym)
+	}
+	return aliases
+}
+
+// Returns the room as in the URL of its livestream, e.g. cab-g-61 for CAB G 61
+// Only the first room is used if there are several
+func roomURL(location string) string {
+	room, _, _ := strings.Cut(location, ",")
+	return strings.Trim(roomRegex.ReplaceAllString(strings.ToLower(room), "-"), "-")
+}
+
+// Returns the cronjob schedule of the weekday at the minutes after its midnight, which may reach into the next day
+func cronSchedule(weekday time.Weekday, minutes int) string {
+	weekday = (weekday + time.Weekday(minutes/(24*60))) % 7
+	minutes %= 24 * 60
+	return fmt.Sprintf("%d %d * * %s", minutes%60, minutes/60, cronDays[weekday])
+}
+
+// Sorts the slots by their time in the week, starting on Monday
+func sortSlots(slots []slot) []slot {
+	sort.SliceStable(slots, func(i, j int) bool {
+		dayI, dayJ := (slots[i].weekday+6)%7, (slots[j].weekday+6)%7
+		if dayI != dayJ {
+			return dayI < dayJ
+		}
+		return slots[i].hour*60+slots[i].min < slots[j].hour*60+slots[j].min
+	})
+	return slots
+}
+
+// Returns the lecture starting at the schedule
+func scheduledLecture(lectures []Lecture, schedule string) (Lecture, bool) {
+	for _, lecture := range lectures {
+		if lecture.Schedule == schedule {
+			return lecture, true
+		}
+	}
+	return Lecture{}, false
+}
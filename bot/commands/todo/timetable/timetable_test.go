@@ -0,0 +1,114 @@
+package timetable
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Run the tests with -update to rewrite the golden files with the current output
+var update = flag.Bool("update", false, "update the golden files")
+
+func loadTimetable(t *testing.T) Timetable {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	assert.Nil(t, err)
+	data, err := os.ReadFile(filepath.Join("testdata", "fifth_semester.ics"))
+	assert.Nil(t, err)
+
+	timetable, err := Parse(data, zurich)
+	assert.Nil(t, err)
+	return timetable
+}
+
+func TestParse(t *testing.T) {
+	timetable := loadTimetable(t)
+
+	assert.Len(t, timetable.Courses, 3)
+
+	apc := timetable.Courses[0]
+	assert.Equal(t, "252-0209-00L", apc.ID)
+	assert.Equal(t, "Algorithms, Probability, and Computing", apc.Name)
+	assert.Equal(t, "0 12 * * TUE", apc.Schedule)
+	assert.Equal(t, []Lecture{{"cab-g-11", "15 10 * * TUE", 105}}, apc.Lectures)
+	assert.Equal(t, []string{"algorithms, probability, and computing", "apc"}, apc.Aliases)
+
+	cs := timetable.Courses[1]
+	assert.Equal(t, "252-0217-00L", cs.ID)
+	assert.Equal(t, "252-0217-00", cs.ClipperID())
+	assert.Equal(t, "Computer Systems", cs.Name)
+	assert.Equal(t, "0 12 * * MON", cs.Schedule)
+	// The exercise isn't clipped and the moved lecture doesn't change the schedule
+	assert.Equal(t, []Lecture{{"cab-g-61", "0 10 * * MON", 120}, {"cab-g-61", "0 10 * * FRI", 120}}, cs.Lectures)
+	assert.Equal(t, []string{"computer systems", "cs"}, cs.Aliases)
+	assert.Equal(t, time.Date(2022, 9, 19, 10, 0, 0, 0, cs.Start.Location()), cs.Start)
+
+	dphpc := timetable.Courses[2]
+	assert.Equal(t, "Design of Parallel and High-Performance Computing", dphpc.Name)
+	// UTC times are converted into the location and the duration gives the end
+	assert.Equal(t, "45 15 * * TUE", dphpc.Schedule)
+	assert.Equal(t, []Lecture{{"hg-f-1", "0 14 * * TUE", 105}}, dphpc.Lectures)
+
+	assert.ElementsMatch(t, []string{
+		"252-0217-00L Computer Systems Exam lasts all day",
+		"Lunch has no course number",
+		"252-0535-00L Advanced Machine Learning V only recurs every 2 weeks",
+		"252-0209-00L on 2022-12-16 only happens once",
+	}, timetable.Skipped)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("BEGIN:VEVENT\nSUMMARY:252-0217-00L Computer Systems\nDTSTART:20220919T100000\nEND:VEVENT\n"), time.UTC)
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte("BEGIN:VEVENT\nSUMMARY:252-0217-00L Computer Systems\nDTSTART:someday\nEND:VEVENT\n"), time.UTC)
+	assert.NotNil(t, err)
+}
+
+func TestCronSchedule(t *testing.T) {
+	tests := []struct {
+		weekday  time.Weekday
+		minutes  int
+		expected string
+	}{
+		{time.Monday, 10 * 60, "0 10 * * MON"},
+		{time.Friday, 18*60 + 30, "30 18 * * FRI"},
+		{time.Sunday, 0, "0 0 * * SUN"},
+		// Events ending at or after midnight end on the next day
+		{time.Saturday, 24*60 + 15, "15 0 * * SUN"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, cronSchedule(test.weekday, test.minutes), test)
+	}
+}
+
+func TestRoomURL(t *testing.T) {
+	assert.Equal(t, "cab-g-61", roomURL("CAB G 61"))
+	assert.Equal(t, "hg-e-7", roomURL(" HG E 7, HG E 5"))
+	assert.Equal(t, "ml-d-28", roomURL("ML D 28 »"))
+	assert.Equal(t, "", roomURL("   "))
+}
+
+func TestAliases(t *testing.T) {
+	assert.Equal(t, []string{"analysis 1", "a1"}, aliases("Analysis 1"))
+	assert.Equal(t, []string{"compilers"}, aliases("Compilers"))
+	assert.Equal(t, []string{"data modelling and databases", "dmd"}, aliases("Data Modelling and Databases"))
+}
+
+func TestWriteSQL(t *testing.T) {
+	buffer := bytes.Buffer{}
+	assert.Nil(t, WriteSQL(&buffer, loadTimetable(t).Courses, "H", "Sem-05"))
+
+	golden := filepath.Join("testdata", "fifth_semester.sql.golden")
+	if *update {
+		assert.Nil(t, os.WriteFile(golden, buffer.Bytes(), 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), buffer.String())
+}
@@ -0,0 +1,88 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/bot/commands/todo/timetable"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTimetable = pendingTimetable{
+	Courses: []timetable.Course{
+		{
+			ID:       "252-0217-00L",
+			Name:     "Computer Systems",
+			Schedule: "0 12 * * MON",
+			Lectures: []timetable.Lecture{{Room: "cab-g-61", Schedule: "0 10 * * MON", Duration: 120}},
+			Aliases:  []string{"computer systems", "cs"},
+		},
+		{
+			ID:       "252-0209-00L",
+			Name:     "Algorithms, Probability, and Computing",
+			Schedule: "0 12 * * TUE",
+			Lectures: []timetable.Lecture{},
+			Aliases:  []string{"algorithms, probability, and computing", "apc"},
+		},
+	},
+	Semester: "H",
+	Parent:   "Sem-05",
+}
+
+func TestTimetableToEmbed(t *testing.T) {
+	embed := timetableToEmbed(testTimetable, []string{"Lunch has no course number"})
+
+	assert.Equal(t, "Imports 2 subscriptions and 1 lecture clippers for the fall semester. The subscriptions become children of `Sem-05`.", embed.Description)
+	assert.Len(t, embed.Fields, 2)
+	assert.Equal(t, "252-0217-00L Computer Systems", embed.Fields[0].Name)
+	assert.Equal(t, "Creates items at `0 12 * * MON`\nClips `cab-g-61` at `0 10 * * MON` for 120 minutes\nClips can be requested as computer systems, cs", embed.Fields[0].Value)
+	assert.Equal(t, "Creates items at `0 12 * * TUE`", embed.Fields[1].Value)
+	assert.Equal(t, "Left out: Lunch has no course number", embed.Footer.Text)
+
+	skipped := []string{}
+	for i := 0; i < 200; i++ {
+		skipped = append(skipped, "Lunch has no course number")
+	}
+	footer := timetableToEmbed(testTimetable, skipped).Footer.Text
+	assert.LessOrEqual(t, len(footer), embedFooterLength)
+	assert.True(t, strings.HasSuffix(footer, "more"))
+}
+
+func TestImportTimetable(t *testing.T) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`INSERT INTO todo.subscription`).
+		WithArgs("252-0217-00L", "Computer Systems", "0 12 * * MON", "H").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO todo.subscription_child`).
+		WithArgs("Sem-05", "252-0217-00L").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO lecture_clippers.clippers`).
+		WithArgs("252-0217-00", "H").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO lecture_clippers.schedule`).
+		WithArgs("252-0217-00", "cab-g-61", "0 10 * * MON", 120).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO lecture_clippers.lecture_alias`).
+		WithArgs("252-0217-00", pq.Array([]string{"computer systems", "cs"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Existing subscriptions are left as they are
+	dbMock.ExpectExec(`INSERT INTO todo.subscription`).
+		WithArgs("252-0209-00L", "Algorithms, Probability, and Computing", "0 12 * * TUE", "H").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec(`DELETE FROM todo.pending_timetable`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	imported, err := mockTodo.importTimetable("1", testTimetable)
+
+	assert.Nil(t, err)
+	assert.Equal(t, timetableImport{
+		Subscriptions: []string{"252-0217-00L"},
+		Clippers:      []string{"252-0217-00"},
+		Existing:      []string{"252-0209-00L"},
+	}, imported)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
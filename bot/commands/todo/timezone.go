@@ -0,0 +1,185 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+func (s Todo) timezoneHelp() string {
+	return "Usage: `todo timezone [name|reset]`\nSets your timezone, such as `Europe/Zurich` or `America/New_York`, used for the due dates you enter, your digest, recurring items and stats.\nUse `todo timezone reset` to use the timezone of the bot again and `todo timezone` to show your current one."
+}
+
+func (s Todo) Timezone(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+	if len(args) > 1 || (len(args) == 1 && args[0] == "help") {
+		bot.ChannelMessageSend(ctx.ChannelID, s.timezoneHelp())
+		return nil
+	}
+
+	if len(args) == 0 { // Show the current timezone
+		location, err := s.getLocation(ctx.Author.ID)
+		if err != nil {
+			return err
+		}
+		bot.ChannelMessageSendReply(ctx.ChannelID, "Your timezone is `"+location.String()+"`.", ctx.Reference())
+		return nil
+	}
+
+	timezone := args[0]
+	if strings.ToLower(timezone) == "reset" {
+		timezone = ""
+	} else if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+		bot.ChannelMessageSend(ctx.ChannelID, "Unknown timezone `"+timezone+"`.\n"+s.timezoneHelp())
+		return nil
+	}
+
+	if err := s.setTimezone(ctx.Author.ID, timezone, time.Now()); err != nil {
+		return err
+	}
+
+	location, err := s.getLocation(ctx.Author.ID)
+	if err != nil {
+		return err
+	}
+	msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, "Your timezone is now `"+location.String()+"`.", ctx.Reference())
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+	return nil
+}
+
+// Returns the location of the timezone, the one of the bot if it is empty or unknown
+func loadLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Println(constants.Red, "Unknown timezone", timezone, err)
+		return time.Local
+	}
+	return location
+}
+
+// Returns the location of the timezone of the user
+func (s Todo) getLocation(userId string) (*time.Location, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var timezone sql.NullString
+	if err := s.DB.QueryRowContext(ctx,
+		`SELECT timezone FROM todo.discord_user WHERE id=$1`,
+		userId,
+	).Scan(&timezone); err != nil {
+		return nil, err
+	}
+	return loadLocation(timezone.String), nil
+}
+
+// Returns the current time in the timezone of the user, the due dates they enter are relative to it
+func (s Todo) userNow(userId string) (time.Time, error) {
+	location, err := s.getLocation(userId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(location), nil
+}
+
+// Sets the timezone of the user, empty for the one of the bot, and moves their digest and recurring items to the new timezone
+func (s Todo) setTimezone(userId, timezone string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE todo.discord_user SET timezone=NULLIF($2, '') WHERE id=$1`,
+		userId,
+		timezone,
+	); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+
+	var schedule string
+	err = tx.QueryRow(`SELECT schedule FROM todo.digest WHERE discord_user=$1`, userId).Scan(&schedule)
+	if err != nil && err != sql.ErrNoRows {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	if err == nil {
+		parsed, err := scheduleParser.Parse(schedule)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return err1
+			}
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE todo.digest SET next_run=$2 WHERE discord_user=$1`,
+			userId,
+			parsed.Next(now.In(loadLocation(timezone))),
+		); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return err1
+			}
+			return err
+		}
+	}
+
+	// Paused recurring items get their next run once they are resumed
+	rows, err := tx.Query(`SELECT id, schedule FROM todo.recurring WHERE discord_user=$1 AND NOT paused`, userId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	recurring := map[int]string{}
+	for rows.Next() {
+		var id int
+		var schedule string
+		rows.Scan(&id, &schedule)
+		recurring[id] = schedule
+	}
+	rows.Close()
+	for id, schedule := range recurring {
+		parsed, err := scheduleParser.Parse(schedule)
+		if err != nil {
+			log.Println(constants.Red, "Invalid schedule of recurring item", id, err)
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE todo.recurring SET next_run=$2 WHERE id=$1`,
+			id,
+			parsed.Next(now.In(loadLocation(timezone))),
+		); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return err1
+			}
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("%s Set users %s timezone to %q\n", constants.Blue, userId, timezone)
+	return nil
+}
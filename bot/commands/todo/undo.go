@@ -0,0 +1,716 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	undoWindow       = 24 * time.Hour   // How long operations can be undone
+	undoMessageDelay = 30 * time.Second // How long confirmations with an undo button stay before they get deleted
+	maxUndoCount     = 10               // Maximum amount of operations undone at once
+	undoButtonPrefix = "todo.undo-button:"
+)
+
+// Operations the bot did on behalf of the user, they can't be undone
+const (
+	recurringAction    = "repeat"       // Items added by recurring items
+	subscriptionAction = "subscription" // Items added by subscriptions
+	retentionAction    = "retention"    // Items archived by the retention policy
+)
+
+// Operations besides adding and moving items, which the journal keeps additional values of to undo them
+const (
+	checkAction = "check" // Subtasks checked off, with the items completed by it
+	groupAction = "group" // Changes of group items
+)
+
+// Selects the operations of the user $1 created after $3 that haven't been undone yet
+const undoableOperations = `SELECT id FROM todo.operation
+	WHERE discord_user=$1 AND undone_at IS NULL AND action NOT IN ('` + recurringAction + `', '` + subscriptionAction + `', '` + retentionAction + `') AND created_at>$3`
+
+// Change of the list a task is in, "" if it isn't in any list
+// From and To are equal for reverted edits, which don't move the task
+type listChange struct {
+	Task int
+	From string
+	To   string
+}
+
+// Change of the status of a group item, To is "" if it was deleted
+// Assignee, CompletedBy and CompletedAt are the values the item had before the change
+type groupChange struct {
+	Group       int
+	Task        int
+	From        string
+	To          string
+	Assignee    sql.NullString
+	CompletedBy sql.NullString
+	CompletedAt sql.NullTime
+}
+
+// Changes reverted by undoing operations
+type undoneChanges struct {
+	Lists    []listChange  // Moves and edits of the users items
+	Subtasks []string      // IDs of the subtasks which aren't checked off anymore, such as 1.2
+	Groups   []groupChange // Changes of group items
+}
+
+// Returns whether nothing was reverted
+func (u undoneChanges) empty() bool {
+	return len(u.Lists) == 0 && len(u.Subtasks) == 0 && len(u.Groups) == 0
+}
+
+// Edit of a task, Previous holds the ID and the values of the task in the users list before the edit
+type taskEdit struct {
+	Operation int64
+	Task      int
+	Previous  todoItem
+}
+
+func (s Todo) undoHelp() string {
+	return fmt.Sprintf("Usage: `todo undo [count]`\nReverts your last `count` (default 1, at most %d) changes to your items, e.g. adding, editing, completing, archiving or deleting them, checking off subtasks or changing the items of your groups.\nOnly changes from the last %d hours can be undone.", maxUndoCount, int(undoWindow.Hours()))
+}
+
+func (s Todo) Undo(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+
+	count := 1
+	if len(args) == 1 && args[0] == "help" {
+		bot.ChannelMessageSend(ctx.ChannelID, s.undoHelp())
+		return nil
+	} else if len(args) == 1 {
+		var err error
+		if count, err = strconv.Atoi(args[0]); err != nil || count < 1 || count > maxUndoCount {
+			bot.ChannelMessageSend(ctx.ChannelID, "Couldn't parse the count.\n"+s.undoHelp())
+			return nil
+		}
+	} else if len(args) > 1 {
+		bot.ChannelMessageSend(ctx.ChannelID, s.undoHelp())
+		return nil
+	}
+
+	undone, err := s.undoLast(ctx.Author.ID, count, time.Now())
+	if err != nil {
+		return err
+	}
+
+	msg, _ := bot.ChannelMessageSendReply(ctx.ChannelID, formatUndone(undone), ctx.Reference())
+	time.Sleep(messageDeleteDelay)
+	bot.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+	if msg != nil {
+		bot.ChannelMessageDelete(ctx.ChannelID, msg.ID)
+	}
+	return nil
+}
+
+// Describes the reverted changes
+func formatUndone(undone undoneChanges) string {
+	if undone.empty() {
+		return "There is nothing to undo."
+	}
+
+	// Group the tasks by how they were moved, keeping the order of the changes
+	type move struct {
+		from, to string
+		group    bool
+	}
+	moves := []move{}
+	tasks := map[move][]string{}
+	addMove := func(m move, task int) {
+		if _, found := tasks[m]; !found {
+			moves = append(moves, m)
+		}
+		tasks[m] = append(tasks[m], fmt.Sprint(task))
+	}
+	for _, change := range undone.Lists {
+		addMove(move{change.From, change.To, false}, change.Task)
+	}
+	for _, change := range undone.Groups {
+		addMove(move{change.From, change.To, true}, change.Task)
+	}
+
+	lines := []string{}
+	for _, m := range moves {
+		ids := strings.Join(tasks[m], ", ")
+		switch {
+		case m.group && m.to == "":
+			lines = append(lines, fmt.Sprintf("Restored %s to the %s items of your group.", ids, strings.ToLower(listLabels[m.from])))
+		case m.group:
+			lines = append(lines, fmt.Sprintf("Moved %s of your group back from %s to %s.", ids, strings.ToLower(listLabels[m.to]), strings.ToLower(listLabels[m.from])))
+		case m.from == m.to:
+			lines = append(lines, fmt.Sprintf("Reverted your edits of %s.", ids))
+		case m.from == "":
+			lines = append(lines, fmt.Sprintf("Removed %s from your %s items.", ids, strings.ToLower(listLabels[m.to])))
+		case m.to == "":
+			lines = append(lines, fmt.Sprintf("Restored %s to your %s items.", ids, strings.ToLower(listLabels[m.from])))
+		default:
+			lines = append(lines, fmt.Sprintf("Moved %s back from %s to %s.", ids, strings.ToLower(listLabels[m.to]), strings.ToLower(listLabels[m.from])))
+		}
+	}
+	if len(undone.Subtasks) != 0 {
+		lines = append(lines, fmt.Sprintf("Unchecked %s.", strings.Join(undone.Subtasks, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the components of a confirmation with a button undoing the operations
+func undoComponents(owner string, operations ...int) []discord.MessageComponent {
+	ids := []string{}
+	for _, operation := range operations {
+		if operation != 0 {
+			ids = append(ids, fmt.Sprint(operation))
+		}
+	}
+	if len(ids) == 0 {
+		return []discord.MessageComponent{}
+	}
+
+	return []discord.MessageComponent{
+		discord.ActionsRow{
+			Components: []discord.MessageComponent{
+				discord.Button{
+					Label:    "Undo",
+					Style:    discord.SecondaryButton,
+					CustomID: undoButtonPrefix + owner + ":" + strings.Join(ids, ","),
+				},
+			},
+		},
+	}
+}
+
+// Sends the confirmation of operations with a button to undo them
+func sendUndoable(bot *discord.Session, channelId, content, owner string, operations ...int) (*discord.Message, error) {
+	return bot.ChannelMessageSendComplex(channelId, &discord.MessageSend{
+		Content:    content,
+		Components: undoComponents(owner, operations...),
+		AllowedMentions: &discord.MessageAllowedMentions{
+			Users: []string{},
+		},
+	})
+}
+
+// Splits the state encoded in the CustomID of an undo button into its owner and operations
+func parseUndoState(customId string) (string, []int, error) {
+	split := strings.Split(strings.TrimPrefix(customId, undoButtonPrefix), ":")
+	if len(split) != 2 {
+		return "", nil, fmt.Errorf("invalid undo button state %s", customId)
+	}
+
+	operations := []int{}
+	for _, rawOperation := range strings.Split(split[1], ",") {
+		operation, err := strconv.Atoi(rawOperation)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid undo button state %s", customId)
+		}
+		operations = append(operations, operation)
+	}
+	return split[0], operations, nil
+}
+
+// Callback for the undo button of confirmations, reverts the operations encoded in its CustomID
+func (s Todo) handleUndoButton(bot *discord.Session, interaction *discord.Interaction) error {
+	if isExpired(interaction) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	owner, operations, err := parseUndoState(interaction.MessageComponentData().CustomID)
+	if err != nil {
+		return err
+	}
+	if interactionUser(interaction).ID != owner {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: "You can only undo your own changes.",
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		})
+	}
+
+	undone, err := s.undoOperations(owner, operations, time.Now())
+	if err != nil {
+		return err
+	}
+
+	content := formatUndone(undone)
+	if undone.empty() {
+		content = "This can't be undone anymore."
+	}
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseUpdateMessage,
+		Data: &discord.InteractionResponseData{
+			Content:    content,
+			Components: []discord.MessageComponent{},
+		},
+	})
+}
+
+// Records an operation changing the lists of the user in the journal, returns its ID
+// Nothing is recorded if there are no changes, the returned ID is 0 then
+func recordOperation(tx *sql.Tx, userId, action string, changes []listChange) (int, error) {
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	operation, err := insertOperation(tx, userId, action)
+	if err != nil {
+		return 0, err
+	}
+	return operation, recordChanges(tx, operation, changes)
+}
+
+// Inserts an operation of the user into the journal, returns its ID
+func insertOperation(tx *sql.Tx, userId, action string) (int, error) {
+	var operation int
+	err := tx.QueryRow(`INSERT INTO todo.operation (discord_user, action) VALUES ($1, $2) RETURNING id`,
+		userId,
+		action,
+	).Scan(&operation)
+	return operation, err
+}
+
+// Records the changes of the lists as part of the operation
+func recordChanges(tx *sql.Tx, operation int, changes []listChange) error {
+	tasks, from, to := []int64{}, []string{}, []string{}
+	for _, change := range changes {
+		tasks = append(tasks, int64(change.Task))
+		from = append(from, change.From)
+		to = append(to, change.To)
+	}
+	if _, err := tx.Exec(`INSERT INTO todo.operation_change (operation, task, from_list, to_list)
+		SELECT $1, c.task, NULLIF(c.from_list, ''), NULLIF(c.to_list, '')
+		FROM UNNEST($2::INTEGER[], $3::TEXT[], $4::TEXT[]) AS c(task, from_list, to_list)`,
+		operation,
+		pq.Array(tasks),
+		pq.Array(from),
+		pq.Array(to),
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Records checking off the users subtasks with the IDs in the journal, together with the changes of the lists it caused
+// Returns the ID of the operation, nothing is recorded if there are neither subtasks nor changes, the returned ID is 0 then
+func recordCheck(tx *sql.Tx, userId string, subtasks []string, changes []listChange) (int, error) {
+	if len(subtasks) == 0 && len(changes) == 0 {
+		return 0, nil
+	}
+
+	operation, err := insertOperation(tx, userId, checkAction)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO todo.operation_subtask (operation, task, position)
+		SELECT $1, task, position FROM todo.subtask WHERE (task || '.' || position)=ANY($2)`,
+		operation,
+		pq.Array(subtasks),
+	); err != nil {
+		return 0, err
+	}
+	if len(changes) == 0 {
+		return operation, nil
+	}
+	return operation, recordChanges(tx, operation, changes)
+}
+
+// Records the change of the groups items in one of the statuses "from" to the status "to" in the journal, returns the ID of the operation
+// It has to be recorded before the items are changed, so the journal keeps their previous values, to is "" if they get deleted
+func recordGroupChange(tx *sql.Tx, userId string, groupId int, itemIds, from []string, to string) (int, error) {
+	operation, err := insertOperation(tx, userId, groupAction)
+	if err != nil {
+		return 0, err
+	}
+
+	var toArg interface{}
+	if to != "" {
+		toArg = to
+	}
+	if _, err := tx.Exec(`INSERT INTO todo.operation_group_change (operation, study_group, task, from_status, to_status, assignee, completed_by, completed_at)
+		SELECT $1, study_group, task, status, $5, assignee, completed_by, completed_at FROM todo.group_task
+		WHERE study_group=$2 AND task=ANY($3) AND status=ANY($4)`,
+		operation,
+		groupId,
+		pq.Array(itemIds),
+		pq.Array(from),
+		toArg,
+	); err != nil {
+		return 0, err
+	}
+	return operation, nil
+}
+
+// Records the edit of a users task in the journal, returns the ID of the operation
+func recordEdit(tx *sql.Tx, userId string, taskId int, previous todoItem) (int, error) {
+	var operation int
+	if err := tx.QueryRow(`INSERT INTO todo.operation (discord_user, action) VALUES ($1, 'edit') RETURNING id`,
+		userId,
+	).Scan(&operation); err != nil {
+		return 0, err
+	}
+
+	tags := previous.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	if _, err := tx.Exec(`INSERT INTO todo.operation_edit (operation, task, previous_task, title, description, due, tags, priority)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		operation,
+		taskId,
+		previous.ID,
+		previous.Title,
+		previous.Description,
+		previous.Due,
+		pq.Array(tags),
+		previous.Priority,
+	); err != nil {
+		return 0, err
+	}
+	return operation, nil
+}
+
+// Changes of the tasks moved from one list to another
+func moveChanges(tasks []string, from, to string) []listChange {
+	changes := []listChange{}
+	for _, task := range tasks {
+		id, _ := strconv.Atoi(task)
+		changes = append(changes, listChange{id, from, to})
+	}
+	return changes
+}
+
+// Reverts the last count operations of the user, returns the reverted changes
+func (s Todo) undoLast(userId string, count int, now time.Time) (undoneChanges, error) {
+	return s.revertOperations(userId, undoableOperations+` ORDER BY id DESC LIMIT $4`, count, now)
+}
+
+// Reverts the given operations of the user if they can still be undone, returns the reverted changes
+func (s Todo) undoOperations(userId string, operations []int, now time.Time) (undoneChanges, error) {
+	return s.revertOperations(userId, undoableOperations+` AND id=ANY($4)`, pq.Array(operations), now)
+}
+
+// Reverts the operations selected by the query, which gets passed the user, now, the start of the undo window and the selection as $1 to $4
+// Changes whose tasks were changed again since by operations which aren't undone are skipped
+func (s Todo) revertOperations(userId, selectQuery string, selection interface{}, now time.Time) (undoneChanges, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return undoneChanges{}, err
+	}
+
+	// Mark the operations as undone first, so concurrent undos don't revert them twice
+	rows, err := tx.Query(`UPDATE todo.operation SET undone_at=$2 WHERE id IN (`+selectQuery+`) RETURNING id`,
+		userId,
+		now,
+		now.Add(-undoWindow),
+		selection,
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return undoneChanges{}, err1
+		}
+		return undoneChanges{}, err
+	}
+	operations := []int64{}
+	for rows.Next() {
+		var operation int64
+		rows.Scan(&operation)
+		operations = append(operations, operation)
+	}
+	rows.Close()
+	// The latest operations get reverted first
+	sort.Slice(operations, func(i, j int) bool { return operations[i] > operations[j] })
+
+	rows, err = tx.Query(`SELECT operation, task, COALESCE(from_list, ''), COALESCE(to_list, '') FROM todo.operation_change
+		WHERE operation=ANY($1) ORDER BY operation DESC, task`,
+		pq.Array(operations),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return undoneChanges{}, err1
+		}
+		return undoneChanges{}, err
+	}
+	changes := map[int64][]listChange{}
+	for rows.Next() {
+		var operation int64
+		var change listChange
+		rows.Scan(&operation, &change.Task, &change.From, &change.To)
+		changes[operation] = append(changes[operation], change)
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT operation, task, previous_task, title, description, due, tags, priority FROM todo.operation_edit
+		WHERE operation=ANY($1)`,
+		pq.Array(operations),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return undoneChanges{}, err1
+		}
+		return undoneChanges{}, err
+	}
+	edits := map[int64][]taskEdit{}
+	for rows.Next() {
+		var edit taskEdit
+		rows.Scan(&edit.Operation, &edit.Task, &edit.Previous.ID, &edit.Previous.Title, &edit.Previous.Description, &edit.Previous.Due, pq.Array(&edit.Previous.Tags), &edit.Previous.Priority)
+		edits[edit.Operation] = append(edits[edit.Operation], edit)
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT operation, task || '.' || position FROM todo.operation_subtask
+		WHERE operation=ANY($1) ORDER BY task, position`,
+		pq.Array(operations),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return undoneChanges{}, err1
+		}
+		return undoneChanges{}, err
+	}
+	subtasks := map[int64][]string{}
+	for rows.Next() {
+		var operation int64
+		var subtask string
+		rows.Scan(&operation, &subtask)
+		subtasks[operation] = append(subtasks[operation], subtask)
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT operation, study_group, task, from_status, COALESCE(to_status, ''), assignee, completed_by, completed_at
+		FROM todo.operation_group_change WHERE operation=ANY($1) ORDER BY task`,
+		pq.Array(operations),
+	)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return undoneChanges{}, err1
+		}
+		return undoneChanges{}, err
+	}
+	groupChanges := map[int64][]groupChange{}
+	for rows.Next() {
+		var operation int64
+		var change groupChange
+		rows.Scan(&operation, &change.Group, &change.Task, &change.From, &change.To, &change.Assignee, &change.CompletedBy, &change.CompletedAt)
+		groupChanges[operation] = append(groupChanges[operation], change)
+	}
+	rows.Close()
+
+	undone := undoneChanges{Lists: []listChange{}, Subtasks: []string{}, Groups: []groupChange{}}
+	for _, operation := range operations {
+		for _, edit := range edits[operation] {
+			change, ok, err := revertEdit(tx, userId, edit)
+			if err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return undoneChanges{}, err1
+				}
+				return undoneChanges{}, err
+			}
+			if ok {
+				undone.Lists = append(undone.Lists, change)
+			}
+		}
+		for _, change := range changes[operation] {
+			ok, err := revertChange(tx, userId, change)
+			if err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return undoneChanges{}, err1
+				}
+				return undoneChanges{}, err
+			}
+			if ok {
+				undone.Lists = append(undone.Lists, change)
+			}
+		}
+		for _, subtask := range subtasks[operation] {
+			ok, err := revertCheck(tx, userId, subtask)
+			if err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return undoneChanges{}, err1
+				}
+				return undoneChanges{}, err
+			}
+			if ok {
+				undone.Subtasks = append(undone.Subtasks, subtask)
+			}
+		}
+		for _, change := range groupChanges[operation] {
+			ok, err := revertGroupChange(tx, change)
+			if err != nil {
+				if err1 := tx.Rollback(); err1 != nil {
+					return undoneChanges{}, err1
+				}
+				return undoneChanges{}, err
+			}
+			if ok {
+				undone.Groups = append(undone.Groups, change)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return undoneChanges{}, err
+	}
+
+	if undone.empty() {
+		return undone, nil
+	}
+
+	log.Printf("%s Undid users %s operations %v\n", constants.Blue, userId, operations)
+	notifyBoards(userId)
+	for _, change := range undone.Groups {
+		notifyGroupBoards(change.Group)
+	}
+
+	return undone, s.scheduleReminders(userId)
+}
+
+// Moves the task of the change back into the list it came from, returns whether it did
+// Tasks which aren't in the list they were moved to anymore are left where they are
+func revertChange(tx *sql.Tx, userId string, change listChange) (bool, error) {
+	for _, list := range []string{change.From, change.To} {
+		if _, found := listLabels[list]; list != "" && !found {
+			return false, fmt.Errorf("invalid list %s in the change of task %d", list, change.Task)
+		}
+	}
+
+	if change.To != "" {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM todo.%s WHERE discord_user=$1 AND task=$2`, change.To),
+			userId,
+			change.Task,
+		)
+		if err != nil {
+			return false, err
+		}
+		if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+			return false, err
+		}
+	}
+	if change.From != "" {
+		result, err := tx.Exec(fmt.Sprintf(`INSERT INTO todo.%s (discord_user, task)
+			SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM `+userListsQuery+` AS l WHERE l.discord_user=$1 AND l.task=$2)
+			ON CONFLICT DO NOTHING`, change.From),
+			userId,
+			change.Task,
+		)
+		if err != nil {
+			return false, err
+		}
+		// Restoring a task the user has in another list already only removes it from the list it was moved to
+		if inserted, err := result.RowsAffected(); err != nil || (inserted == 0 && change.To == "") {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Unchecks the users subtask checked off by an operation, returns whether it was still checked off
+func revertCheck(tx *sql.Tx, userId, subtask string) (bool, error) {
+	result, err := tx.Exec(`DELETE FROM todo.completed_subtask WHERE discord_user=$1 AND (task || '.' || position)=$2`,
+		userId,
+		subtask,
+	)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted != 0, err
+}
+
+// Restores the group item of the change to the status and values it had before, returns whether it did
+// Items which were changed again since are left as they are, deleted items are only restored if they weren't added again
+func revertGroupChange(tx *sql.Tx, change groupChange) (bool, error) {
+	var result sql.Result
+	var err error
+	if change.To == "" {
+		result, err = tx.Exec(`INSERT INTO todo.group_task (study_group, task, status, assignee, completed_by, completed_at)
+			VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING`,
+			change.Group,
+			change.Task,
+			change.From,
+			change.Assignee,
+			change.CompletedBy,
+			change.CompletedAt,
+		)
+	} else {
+		result, err = tx.Exec(`UPDATE todo.group_task SET status=$3, completed_by=$5, completed_at=$6 WHERE study_group=$1 AND task=$2 AND status=$4`,
+			change.Group,
+			change.Task,
+			change.From,
+			change.To,
+			change.CompletedBy,
+			change.CompletedAt,
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	return changed != 0, err
+}
+
+// Restores the values the task of the edit had before it, returns the change of the list it is in
+// Copies made by the edit are replaced by the original task again
+// Edits of tasks which aren't in any of the users lists anymore or which were edited again since are skipped
+func revertEdit(tx *sql.Tx, userId string, edit taskEdit) (listChange, bool, error) {
+	var list string
+	err := tx.QueryRow(`SELECT l.list FROM `+userListsQuery+` AS l
+		WHERE l.discord_user=$1 AND l.task=$2 AND NOT EXISTS (
+			SELECT 1 FROM todo.operation_edit AS e JOIN todo.operation AS o ON o.id=e.operation
+			WHERE o.discord_user=$1 AND e.task=$2 AND o.id>$3 AND o.undone_at IS NULL
+		)`,
+		userId,
+		edit.Task,
+		edit.Operation,
+	).Scan(&list)
+	if err == sql.ErrNoRows {
+		return listChange{}, false, nil
+	} else if err != nil {
+		return listChange{}, false, err
+	}
+
+	if edit.Task == edit.Previous.ID {
+		tags := edit.Previous.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		_, err := tx.Exec(`UPDATE todo.task SET title=$2, description=$3, due=$4, tags=$5, priority=$6 WHERE id=$1`,
+			edit.Task,
+			edit.Previous.Title,
+			edit.Previous.Description,
+			edit.Previous.Due,
+			pq.Array(tags),
+			edit.Previous.Priority,
+		)
+		return listChange{edit.Task, list, list}, err == nil, err
+	}
+
+	// Swap the copy for the original, the reverse of the copy on write of editItem
+	for _, query := range []string{
+		fmt.Sprintf(`UPDATE todo.%s SET task=$3 WHERE discord_user=$1 AND task=$2`, list),
+		`UPDATE todo.completed_subtask SET task=$3 WHERE discord_user=$1 AND task=$2`,
+		`UPDATE todo.operation_change AS c SET task=$3 FROM todo.operation AS o WHERE o.id=c.operation AND o.discord_user=$1 AND c.task=$2`,
+		`UPDATE todo.operation_subtask AS c SET task=$3 FROM todo.operation AS o WHERE o.id=c.operation AND o.discord_user=$1 AND c.task=$2`,
+	} {
+		if _, err := tx.Exec(query, userId, edit.Task, edit.Previous.ID); err != nil {
+			return listChange{}, false, err
+		}
+	}
+	return listChange{edit.Previous.ID, list, list}, true, nil
+}
@@ -0,0 +1,304 @@
+package todo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectRecordOperation(userId, action string, operation int, tasks []int64, from, to []string) {
+	dbMock.ExpectQuery(`INSERT INTO todo.operation \(discord_user, action\)`).
+		WithArgs(userId, action).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(operation))
+	dbMock.ExpectExec(`INSERT INTO todo.operation_change`).
+		WithArgs(operation, pq.Array(tasks), pq.Array(from), pq.Array(to)).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(tasks))))
+}
+
+// Columns of the query loading the edits of undone operations
+var operationEditColumns = []string{"operation", "task", "previous_task", "title", "description", "due", "tags", "priority"}
+
+func expectOperationEdits(operations []int64, edits ...[]driver.Value) {
+	rows := sqlmock.NewRows(operationEditColumns)
+	for _, edit := range edits {
+		rows.AddRow(edit...)
+	}
+	dbMock.ExpectQuery(`SELECT operation, task, previous_task, (.+) FROM todo.operation_edit`).
+		WithArgs(pq.Array(operations)).
+		WillReturnRows(rows)
+}
+
+func expectOperationSubtasks(operations []int64, subtasks ...[]driver.Value) {
+	rows := sqlmock.NewRows([]string{"operation", "subtask"})
+	for _, subtask := range subtasks {
+		rows.AddRow(subtask...)
+	}
+	dbMock.ExpectQuery(`SELECT operation, (.+) FROM todo.operation_subtask`).
+		WithArgs(pq.Array(operations)).
+		WillReturnRows(rows)
+}
+
+// Columns of the query loading the group changes of undone operations
+var operationGroupChangeColumns = []string{"operation", "study_group", "task", "from_status", "to_status", "assignee", "completed_by", "completed_at"}
+
+func expectOperationGroupChanges(operations []int64, changes ...[]driver.Value) {
+	rows := sqlmock.NewRows(operationGroupChangeColumns)
+	for _, change := range changes {
+		rows.AddRow(change...)
+	}
+	dbMock.ExpectQuery(`SELECT operation, study_group, (.+) FROM todo.operation_group_change`).
+		WithArgs(pq.Array(operations)).
+		WillReturnRows(rows)
+}
+
+func TestFormatUndone(t *testing.T) {
+	tests := []struct {
+		input          undoneChanges
+		expectedOutput string
+	}{
+		{undoneChanges{}, "There is nothing to undo."},
+		{undoneChanges{Lists: []listChange{{1, "", "active"}}}, "Removed 1 from your active items."},
+		{
+			undoneChanges{Lists: []listChange{{1, "active", "completed"}, {2, "archived", ""}, {3, "active", "completed"}}},
+			"Moved 1, 3 back from done to active.\nRestored 2 to your archived items.",
+		},
+		{undoneChanges{Lists: []listChange{{4, "active", "active"}}}, "Reverted your edits of 4."},
+		{
+			undoneChanges{Lists: []listChange{{2, "active", "completed"}}, Subtasks: []string{"2.1", "2.2"}},
+			"Moved 2 back from done to active.\nUnchecked 2.1, 2.2.",
+		},
+		{
+			undoneChanges{Groups: []groupChange{{Group: 1, Task: 5, From: "active", To: "completed"}, {Group: 1, Task: 6, From: "completed"}}},
+			"Moved 5 of your group back from done to active.\nRestored 6 to the done items of your group.",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, formatUndone(test.input))
+	}
+}
+
+func TestParseUndoState(t *testing.T) {
+	owner, operations, err := parseUndoState(undoButtonPrefix + "123:4,5")
+	assert.Nil(t, err)
+	assert.Equal(t, "123", owner)
+	assert.Equal(t, []int{4, 5}, operations)
+
+	_, _, err = parseUndoState(undoButtonPrefix + "123")
+	assert.NotNil(t, err)
+
+	_, _, err = parseUndoState(undoButtonPrefix + "123:a")
+	assert.NotNil(t, err)
+}
+
+func TestUndoComponents(t *testing.T) {
+	assert.Empty(t, undoComponents("123", 0))
+	assert.Len(t, undoComponents("123", 0, 4), 1)
+}
+
+func TestUndoLast(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at=\$2 WHERE id IN \(SELECT id FROM todo.operation (.+) ORDER BY id DESC LIMIT \$4\) RETURNING id`).
+		WithArgs("0", now, now.Add(-undoWindow), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8).AddRow(7))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WithArgs(pq.Array([]int64{8, 7})).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}).
+			AddRow(8, 3, "archived", "").
+			AddRow(7, 1, "active", "completed"))
+	expectOperationEdits([]int64{8, 7})
+	expectOperationSubtasks([]int64{8, 7})
+	expectOperationGroupChanges([]int64{8, 7})
+	// Deleted items are restored
+	dbMock.ExpectExec(`INSERT INTO todo.archived (.+) ON CONFLICT DO NOTHING`).
+		WithArgs("0", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Moved items are moved back
+	dbMock.ExpectExec(`DELETE FROM todo.completed`).
+		WithArgs("0", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO todo.active (.+) ON CONFLICT DO NOTHING`).
+		WithArgs("0", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	undone, err := mockTodo.undoLast("0", 2, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []listChange{{3, "archived", ""}, {1, "active", "completed"}}, undone.Lists)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUndoOperationsNothingToUndo(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at=\$2 WHERE id IN \(SELECT id FROM todo.operation (.+) AND id=ANY\(\$4\)\) RETURNING id`).
+		WithArgs("0", now, now.Add(-undoWindow), pq.Array([]int{4})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WithArgs(pq.Array([]int64{})).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}))
+	expectOperationEdits([]int64{})
+	expectOperationSubtasks([]int64{})
+	expectOperationGroupChanges([]int64{})
+	dbMock.ExpectCommit()
+
+	undone, err := mockTodo.undoOperations("0", []int{4}, now)
+
+	assert.Nil(t, err)
+	assert.True(t, undone.empty())
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUndoInvalidList(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}).AddRow(8, 3, "discord_user", ""))
+	expectOperationEdits([]int64{8})
+	expectOperationSubtasks([]int64{8})
+	expectOperationGroupChanges([]int64{8})
+	dbMock.ExpectRollback()
+
+	_, err := mockTodo.undoLast("0", 1, now)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUndoMovedAgain(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	// The task got archived by a later operation after being marked as done
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}).AddRow(7, 1, "active", "completed"))
+	expectOperationEdits([]int64{7})
+	expectOperationSubtasks([]int64{7})
+	expectOperationGroupChanges([]int64{7})
+	dbMock.ExpectExec(`DELETE FROM todo.completed`).
+		WithArgs("0", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectCommit()
+
+	undone, err := mockTodo.undoOperations("0", []int{7}, now)
+
+	assert.Nil(t, err)
+	assert.True(t, undone.empty())
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUndoEdits(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7).AddRow(9).AddRow(8))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}))
+	expectOperationEdits([]int64{9, 8, 7},
+		[]driver.Value{7, 4, 4, "Old", "d", nil, "{}", priorityNone},
+		[]driver.Value{8, 2, 1, "Exam", "", nil, "{exam}", priorityHigh},
+		[]driver.Value{9, 5, 5, "Lab", "", nil, "{}", priorityNone},
+	)
+	expectOperationSubtasks([]int64{9, 8, 7})
+	expectOperationGroupChanges([]int64{9, 8, 7})
+	// Tasks which were removed from the users lists since are skipped
+	dbMock.ExpectQuery(`SELECT l.list FROM (.+) todo.operation_edit`).
+		WithArgs("0", 5, int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"list"}))
+	// Copies are replaced by the original task again
+	dbMock.ExpectQuery(`SELECT l.list FROM (.+) todo.operation_edit`).
+		WithArgs("0", 2, int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"list"}).AddRow("completed"))
+	dbMock.ExpectExec(`UPDATE todo.completed SET task=\$3`).
+		WithArgs("0", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`UPDATE todo.completed_subtask SET task=\$3`).
+		WithArgs("0", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec(`UPDATE todo.operation_change AS c SET task=\$3`).
+		WithArgs("0", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`UPDATE todo.operation_subtask AS c SET task=\$3`).
+		WithArgs("0", 2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The users own tasks get their previous values back
+	dbMock.ExpectQuery(`SELECT l.list FROM (.+) todo.operation_edit`).
+		WithArgs("0", 4, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"list"}).AddRow("active"))
+	dbMock.ExpectExec(`UPDATE todo.task SET title=\$2`).
+		WithArgs(4, "Old", "d", nil, pq.Array([]string{}), priorityNone).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	undone, err := mockTodo.undoLast("0", 3, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []listChange{{1, "completed", "completed"}, {4, "active", "active"}}, undone.Lists)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUndoSubtasksAndGroups(t *testing.T) {
+	now := time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)
+	completedAt := time.Date(2022, 10, 11, 8, 0, 0, 0, time.UTC)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery(`UPDATE todo.operation SET undone_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8).AddRow(7))
+	dbMock.ExpectQuery(`SELECT operation, task, (.+) FROM todo.operation_change`).
+		WillReturnRows(sqlmock.NewRows([]string{"operation", "task", "from_list", "to_list"}).AddRow(7, 2, "active", "completed"))
+	expectOperationEdits([]int64{8, 7})
+	expectOperationSubtasks([]int64{8, 7}, []driver.Value{7, "2.1"}, []driver.Value{7, "2.2"})
+	expectOperationGroupChanges([]int64{8, 7},
+		[]driver.Value{8, 1, 5, "completed", "", "1", "0", completedAt},
+		[]driver.Value{8, 1, 6, "active", "completed", nil, nil, nil},
+	)
+	// Deleted group items are restored with their previous values
+	dbMock.ExpectExec(`INSERT INTO todo.group_task (.+) ON CONFLICT DO NOTHING`).
+		WithArgs(1, 5, "completed", sql.NullString{String: "1", Valid: true}, sql.NullString{String: "0", Valid: true}, sql.NullTime{Time: completedAt, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Group items which were changed again since are left as they are
+	dbMock.ExpectExec(`UPDATE todo.group_task SET status=\$3`).
+		WithArgs(1, 6, "active", "completed", sql.NullString{}, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Undoing the completion by the last subtask also unchecks the subtasks
+	dbMock.ExpectExec(`DELETE FROM todo.completed`).
+		WithArgs("0", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`INSERT INTO todo.active (.+) ON CONFLICT DO NOTHING`).
+		WithArgs("0", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`DELETE FROM todo.completed_subtask`).
+		WithArgs("0", "2.1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(`DELETE FROM todo.completed_subtask`).
+		WithArgs("0", "2.2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	expectScheduleReminders("0")
+
+	undone, err := mockTodo.undoLast("0", 2, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []listChange{{2, "active", "completed"}}, undone.Lists)
+	assert.Equal(t, []string{"2.1", "2.2"}, undone.Subtasks)
+	assert.Len(t, undone.Groups, 1)
+	assert.Equal(t, 5, undone.Groups[0].Task)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,198 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/calendar"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron"
+)
+
+const (
+	defaultUpcomingWindow  = 7 * 24 * time.Hour
+	maxUpcomingWindow      = 8 * 7 * 24 * time.Hour
+	previewHorizon         = 366 * 24 * time.Hour // How far ahead the next firing of a subscription is searched for its preview
+	embedDescriptionLength = 4096                 // Maximum length of the description of an embed
+	previewTimeFormat      = "Mon 2 Jan 15:04"
+)
+
+// Item a subscription creates in the future
+type upcomingItem struct {
+	subscription subscriptionItem
+	at           time.Time
+}
+
+func (s Todo) upcomingHelp() string {
+	return "Usage: `todo upcoming [duration]`\nShows when your subscriptions create items within the duration, e.g. `todo upcoming 2w`. Defaults to `7d` and takes the semesters and breaks of `todo calendar` into account."
+}
+
+func (s Todo) Upcoming(bot *discord.Session, ctx *discord.MessageCreate, args []string) error {
+	if err := s.checkUserPresence(ctx.Author.ID); err != nil {
+		return err
+	}
+
+	window := defaultUpcomingWindow
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] != "help":
+		parsed, err := parseLeadTime(args[0])
+		if err != nil || parsed <= 0 || parsed > maxUpcomingWindow {
+			s.replyAndDelete(bot, ctx, fmt.Sprintf("The duration has to be between `1m` and `%s`, such as `7d`.", formatLeadTime(maxUpcomingWindow)))
+			return nil
+		}
+		window = parsed
+	default:
+		bot.ChannelMessageSend(ctx.ChannelID, s.upcomingHelp())
+		return nil
+	}
+
+	now := time.Now()
+	items, err := s.getUpcoming(ctx.Author.ID, now, now.Add(window))
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.ChannelMessageSendComplex(ctx.ChannelID, &discord.MessageSend{
+		Embeds:    []*discord.MessageEmbed{upcomingToEmbed(items, window, ctx.Author)},
+		Reference: ctx.Reference(),
+	})
+	return err
+}
+
+// Returns the items the subscriptions of the user create after now until the end, sorted by when they get created
+// Items of a subscription reach the user if the user is subscribed to it or to one of its ancestors
+func (s Todo) getUpcoming(userId string, now, end time.Time) ([]upcomingItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT subscription FROM todo.subscribed_to WHERE discord_user=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	subscribed := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		subscribed[id] = true
+	}
+	rows.Close()
+	if len(subscribed) == 0 {
+		return []upcomingItem{}, nil
+	}
+
+	rows, err = s.DB.QueryContext(ctx, `SELECT id, subscription_name, schedule, semester FROM todo.subscription WHERE schedule != ''`)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := []subscriptionItem{}
+	for rows.Next() {
+		subscription := subscriptionItem{}
+		rows.Scan(&subscription.id, &subscription.name, &subscription.schedule, &subscription.semester)
+		scheduled = append(scheduled, subscription)
+	}
+	rows.Close()
+
+	academicCalendar, err := s.getCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []upcomingItem{}
+	for _, subscription := range scheduled {
+		ancestors, err := s.getAncestors(subscription.id)
+		if err != nil {
+			return nil, err
+		}
+		reachesUser := false
+		for _, ancestor := range ancestors {
+			reachesUser = reachesUser || subscribed[ancestor]
+		}
+		if !reachesUser {
+			continue
+		}
+
+		schedule, err := scheduleParser.Parse(subscription.schedule)
+		if err != nil {
+			continue
+		}
+		for _, at := range activeFirings(schedule, academicCalendar, subscription.semester, now, end) {
+			items = append(items, upcomingItem{subscription: subscription, at: at})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].at.Before(items[j].at) })
+	return items, nil
+}
+
+// Returns the firings of the schedule after the start until the end which lie within the semester
+func activeFirings(schedule cron.Schedule, academicCalendar calendar.Calendar, semester string, start, end time.Time) []time.Time {
+	firings := []time.Time{}
+	for next := schedule.Next(start); !next.IsZero() && !next.After(end); next = schedule.Next(next) {
+		if academicCalendar.Active(semester, next) {
+			firings = append(firings, next)
+		}
+	}
+	return firings
+}
+
+// Returns the first firing of the schedule after the start which lies within the semester, if there is one before the end
+func nextActiveFiring(schedule cron.Schedule, academicCalendar calendar.Calendar, semester string, start, end time.Time) (time.Time, bool) {
+	for next := schedule.Next(start); !next.IsZero() && !next.After(end); next = schedule.Next(next) {
+		if academicCalendar.Active(semester, next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns a short preview of when the subscription creates its next item in the location, for the description of its select option
+// Schedules fire in the timezone of the bot, so now has to be in it as well
+func subscriptionPreview(subscription subscriptionItem, academicCalendar calendar.Calendar, now time.Time, location *time.Location) string {
+	if subscription.schedule == "" {
+		return ""
+	}
+	schedule, err := scheduleParser.Parse(subscription.schedule)
+	if err != nil {
+		return ""
+	}
+	next, found := nextActiveFiring(schedule, academicCalendar, subscription.semester, now, now.Add(previewHorizon))
+	if !found {
+		return "no upcoming items"
+	}
+	return "next " + next.In(location).Format(previewTimeFormat)
+}
+
+func upcomingToEmbed(items []upcomingItem, window time.Duration, user *discord.User) *discord.MessageEmbed {
+	description := "None of your subscriptions create items within the next `" + formatLeadTime(window) + "`."
+	if len(items) != 0 {
+		lines := []string{}
+		length := 0
+		for i, item := range items {
+			line := fmt.Sprintf("<t:%d:f> (<t:%d:R>) %s `%s`", item.at.Unix(), item.at.Unix(), item.subscription.name, item.subscription.id)
+			more := fmt.Sprintf("And %d more", len(items)-i)
+			if length+len(line)+len(more)+2 > embedDescriptionLength {
+				lines = append(lines, more)
+				break
+			}
+			lines = append(lines, line)
+			length += len(line) + 1
+		}
+		description = strings.Join(lines, "\n")
+	}
+
+	return &discord.MessageEmbed{
+		Author: &discord.MessageEmbedAuthor{
+			Name: user.Username + "s upcoming subscription items",
+		},
+		Description: description,
+		Color:       todoEmbedColor,
+		Footer: &discord.MessageEmbedFooter{
+			Text: "Subscriptions restricted to a semester don't create items during breaks, see todo calendar",
+		},
+	}
+}
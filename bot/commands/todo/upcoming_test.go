@@ -0,0 +1,85 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DominicWuest/Alphie/calendar"
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+var upcomingCalendar = calendar.New([]calendar.Period{
+	{Name: "Spring semester 2022", Semester: calendar.Spring, Start: time.Date(2022, 2, 21, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 6, 4, 0, 0, 0, 0, time.UTC)},
+	{Name: "Easter break 2022", Start: time.Date(2022, 4, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 4, 25, 0, 0, 0, 0, time.UTC)},
+	{Name: "Fall semester 2022", Semester: calendar.Fall, Start: time.Date(2022, 9, 19, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)},
+})
+
+func TestSubscriptionPreview(t *testing.T) {
+	now := time.Date(2022, 4, 12, 12, 0, 0, 0, time.UTC)
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	tests := []struct {
+		subscription subscriptionItem
+		location     *time.Location
+		expected     string
+	}{
+		{subscriptionItem{schedule: "0 18 * * FRI", semester: calendar.Spring}, time.UTC, "next Fri 29 Apr 18:00"},
+		{subscriptionItem{schedule: "0 18 * * FRI", semester: calendar.None}, time.UTC, "next Fri 15 Apr 18:00"},
+		{subscriptionItem{schedule: "0 18 * * FRI", semester: calendar.None}, zurich, "next Fri 15 Apr 20:00"},
+		{subscriptionItem{schedule: "0 8 * * MON", semester: calendar.Fall}, time.UTC, "next Mon 19 Sep 08:00"},
+		{subscriptionItem{schedule: ""}, time.UTC, ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, subscriptionPreview(test.subscription, upcomingCalendar, now, test.location), test.subscription)
+	}
+
+	// The calendar doesn't contain any semester after the fall semester
+	assert.Equal(t, "no upcoming items", subscriptionPreview(subscriptionItem{schedule: "0 8 * * MON", semester: calendar.Both}, upcomingCalendar, time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC), time.UTC))
+}
+
+func TestGetUpcoming(t *testing.T) {
+	now := time.Date(2022, 10, 3, 12, 0, 0, 0, time.Local)
+
+	dbMock.ExpectQuery(`SELECT subscription FROM todo.subscribed_to`).
+		WithArgs("0").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription"}).AddRow("Sem-05"))
+	dbMock.ExpectQuery(`SELECT id, subscription_name, schedule, semester FROM todo.subscription WHERE schedule != ''`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_name", "schedule", "semester"}).
+			AddRow("252-0217-00L", "Computer Systems", "0 12 * * MON,FRI", "H").
+			AddRow("401-0212-16L", "Analysis 1", "0 18 * * FRI", "F"))
+	expectCalendar()
+	// Only subscriptions with an ancestor the user is subscribed to create items for them
+	dbMock.ExpectQuery(`WITH RECURSIVE ids`).
+		WithArgs("252-0217-00L").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("252-0217-00L").AddRow("Sem-05"))
+	dbMock.ExpectQuery(`WITH RECURSIVE ids`).
+		WithArgs("401-0212-16L").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("401-0212-16L").AddRow("Sem-02"))
+
+	items, err := mockTodo.getUpcoming("0", now, now.Add(7*24*time.Hour))
+
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, time.Date(2022, 10, 7, 12, 0, 0, 0, time.Local), items[0].at)
+	assert.Equal(t, time.Date(2022, 10, 10, 12, 0, 0, 0, time.Local), items[1].at)
+	assert.Equal(t, "Computer Systems", items[0].subscription.name)
+	assert.Nil(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpcomingToEmbed(t *testing.T) {
+	user := &discord.User{Username: "user"}
+	assert.Equal(t, "None of your subscriptions create items within the next `1w`.", upcomingToEmbed([]upcomingItem{}, 7*24*time.Hour, user).Description)
+
+	at := time.Unix(1665136800, 0)
+	items := []upcomingItem{}
+	for i := 0; i < 100; i++ {
+		items = append(items, upcomingItem{subscriptionItem{id: "252-0217-00L", name: "Computer Systems"}, at})
+	}
+	description := upcomingToEmbed(items, 7*24*time.Hour, user).Description
+	assert.True(t, strings.HasPrefix(description, "<t:1665136800:f> (<t:1665136800:R>) Computer Systems `252-0217-00L`\n"))
+	assert.LessOrEqual(t, len(description), embedDescriptionLength)
+	assert.True(t, strings.HasSuffix(description, "more"))
+}
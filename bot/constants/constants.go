@@ -5,12 +5,14 @@ import (
 	"os"
 	"strings"
 
+	"github.com/DominicWuest/Alphie/bot/ratelimit"
+
 	discord "github.com/bwmarrin/discordgo"
 )
 
 type HandlerStruct struct {
-	MessageComponents map[string]func(*discord.Interaction) error // Handlers for InteractionCreate events
-	ModalSubmit       map[string]func(*discord.Interaction) error // Handlers for InteractionCreate events
+	MessageComponents *HandlerRegistry // Handlers for InteractionCreate events
+	ModalSubmit       *HandlerRegistry // Handlers for InteractionCreate events
 }
 
 // Interface for callable commands
@@ -18,15 +20,30 @@ type Command interface {
 	HandleCommand(*discord.Session, *discord.MessageCreate, []string) error
 	Desc() string
 	Help() string
+	Options() []*discord.ApplicationCommandOption // Options of the command when invoked as an application command
+	Permission([]string) Permission               // Permission needed to call the command with the given arguments
+	RateLimit() RateLimit                         // Default rate limits of the command
 	Init(...interface{}) Command
 }
 
+// Rate limits of a command, zero values mean no limit
+type RateLimit struct {
+	User    ratelimit.Limit // Limit per user calling the command
+	Channel ratelimit.Limit // Limit per channel the command is called in
+}
+
+// Prefixes the bot listens to for commands in messages
+var Prefixes []string = []string{":) ", "(: ", ": ) ", "al ", "🙂 "}
+
 var HomeGuildID string
 var HomeGuild *discord.Guild
 var AuthorizedIDs []string
 var Emojis map[string]string
 var EmojiIDs map[string]string
-var Handlers HandlerStruct
+var Handlers HandlerStruct = HandlerStruct{
+	MessageComponents: NewHandlerRegistry(),
+	ModalSubmit:       NewHandlerRegistry(),
+}
 
 // For colors in logging output
 const Red = "\033[31m"    // Error
@@ -45,7 +62,12 @@ func InitialiseConstants(bot *discord.Session) {
 	HomeGuild = localHomeGuild
 
 	// Parsing AUTHORIZED_IDS
-	AuthorizedIDs = strings.Split(os.Getenv("AUTHORIZED_IDS"), ",")
+	AuthorizedIDs = []string{}
+	for _, id := range strings.Split(os.Getenv("AUTHORIZED_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			AuthorizedIDs = append(AuthorizedIDs, id)
+		}
+	}
 
 	// Creating the emojis map
 	Emojis = make(map[string]string)
@@ -65,9 +87,4 @@ func InitialiseConstants(bot *discord.Session) {
 		Emojis[emoji.Name] = "<:" + emoji.Name + ":" + emoji.ID + ">"
 		EmojiIDs[emoji.Name] = emoji.ID
 	}
-
-	Handlers = HandlerStruct{
-		make(map[string]func(*discord.Interaction) error),
-		make(map[string]func(*discord.Interaction) error),
-	}
 }
@@ -0,0 +1,140 @@
+package constants
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// Handler for InteractionCreate events
+type Handler func(*discord.Session, *discord.Interaction) error
+
+type handlerEntry struct {
+	handler  Handler
+	timer    expiryTimer // Removes the entry once its TTL is over, nil if it never expires
+	onExpire func()
+}
+
+// Timer removing a handler once its TTL is over, such as a *time.Timer
+type expiryTimer interface {
+	Stop() bool
+}
+
+// Concurrency safe registry of interaction handlers, addressed by the CustomID of the interaction
+type HandlerRegistry struct {
+	sync.Mutex
+	handlers  map[string]*handlerEntry
+	prefixes  map[string]*handlerEntry                // Handlers serving every CustomID starting with the key
+	afterFunc func(time.Duration, func()) expiryTimer // Calls the function after the duration, replaced in tests to expire handlers deterministically
+}
+
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers:  make(map[string]*handlerEntry),
+		prefixes:  make(map[string]*handlerEntry),
+		afterFunc: func(d time.Duration, f func()) expiryTimer { return time.AfterFunc(d, f) },
+	}
+}
+
+// Registers the handler for the CustomID id, replacing any previous handler
+// If ttl is non-zero, the handler gets removed after ttl and onExpire (which may be nil) gets called
+func (r *HandlerRegistry) Register(id string, handler Handler, ttl time.Duration, onExpire func()) {
+	r.register(r.handlers, id, handler, ttl, onExpire)
+}
+
+// Registers the handler for all CustomIDs starting with prefix, see Register
+func (r *HandlerRegistry) RegisterPrefix(prefix string, handler Handler, ttl time.Duration, onExpire func()) {
+	r.register(r.prefixes, prefix, handler, ttl, onExpire)
+}
+
+func (r *HandlerRegistry) register(handlers map[string]*handlerEntry, key string, handler Handler, ttl time.Duration, onExpire func()) {
+	r.Lock()
+	defer r.Unlock()
+
+	if old, found := handlers[key]; found && old.timer != nil {
+		old.timer.Stop()
+	}
+
+	entry := &handlerEntry{
+		handler:  handler,
+		onExpire: onExpire,
+	}
+	if ttl > 0 {
+		entry.timer = r.afterFunc(ttl, func() {
+			r.Lock()
+			// The handler may have been replaced or removed in the meantime
+			if handlers[key] != entry {
+				r.Unlock()
+				return
+			}
+			delete(handlers, key)
+			r.Unlock()
+
+			if entry.onExpire != nil {
+				entry.onExpire()
+			}
+		})
+	}
+	handlers[key] = entry
+}
+
+// Returns the handler for the CustomID id
+// Handlers registered for the exact ID take precedence over the ones with the longest matching prefix
+func (r *HandlerRegistry) Lookup(id string) (Handler, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if entry, found := r.handlers[id]; found {
+		return entry.handler, true
+	}
+
+	var match *handlerEntry
+	matchLength := -1
+	for prefix, entry := range r.prefixes {
+		if strings.HasPrefix(id, prefix) && len(prefix) > matchLength {
+			match = entry
+			matchLength = len(prefix)
+		}
+	}
+	if match == nil {
+		return nil, false
+	}
+	return match.handler, true
+}
+
+// Removes the handlers for the CustomIDs without calling their onExpire callbacks
+func (r *HandlerRegistry) Unregister(ids ...string) {
+	r.unregister(r.handlers, ids)
+}
+
+// Removes the handlers for the prefixes without calling their onExpire callbacks
+func (r *HandlerRegistry) UnregisterPrefix(prefixes ...string) {
+	r.unregister(r.prefixes, prefixes)
+}
+
+func (r *HandlerRegistry) unregister(handlers map[string]*handlerEntry, keys []string) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, key := range keys {
+		if entry, found := handlers[key]; found {
+			if entry.timer != nil {
+				entry.timer.Stop()
+			}
+			delete(handlers, key)
+		}
+	}
+}
+
+// Tells the user that the component they interacted with expired, only visible to them
+func RespondExpired(bot *discord.Session, interaction *discord.Interaction) error {
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Content: "This menu expired, please use the command again.",
+			Flags:   uint64(discord.MessageFlagsEphemeral),
+		},
+	})
+}
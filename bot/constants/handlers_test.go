@@ -0,0 +1,148 @@
+package constants
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a handler which returns an error with the given name, to tell handlers apart
+func namedHandler(name string) Handler {
+	return func(*discord.Session, *discord.Interaction) error { return fmt.Errorf(name) }
+}
+
+// Timer which only fires when the test fires it
+type fakeTimer struct {
+	fire    func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	wasRunning := !t.stopped
+	t.stopped = true
+	return wasRunning
+}
+
+// Returns a registry whose handlers expire only when the test fires the returned timers, in the order they were created
+func fakeTimerRegistry() (*HandlerRegistry, *[]*fakeTimer) {
+	registry := NewHandlerRegistry()
+	timers := []*fakeTimer{}
+	registry.afterFunc = func(_ time.Duration, fire func()) expiryTimer {
+		timer := &fakeTimer{fire: fire}
+		timers = append(timers, timer)
+		return timer
+	}
+	return registry, &timers
+}
+
+func TestLookup(t *testing.T) {
+	registry := NewHandlerRegistry()
+	registry.Register("a:1", namedHandler("exact"), 0, nil)
+	registry.RegisterPrefix("a:", namedHandler("short"), 0, nil)
+	registry.RegisterPrefix("a:2", namedHandler("long"), 0, nil)
+
+	tests := []struct {
+		id            string
+		expectedFound bool
+		expectedName  string
+	}{
+		{"a:1", true, "exact"},
+		{"a:3", true, "short"},
+		{"a:23", true, "long"},
+		{"b:1", false, ""},
+		{"a", false, ""},
+	}
+
+	for _, test := range tests {
+		handler, found := registry.Lookup(test.id)
+		assert.Equal(t, test.expectedFound, found, test.id)
+		if found {
+			assert.EqualError(t, handler(nil, nil), test.expectedName, test.id)
+		}
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	registry := NewHandlerRegistry()
+	registry.Register("a", namedHandler("a"), 0, nil)
+	registry.Register("b", namedHandler("b"), 0, nil)
+	registry.RegisterPrefix("c", namedHandler("c"), 0, nil)
+
+	registry.Unregister("a", "unknown")
+	registry.UnregisterPrefix("c")
+
+	_, found := registry.Lookup("a")
+	assert.False(t, found)
+	_, found = registry.Lookup("b")
+	assert.True(t, found)
+	_, found = registry.Lookup("c1")
+	assert.False(t, found)
+}
+
+func TestExpire(t *testing.T) {
+	registry := NewHandlerRegistry()
+	expired := make(chan string, 2)
+	registry.Register("a", namedHandler("a"), 10*time.Millisecond, func() { expired <- "a" })
+	registry.RegisterPrefix("b", namedHandler("b"), 10*time.Millisecond, func() { expired <- "b" })
+
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{<-expired, <-expired})
+
+	_, found := registry.Lookup("a")
+	assert.False(t, found)
+	_, found = registry.Lookup("b1")
+	assert.False(t, found)
+}
+
+func TestExpireUnregistered(t *testing.T) {
+	registry, timers := fakeTimerRegistry()
+	expired := false
+	registry.Register("a", namedHandler("a"), time.Minute, func() { expired = true })
+	registry.Unregister("a")
+
+	assert.True(t, (*timers)[0].stopped)
+	// The timer may fire while it gets stopped, the handler must not expire anyway
+	(*timers)[0].fire()
+	assert.False(t, expired)
+}
+
+func TestExpireReplaced(t *testing.T) {
+	registry, timers := fakeTimerRegistry()
+	expired := []string{}
+	registry.Register("a", namedHandler("old"), time.Minute, func() { expired = append(expired, "old") })
+	registry.Register("a", namedHandler("new"), time.Minute, func() { expired = append(expired, "new") })
+
+	// The replaced handler must neither expire nor remove the new one
+	assert.True(t, (*timers)[0].stopped)
+	(*timers)[0].fire()
+	handler, found := registry.Lookup("a")
+	assert.True(t, found)
+	assert.EqualError(t, handler(nil, nil), "new")
+	assert.Empty(t, expired)
+
+	(*timers)[1].fire()
+	_, found = registry.Lookup("a")
+	assert.False(t, found)
+	assert.Equal(t, []string{"new"}, expired)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	registry := NewHandlerRegistry()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprint("id:", i)
+			registry.Register(id, namedHandler(id), time.Millisecond, nil)
+			registry.Lookup(id)
+			registry.Unregister(id)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Empty(t, registry.handlers)
+}
@@ -0,0 +1,63 @@
+package constants
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+)
+
+type PermissionLevel int8
+
+const (
+	Everyone  PermissionLevel = iota // Anyone can call the command
+	GuildRole                        // Only members with a certain role in the guild can call the command
+	Owner                            // Only the users in AuthorizedIDs can call the command
+)
+
+// Permission required to call a command
+type Permission struct {
+	Level PermissionLevel
+	Role  string // ID or name of the role needed, only relevant for the GuildRole level
+}
+
+// Returns whether the user is one of the owners of the bot
+func IsOwner(userId string) bool {
+	for _, id := range AuthorizedIDs {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the user fulfills the permission
+// The member may be nil if the command wasn't called in a guild
+func (p Permission) Allows(bot *discord.Session, guildId string, user *discord.User, member *discord.Member) bool {
+	// Owners may call any command
+	if p.Level == Everyone || IsOwner(user.ID) {
+		return true
+	}
+	if p.Level != GuildRole || member == nil {
+		return false
+	}
+
+	for _, roleId := range member.Roles {
+		if roleId == p.Role {
+			return true
+		}
+		if role, err := bot.State.Role(guildId, roleId); err == nil && role.Name == p.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// Describes what is needed to fulfill the permission
+func (p Permission) Requirement() string {
+	switch p.Level {
+	case GuildRole:
+		return "This command requires the role `" + p.Role + "` on this server."
+	case Owner:
+		return "This command is reserved for my owners."
+	default:
+		return "Anyone can use this command."
+	}
+}
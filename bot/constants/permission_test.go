@@ -0,0 +1,55 @@
+package constants
+
+import (
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionAllows(t *testing.T) {
+	AuthorizedIDs = []string{"owner"}
+	defer func() { AuthorizedIDs = nil }()
+
+	// Roles can be given by their ID or their name, names are looked up in the state
+	bot := &discord.Session{State: discord.NewState()}
+	assert.Nil(t, bot.State.GuildAdd(&discord.Guild{ID: "guild", Roles: []*discord.Role{{ID: "2", Name: "Tutor"}}}))
+
+	user := &discord.User{ID: "user"}
+	owner := &discord.User{ID: "owner"}
+	tests := []struct {
+		name       string
+		permission Permission
+		user       *discord.User
+		member     *discord.Member
+		expected   bool
+	}{
+		{"everyone", Permission{Level: Everyone}, user, nil, true},
+		{"role outside of guilds", Permission{Level: GuildRole, Role: "1"}, user, nil, false},
+		{"matching role ID", Permission{Level: GuildRole, Role: "1"}, user, &discord.Member{Roles: []string{"1"}}, true},
+		{"matching role name", Permission{Level: GuildRole, Role: "Tutor"}, user, &discord.Member{Roles: []string{"2"}}, true},
+		{"missing role", Permission{Level: GuildRole, Role: "1"}, user, &discord.Member{Roles: []string{"2"}}, false},
+		{"role of an owner", Permission{Level: GuildRole, Role: "1"}, owner, nil, true},
+		{"owner", Permission{Level: Owner}, owner, nil, true},
+		{"not an owner", Permission{Level: Owner}, user, &discord.Member{Roles: []string{"1"}}, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.permission.Allows(bot, "guild", test.user, test.member), test.name)
+	}
+}
+
+func TestPermissionRequirement(t *testing.T) {
+	tests := []struct {
+		permission Permission
+		expected   string
+	}{
+		{Permission{Level: Everyone}, "Anyone can use this command."},
+		{Permission{Level: GuildRole, Role: "Tutor"}, "This command requires the role `Tutor` on this server."},
+		{Permission{Level: Owner}, "This command is reserved for my owners."},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.permission.Requirement())
+	}
+}
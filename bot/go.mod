@@ -3,18 +3,22 @@ module github.com/DominicWuest/Alphie/bot
 go 1.18
 
 require (
+	github.com/DominicWuest/Alphie/calendar v0.0.0
 	github.com/bwmarrin/discordgo v0.25.0
+	github.com/fogleman/gg v1.3.0
 	github.com/lib/pq v1.10.5
+	golang.org/x/image v0.0.0-20220601225756-64ec528b34cd
 	google.golang.org/grpc v1.47.0
 	google.golang.org/protobuf v1.28.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
-	golang.org/x/text v0.3.6 // indirect
+	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -27,3 +31,5 @@ require (
 	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4 // indirect
 	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect
 )
+
+replace github.com/DominicWuest/Alphie/calendar => ../calendar
@@ -0,0 +1,259 @@
+package paginator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DominicWuest/Alphie/bot/constants"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// A single page of a paginated message
+type Page struct {
+	Content    string
+	Embeds     []*discord.MessageEmbed
+	Components []discord.MessageComponent // Shown above the navigation, at most four rows
+	Pages      int                        // Total amount of pages
+}
+
+// Renders a page of a paginated message for the user navigating it
+// All state besides the page has to be recovered from msg, which is nil when sending the message
+type Renderer func(msg *discord.Message, user *discord.User, page int) (Page, error)
+
+// Paginator adding navigation buttons to messages, which only the owner of the message can use
+// The owner and the page are encoded in the CustomIDs of the buttons, so they keep working after restarts
+type Paginator struct {
+	prefix  string
+	timeout time.Duration // How long the message can be navigated, zero if forever
+	render  Renderer
+}
+
+// Kinds of navigation buttons
+const (
+	first = "first"
+	prev  = "prev"
+	jump  = "jump"
+	next  = "next"
+	last  = "last"
+)
+
+// Returns a paginator whose components have CustomIDs starting with prefix
+func New(prefix string, timeout time.Duration, render Renderer) *Paginator {
+	return &Paginator{
+		prefix:  prefix,
+		timeout: timeout,
+		render:  render,
+	}
+}
+
+// Registers the handlers of the navigation buttons and the jump modal
+func (p *Paginator) Register(components, modals *constants.HandlerRegistry) {
+	components.RegisterPrefix(p.prefix, p.handleButton, 0, nil)
+	modals.RegisterPrefix(p.prefix, p.handleJumpModal, 0, nil)
+}
+
+// Renders the first page and sends it to the channel, owned by user
+func (p *Paginator) Send(bot *discord.Session, channelId string, user *discord.User) (*discord.Message, error) {
+	page, err := p.render(nil, user, 0)
+	if err != nil {
+		return nil, err
+	}
+	return bot.ChannelMessageSendComplex(channelId, &discord.MessageSend{
+		Content:    page.Content,
+		Embeds:     page.Embeds,
+		Components: p.Components(page, user.ID, 0),
+	})
+}
+
+// Returns the components of the page followed by the navigation, which is omitted if there is only one page
+func (p *Paginator) Components(page Page, owner string, current int) []discord.MessageComponent {
+	components := append([]discord.MessageComponent{}, page.Components...)
+	if page.Pages <= 1 {
+		return components
+	}
+
+	button := func(kind, label string, target int, disabled bool) discord.Button {
+		return discord.Button{
+			Label:    label,
+			Style:    discord.SecondaryButton,
+			CustomID: fmt.Sprint(p.prefix, kind, ":", owner, ":", target),
+			Disabled: disabled,
+		}
+	}
+	return append(components, discord.ActionsRow{
+		Components: []discord.MessageComponent{
+			button(first, "⏮", 0, current == 0),
+			button(prev, "◀", current-1, current == 0),
+			button(jump, fmt.Sprintf("%d/%d", current+1, page.Pages), current, false),
+			button(next, "▶", current+1, current == page.Pages-1),
+			button(last, "⏭", page.Pages-1, current == page.Pages-1),
+		},
+	})
+}
+
+// Splits a CustomID of the paginator into the kind of component, the owner and the page
+func (p *Paginator) parseCustomID(customId string) (kind, owner string, page int, err error) {
+	split := strings.Split(strings.TrimPrefix(customId, p.prefix), ":")
+	if len(split) != 3 {
+		return "", "", 0, fmt.Errorf("invalid paginator ID %s", customId)
+	}
+	page, err = strconv.Atoi(split[2])
+	return split[0], split[1], page, err
+}
+
+// Returns whether the message is too old to be navigated
+func (p *Paginator) expired(msg *discord.Message) bool {
+	return msg == nil || (p.timeout != 0 && time.Since(msg.Timestamp) > p.timeout)
+}
+
+// Tells the user that only the owner can navigate the message, only visible to them
+func respondNotOwner(bot *discord.Session, interaction *discord.Interaction) error {
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Content: "Only the person who used the command can turn the pages.",
+			Flags:   uint64(discord.MessageFlagsEphemeral),
+		},
+	})
+}
+
+// Returns the user who created the interaction
+func interactionUser(interaction *discord.Interaction) *discord.User {
+	if interaction.User != nil {
+		return interaction.User
+	}
+	return interaction.Member.User
+}
+
+// Callback for the navigation buttons, the jump button opens a modal to enter the page
+func (p *Paginator) handleButton(bot *discord.Session, interaction *discord.Interaction) error {
+	if p.expired(interaction.Message) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	kind, owner, page, err := p.parseCustomID(interaction.MessageComponentData().CustomID)
+	if err != nil {
+		return err
+	}
+	if interactionUser(interaction).ID != owner {
+		return respondNotOwner(bot, interaction)
+	}
+
+	if kind == jump {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseModal,
+			Data: &discord.InteractionResponseData{
+				CustomID: fmt.Sprint(p.prefix, "modal:", owner, ":", page),
+				Title:    "Jump to page",
+				Components: []discord.MessageComponent{
+					discord.ActionsRow{
+						Components: []discord.MessageComponent{
+							discord.TextInput{
+								CustomID:  p.prefix + "modal-page",
+								Label:     "Page",
+								Style:     discord.TextInputShort,
+								Value:     fmt.Sprint(page + 1),
+								MinLength: 1,
+								MaxLength: 5,
+								Required:  true,
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return p.update(bot, interaction, page)
+}
+
+// Callback for the jump modal, shows the entered page
+func (p *Paginator) handleJumpModal(bot *discord.Session, interaction *discord.Interaction) error {
+	if p.expired(interaction.Message) {
+		return constants.RespondExpired(bot, interaction)
+	}
+
+	_, owner, _, err := p.parseCustomID(interaction.ModalSubmitData().CustomID)
+	if err != nil {
+		return err
+	}
+	if interactionUser(interaction).ID != owner {
+		return respondNotOwner(bot, interaction)
+	}
+
+	row := interaction.ModalSubmitData().Components[0].(*discord.ActionsRow)
+	raw := strings.TrimSpace(row.Components[0].(*discord.TextInput).Value)
+	page, err := strconv.Atoi(raw)
+	if err != nil {
+		return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Content: "`" + raw + "` is not a page number.",
+				Flags:   uint64(discord.MessageFlagsEphemeral),
+			},
+		})
+	}
+
+	return p.update(bot, interaction, page-1)
+}
+
+// Renders the page, clamped to the existing pages, and shows it in the message of the interaction
+func (p *Paginator) update(bot *discord.Session, interaction *discord.Interaction, page int) error {
+	user := interactionUser(interaction)
+
+	if page < 0 {
+		page = 0
+	}
+	rendered, err := p.render(interaction.Message, user, page)
+	if err != nil {
+		return err
+	}
+	// The amount of pages may have changed since the message was sent
+	if clamped := Clamp(page, rendered.Pages); clamped != page {
+		page = clamped
+		if rendered, err = p.render(interaction.Message, user, page); err != nil {
+			return err
+		}
+	}
+
+	return bot.InteractionRespond(interaction, &discord.InteractionResponse{
+		Type: discord.InteractionResponseUpdateMessage,
+		Data: &discord.InteractionResponseData{
+			Content:    rendered.Content,
+			Embeds:     rendered.Embeds,
+			Components: p.Components(rendered, user.ID, page),
+		},
+	})
+}
+
+// Returns the amount of pages needed for total items with size items per page, at least one
+func Pages(total, size int) int {
+	if total <= 0 {
+		return 1
+	}
+	return (total + size - 1) / size
+}
+
+// Returns the page if it exists, else the closest existing one
+func Clamp(page, pages int) int {
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	return page
+}
+
+// Returns the bounds of the items on the page, for slicing the items
+func Bounds(total, size, page int) (start, end int) {
+	start = Clamp(page, Pages(total, size)) * size
+	end = start + size
+	if end > total {
+		end = total
+	}
+	return start, end
+}
@@ -0,0 +1,97 @@
+package paginator
+
+import (
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		total, size, expectedOutput int
+	}{
+		{0, 10, 1},
+		{1, 10, 1},
+		{10, 10, 1},
+		{11, 10, 2},
+		{25, 25, 1},
+		{26, 25, 2},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedOutput, Pages(test.total, test.size))
+	}
+}
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		total, size, page          int
+		expectedStart, expectedEnd int
+	}{
+		{0, 10, 0, 0, 0},
+		{5, 10, 0, 0, 5},
+		{25, 10, 1, 10, 20},
+		{25, 10, 2, 20, 25},
+		{25, 10, 3, 20, 25},
+		{25, 10, -1, 0, 10},
+	}
+
+	for _, test := range tests {
+		start, end := Bounds(test.total, test.size, test.page)
+		assert.Equal(t, test.expectedStart, start)
+		assert.Equal(t, test.expectedEnd, end)
+	}
+}
+
+// Returns the navigation buttons of the components
+func navigation(components []discord.MessageComponent) []discord.Button {
+	buttons := []discord.Button{}
+	for _, component := range components[len(components)-1].(discord.ActionsRow).Components {
+		buttons = append(buttons, component.(discord.Button))
+	}
+	return buttons
+}
+
+func TestComponents(t *testing.T) {
+	p := New("test:", 0, nil)
+	row := discord.ActionsRow{}
+
+	// Single pages have no navigation
+	assert.Equal(t, []discord.MessageComponent{row}, p.Components(Page{Components: []discord.MessageComponent{row}, Pages: 1}, "owner", 0))
+
+	buttons := navigation(p.Components(Page{Components: []discord.MessageComponent{row}, Pages: 3}, "owner", 1))
+	assert.Len(t, buttons, 5)
+	ids := []string{}
+	for _, button := range buttons {
+		assert.False(t, button.Disabled)
+		ids = append(ids, button.CustomID)
+	}
+	assert.Equal(t, []string{"test:first:owner:0", "test:prev:owner:0", "test:jump:owner:1", "test:next:owner:2", "test:last:owner:2"}, ids)
+	assert.Equal(t, "2/3", buttons[2].Label)
+
+	// Buttons leading past the first or last page are disabled
+	buttons = navigation(p.Components(Page{Pages: 3}, "owner", 0))
+	assert.True(t, buttons[0].Disabled)
+	assert.True(t, buttons[1].Disabled)
+	assert.False(t, buttons[3].Disabled)
+	buttons = navigation(p.Components(Page{Pages: 3}, "owner", 2))
+	assert.False(t, buttons[1].Disabled)
+	assert.True(t, buttons[3].Disabled)
+	assert.True(t, buttons[4].Disabled)
+}
+
+func TestParseCustomID(t *testing.T) {
+	p := New("test:", 0, nil)
+
+	kind, owner, page, err := p.parseCustomID("test:next:123:2")
+	assert.Equal(t, "next", kind)
+	assert.Equal(t, "123", owner)
+	assert.Equal(t, 2, page)
+	assert.Nil(t, err)
+
+	_, _, _, err = p.parseCustomID("test:next:123")
+	assert.NotNil(t, err)
+	_, _, _, err = p.parseCustomID("test:next:123:x")
+	assert.NotNil(t, err)
+}
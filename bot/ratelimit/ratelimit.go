@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token bucket limit, the zero value means no limit
+type Limit struct {
+	Burst    int           // How many calls can be made at once
+	Interval time.Duration // How long it takes to regain a single call
+}
+
+// A request for a token from the bucket with the given key
+type Request struct {
+	Key   string
+	Limit Limit
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time // When the tokens were last refilled
+}
+
+// Keeps track of token buckets by key
+type Limiter struct {
+	sync.Mutex
+	now       func() time.Time
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// How often full buckets get removed from the limiter
+const pruneInterval = 10 * time.Minute
+
+// Creates a new limiter using the clock now, which is time.Now if nil
+func New(now func() time.Time) *Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &Limiter{
+		now:       now,
+		buckets:   make(map[string]*bucket),
+		lastPrune: now(),
+	}
+}
+
+// Takes a token from every requested bucket if all of them have one left
+// Otherwise no token is taken and the time until all of them have one again is returned
+func (l *Limiter) Allow(requests ...Request) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	var wait time.Duration
+	buckets := []*bucket{}
+	for _, req := range requests {
+		if req.Limit.Burst <= 0 || req.Limit.Interval <= 0 {
+			continue
+		}
+		b, found := l.buckets[req.Key]
+		if !found || b.limit != req.Limit {
+			b = &bucket{limit: req.Limit, tokens: float64(req.Limit.Burst), last: now}
+			l.buckets[req.Key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			if missing := time.Duration((1 - b.tokens) * float64(b.limit.Interval)); missing > wait {
+				wait = missing
+			}
+		}
+		buckets = append(buckets, b)
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// Adds the tokens regained since the last refill
+func (b *bucket) refill(now time.Time) {
+	b.tokens += float64(now.Sub(b.last)) / float64(b.limit.Interval)
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+}
+
+// Removes buckets which are full again, as they behave just like new ones
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Parses a limit in the format burst/interval, e.g. 3/1m
+func ParseLimit(raw string) (Limit, error) {
+	split := strings.Split(strings.TrimSpace(raw), "/")
+	if len(split) != 2 {
+		return Limit{}, fmt.Errorf("invalid limit format %q, expected burst/interval", raw)
+	}
+	burst, err := strconv.Atoi(split[0])
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("invalid burst in limit %q", raw)
+	}
+	interval, err := time.ParseDuration(split[1])
+	if err != nil || interval < 0 {
+		return Limit{}, fmt.Errorf("invalid interval in limit %q", raw)
+	}
+	return Limit{Burst: burst, Interval: interval}, nil
+}
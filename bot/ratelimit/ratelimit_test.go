@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Clock which only advances when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newFakeLimiter() (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+	return New(clock.Now), clock
+}
+
+func TestAllowBurst(t *testing.T) {
+	limiter, _ := newFakeLimiter()
+	limit := Limit{Burst: 3, Interval: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		allowed, wait := limiter.Allow(Request{"key", limit})
+		assert.True(t, allowed)
+		assert.Zero(t, wait)
+	}
+
+	allowed, wait := limiter.Allow(Request{"key", limit})
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, wait)
+}
+
+func TestAllowRefill(t *testing.T) {
+	limiter, clock := newFakeLimiter()
+	limit := Limit{Burst: 2, Interval: 10 * time.Second}
+
+	limiter.Allow(Request{"key", limit})
+	limiter.Allow(Request{"key", limit})
+
+	clock.Advance(4 * time.Second)
+	allowed, wait := limiter.Allow(Request{"key", limit})
+	assert.False(t, allowed)
+	assert.Equal(t, 6*time.Second, wait)
+
+	clock.Advance(6 * time.Second)
+	allowed, _ = limiter.Allow(Request{"key", limit})
+	assert.True(t, allowed)
+
+	// Refilling never exceeds the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		allowed, _ = limiter.Allow(Request{"key", limit})
+		assert.True(t, allowed)
+	}
+	allowed, _ = limiter.Allow(Request{"key", limit})
+	assert.False(t, allowed)
+}
+
+func TestAllowSeparateKeys(t *testing.T) {
+	limiter, _ := newFakeLimiter()
+	limit := Limit{Burst: 1, Interval: time.Minute}
+
+	allowed, _ := limiter.Allow(Request{"user:0:ping", limit})
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(Request{"user:1:ping", limit})
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(Request{"user:0:help", limit})
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(Request{"user:0:ping", limit})
+	assert.False(t, allowed)
+}
+
+func TestAllowMultipleRequests(t *testing.T) {
+	limiter, _ := newFakeLimiter()
+	user := Limit{Burst: 5, Interval: time.Second}
+	channel := Limit{Burst: 1, Interval: time.Minute}
+
+	allowed, _ := limiter.Allow(Request{"user", user}, Request{"channel", channel})
+	assert.True(t, allowed)
+
+	// The channel bucket is empty, so no token may be taken from the user bucket either
+	for i := 0; i < 3; i++ {
+		allowed, wait := limiter.Allow(Request{"user", user}, Request{"channel", channel})
+		assert.False(t, allowed)
+		assert.Equal(t, time.Minute, wait)
+	}
+	for i := 0; i < 4; i++ {
+		allowed, _ = limiter.Allow(Request{"user", user})
+		assert.True(t, allowed)
+	}
+}
+
+func TestAllowNoLimit(t *testing.T) {
+	limiter, _ := newFakeLimiter()
+
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow(Request{"key", Limit{}})
+		assert.True(t, allowed)
+	}
+	assert.Empty(t, limiter.buckets)
+}
+
+func TestPrune(t *testing.T) {
+	limiter, clock := newFakeLimiter()
+	limit := Limit{Burst: 1, Interval: time.Minute}
+
+	limiter.Allow(Request{"a", limit})
+	clock.Advance(pruneInterval)
+	limiter.Allow(Request{"b", limit})
+
+	// Bucket a is full again, bucket b was just used
+	assert.NotContains(t, limiter.buckets, "a")
+	assert.Contains(t, limiter.buckets, "b")
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput Limit
+		expectedError  error
+	}{
+		{"3/1m", Limit{3, time.Minute}, nil},
+		{" 10/30s ", Limit{10, 30 * time.Second}, nil},
+		{"0/0s", Limit{0, 0}, nil},
+
+		{"", Limit{}, fmt.Errorf(`invalid limit format "", expected burst/interval`)},
+		{"3", Limit{}, fmt.Errorf(`invalid limit format "3", expected burst/interval`)},
+		{"a/1m", Limit{}, fmt.Errorf(`invalid burst in limit "a/1m"`)},
+		{"-1/1m", Limit{}, fmt.Errorf(`invalid burst in limit "-1/1m"`)},
+		{"3/x", Limit{}, fmt.Errorf(`invalid interval in limit "3/x"`)},
+	}
+
+	for _, test := range tests {
+		result, err := ParseLimit(test.input)
+		assert.Equal(t, test.expectedOutput, result)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
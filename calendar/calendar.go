@@ -0,0 +1,164 @@
+package calendar
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Semesters subscriptions and clippers can be restricted to
+const (
+	Spring = "F" // Frühjahrssemester
+	Fall   = "H" // Herbstsemester
+	Both   = "B"
+	None   = "N" // Not restricted to semesters, also runs during breaks
+)
+
+// Semester or break of the academic calendar
+type Period struct {
+	Name     string
+	Semester string    // Spring or Fall for semesters, empty for breaks
+	Start    time.Time // Start of the first day
+	End      time.Time // Start of the day after the last day
+}
+
+// Returns whether the period is a break within or between semesters, such as the Easter week
+func (p Period) IsBreak() bool { return p.Semester == "" }
+
+// Returns whether the time lies within the period
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// Semesters and breaks, each sorted by their start
+type Calendar struct {
+	Semesters []Period
+	Breaks    []Period
+}
+
+// Returns the calendar made up of the periods
+func New(periods []Period) Calendar {
+	calendar := Calendar{Semesters: []Period{}, Breaks: []Period{}}
+	for _, period := range periods {
+		if period.IsBreak() {
+			calendar.Breaks = append(calendar.Breaks, period)
+		} else {
+			calendar.Semesters = append(calendar.Semesters, period)
+		}
+	}
+	sort.SliceStable(calendar.Semesters, func(i, j int) bool { return calendar.Semesters[i].Start.Before(calendar.Semesters[j].Start) })
+	sort.SliceStable(calendar.Breaks, func(i, j int) bool { return calendar.Breaks[i].Start.Before(calendar.Breaks[j].Start) })
+	return calendar
+}
+
+// Loads the calendar from the academic_calendar.period table, with the dates in the location
+func Load(ctx context.Context, db *sql.DB, location *time.Location) (Calendar, error) {
+	rows, err := db.QueryContext(ctx, `SELECT period_name, semester, start_date, end_date FROM academic_calendar.period`)
+	if err != nil {
+		return Calendar{}, err
+	}
+	defer rows.Close()
+
+	periods := []Period{}
+	for rows.Next() {
+		var name string
+		var semester sql.NullString
+		var start, end time.Time
+		if err := rows.Scan(&name, &semester, &start, &end); err != nil {
+			return Calendar{}, err
+		}
+		periods = append(periods, Period{
+			Name:     name,
+			Semester: semester.String,
+			Start:    date(start, location),
+			// The last day is part of the period
+			End: date(end, location).AddDate(0, 0, 1),
+		})
+	}
+	return New(periods), rows.Err()
+}
+
+// Returns the start of the day of the date in the location, dates are read from the database as midnight UTC
+func date(t time.Time, location *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// Returns the semester the time lies in
+func (c Calendar) Semester(t time.Time) (Period, bool) {
+	return find(c.Semesters, t)
+}
+
+// Returns the break the time lies in
+func (c Calendar) Break(t time.Time) (Period, bool) {
+	return find(c.Breaks, t)
+}
+
+func find(periods []Period, t time.Time) (Period, bool) {
+	for _, period := range periods {
+		if period.Contains(t) {
+			return period, true
+		}
+	}
+	return Period{}, false
+}
+
+// Returns whether things restricted to the semester, one of Spring, Fall, Both and None, run at the time
+// Nothing but None runs outside of semesters or during breaks
+func (c Calendar) Active(semester string, t time.Time) bool {
+	if semester == None || semester == "" {
+		return true
+	}
+	if _, found := c.Break(t); found {
+		return false
+	}
+	current, found := c.Semester(t)
+	return found && (semester == Both || semester == current.Semester)
+}
+
+// Returns whether the calendar knows if things restricted to semesters run at the time
+// Times after the end of the last semester lie outside of all known periods, so nothing restricted to semesters runs then
+func (c Calendar) Covers(t time.Time) bool {
+	return len(c.Semesters) != 0 && c.Semesters[len(c.Semesters)-1].End.After(t)
+}
+
+// Returns when the semester of the time ends
+// Times between semesters belong to the break, which ends with the start of the next semester
+// Returns false if the calendar doesn't contain the end yet
+func (c Calendar) SemesterEnd(t time.Time) (time.Time, bool) {
+	if current, found := c.Semester(t); found {
+		return current.End, true
+	}
+	for _, semester := range c.Semesters {
+		if semester.Start.After(t) {
+			return semester.Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns when the latest semester which started by the time started
+// Times between semesters belong to the semester before them, unlike for SemesterEnd
+// Returns false if the calendar doesn't contain a semester starting by the time
+func (c Calendar) SemesterStart(t time.Time) (time.Time, bool) {
+	for i := len(c.Semesters) - 1; i >= 0; i-- {
+		if !c.Semesters[i].Start.After(t) {
+			return c.Semesters[i].Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns the semesters and breaks which haven't ended by the time, sorted by their start
+func (c Calendar) Upcoming(t time.Time) []Period {
+	upcoming := []Period{}
+	for _, periods := range [][]Period{c.Semesters, c.Breaks} {
+		for _, period := range periods {
+			if period.End.After(t) {
+				upcoming = append(upcoming, period)
+			}
+		}
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].Start.Before(upcoming[j].Start) })
+	return upcoming
+}
@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var testCalendar = New([]Period{
+	{"Fall semester 2022", Fall, day(2022, 9, 19), day(2022, 12, 26)},
+	{"Easter break 2022", "", day(2022, 4, 15), day(2022, 4, 25)},
+	{"Spring semester 2022", Spring, day(2022, 2, 21), day(2022, 6, 6)},
+})
+
+func TestNewSortsPeriods(t *testing.T) {
+	assert.Equal(t, "Spring semester 2022", testCalendar.Semesters[0].Name)
+	assert.Equal(t, "Fall semester 2022", testCalendar.Semesters[1].Name)
+	assert.Len(t, testCalendar.Breaks, 1)
+}
+
+func TestActive(t *testing.T) {
+	tests := []struct {
+		semester string
+		time     time.Time
+		expected bool
+	}{
+		{Spring, day(2022, 3, 15), true},
+		{Fall, day(2022, 3, 15), false},
+		{Both, day(2022, 3, 15), true},
+		{None, day(2022, 3, 15), true},
+		// The last day belongs to the semester
+		{Spring, day(2022, 6, 5).Add(23 * time.Hour), true},
+		{Spring, day(2022, 6, 6), false},
+		// Nothing restricted to semesters runs during breaks
+		{Spring, day(2022, 4, 18), false},
+		{Both, day(2022, 4, 18), false},
+		{None, day(2022, 4, 18), true},
+		{Fall, day(2022, 10, 3), true},
+		{Both, day(2022, 8, 1), false},
+		{None, day(2022, 8, 1), true},
+		{"", day(2022, 8, 1), true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testCalendar.Active(test.semester, test.time), test)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	assert.True(t, testCalendar.Covers(day(2022, 1, 10)))
+	assert.True(t, testCalendar.Covers(day(2022, 7, 1)))
+	assert.True(t, testCalendar.Covers(day(2022, 12, 25)))
+	assert.False(t, testCalendar.Covers(day(2022, 12, 26)))
+	assert.False(t, New([]Period{}).Covers(day(2022, 3, 15)))
+}
+
+func TestSemesterEnd(t *testing.T) {
+	tests := []struct {
+		input    time.Time
+		expected time.Time
+		found    bool
+	}{
+		{day(2022, 3, 15), day(2022, 6, 6), true},
+		{day(2022, 10, 3), day(2022, 12, 26), true},
+		// Breaks between semesters end with the start of the next semester
+		{day(2022, 1, 10), day(2022, 2, 21), true},
+		{day(2022, 8, 1), day(2022, 9, 19), true},
+		// The calendar doesn't know the next semester yet
+		{day(2022, 12, 28), time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		end, found := testCalendar.SemesterEnd(test.input)
+		assert.Equal(t, test.found, found, test.input)
+		assert.Equal(t, test.expected, end, test.input)
+	}
+}
+
+func TestSemesterStart(t *testing.T) {
+	tests := []struct {
+		input    time.Time
+		expected time.Time
+		found    bool
+	}{
+		{day(2022, 3, 15), day(2022, 2, 21), true},
+		{day(2022, 10, 3), day(2022, 9, 19), true},
+		// Breaks between semesters belong to the previous semester
+		{day(2022, 8, 1), day(2022, 2, 21), true},
+		{day(2022, 12, 28), day(2022, 9, 19), true},
+		// The calendar doesn't know the previous semester
+		{day(2022, 1, 10), time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		start, found := testCalendar.SemesterStart(test.input)
+		assert.Equal(t, test.found, found, test.input)
+		assert.Equal(t, test.expected, start, test.input)
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	upcoming := testCalendar.Upcoming(day(2022, 4, 20))
+
+	assert.Len(t, upcoming, 3)
+	assert.Equal(t, "Spring semester 2022", upcoming[0].Name)
+	assert.Equal(t, "Easter break 2022", upcoming[1].Name)
+	assert.Equal(t, "Fall semester 2022", upcoming[2].Name)
+
+	assert.Len(t, testCalendar.Upcoming(day(2022, 7, 1)), 1)
+	assert.Empty(t, testCalendar.Upcoming(day(2023, 1, 1)))
+}
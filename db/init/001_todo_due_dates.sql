@@ -0,0 +1,10 @@
+ALTER TABLE todo.task ADD COLUMN due TIMESTAMPTZ; -- NULL if the task has no due date
+
+ALTER TABLE todo.discord_user ADD COLUMN reminder_leads INTEGER[] NOT NULL DEFAULT '{1440, 60}'; -- Minutes before the due date the user gets reminded
+
+CREATE TABLE todo.reminder (
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL,
+    remind_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (discord_user, task, remind_at)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE todo.task ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}'; -- Lowercase, without the leading #
+ALTER TABLE todo.task ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3); -- None, Low, Medium, High
+
+CREATE INDEX task_tags_idx ON todo.task USING GIN (tags);
+
+-- Tag the already existing subscription tasks with their subscription
+UPDATE todo.task SET tags=ARRAY[LOWER(SUBSTRING(description FROM 'Automatically created for subscription (.*)'))]
+WHERE creator='0' AND description LIKE 'Automatically created for subscription %';
@@ -0,0 +1,2 @@
+-- Full-text index for todo search, queries have to use the exact same expression
+CREATE INDEX task_search_idx ON todo.task USING GIN (to_tsvector('simple', title || ' ' || description));
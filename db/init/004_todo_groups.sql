@@ -0,0 +1,22 @@
+CREATE TABLE todo.study_group (
+    id SERIAL NOT NULL,
+    group_name VARCHAR(64) NOT NULL,
+    creator VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE todo.group_member (
+    study_group INTEGER REFERENCES todo.study_group (id) ON DELETE CASCADE NOT NULL,
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    PRIMARY KEY (study_group, discord_user)
+);
+
+CREATE TABLE todo.group_task (
+    study_group INTEGER REFERENCES todo.study_group (id) ON DELETE CASCADE NOT NULL,
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL,
+    status VARCHAR(9) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'archived')),
+    assignee VARCHAR(19) REFERENCES todo.discord_user (id), -- NULL if the task isn't assigned to anyone
+    completed_by VARCHAR(19) REFERENCES todo.discord_user (id), -- NULL if the task hasn't been completed
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (study_group, task)
+);
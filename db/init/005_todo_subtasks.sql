@@ -0,0 +1,15 @@
+CREATE TABLE todo.subtask (
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL,
+    position INTEGER NOT NULL, -- Starting at 1, stays the same when other subtasks get deleted
+    title TEXT NOT NULL,
+    PRIMARY KEY (task, position)
+);
+
+-- Tasks can be shared, so every user checks off subtasks on their own
+CREATE TABLE todo.completed_subtask (
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    task INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    FOREIGN KEY (task, position) REFERENCES todo.subtask (task, position) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (discord_user, task, position)
+);
@@ -0,0 +1,14 @@
+-- Recurring items users created for themselves, added to their active items whenever the schedule fires
+CREATE TABLE todo.recurring (
+    id SERIAL PRIMARY KEY,
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    title TEXT NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    priority SMALLINT NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3),
+    schedule TEXT NOT NULL, -- Cronjob format, e.g. 0 10 * * 0
+    phrase TEXT NOT NULL, -- Schedule as the user entered it, e.g. every sun 10:00
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    next_run TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX recurring_next_run_idx ON todo.recurring (next_run) WHERE NOT paused;
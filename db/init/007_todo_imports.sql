@@ -0,0 +1,6 @@
+-- Items parsed from a file a user wants to import, kept until the user confirmed which ones to import
+CREATE TABLE todo.pending_import (
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) PRIMARY KEY, -- Only the latest import of a user is kept
+    items JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,19 @@
+-- Journal of the operations changing the lists of users, so they can be undone
+CREATE TABLE todo.operation (
+    id SERIAL PRIMARY KEY,
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    action VARCHAR(16) NOT NULL, -- add, move, archive, delete, import or repeat for items created by recurring items
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    undone_at TIMESTAMPTZ -- NULL if the operation hasn't been undone
+);
+
+CREATE INDEX operation_user_idx ON todo.operation (discord_user, id);
+
+-- Tasks are never deleted from todo.task, so changes can always be reverted by moving them back
+CREATE TABLE todo.operation_change (
+    operation INTEGER REFERENCES todo.operation (id) ON DELETE CASCADE NOT NULL,
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL,
+    from_list VARCHAR(9) CHECK (from_list IN ('active', 'completed', 'archived')), -- NULL if the task was added
+    to_list VARCHAR(9) CHECK (to_list IN ('active', 'completed', 'archived')), -- NULL if the task was deleted
+    PRIMARY KEY (operation, task)
+);
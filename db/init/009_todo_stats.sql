@@ -0,0 +1,4 @@
+-- The journal doubles as the history of the transitions between the lists for todo stats
+-- Items of subscriptions are journaled with the action subscription
+CREATE INDEX operation_change_task_idx ON todo.operation_change (task);
+CREATE INDEX operation_created_at_idx ON todo.operation (discord_user, created_at) WHERE undone_at IS NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE todo.discord_user ADD COLUMN timezone TEXT; -- IANA name such as Europe/Zurich, NULL for the timezone of the bot
+
+-- Digests of the active items users opted into
+CREATE TABLE todo.digest (
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    schedule TEXT NOT NULL, -- Cronjob format in the timezone of the user, e.g. 0 8 * * 1
+    phrase TEXT NOT NULL, -- Schedule as the user entered it
+    channel VARCHAR(19), -- NULL if the digest is sent by DM
+    next_run TIMESTAMPTZ NOT NULL,
+    last_sent TIMESTAMPTZ, -- NULL if no digest was sent yet
+    PRIMARY KEY (discord_user)
+);
+
+CREATE INDEX digest_next_run_idx ON todo.digest (next_run);
@@ -0,0 +1,12 @@
+-- Messages showing the active items of a user or a group, edited whenever the items change
+CREATE TABLE todo.board (
+    id SERIAL NOT NULL,
+    channel VARCHAR(19) NOT NULL,
+    message VARCHAR(19) NOT NULL,
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL, -- Who posted the board
+    study_group INTEGER REFERENCES todo.study_group (id) ON DELETE CASCADE, -- NULL for the board of discord_user
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX board_user_idx ON todo.board (discord_user);
+CREATE INDEX board_group_idx ON todo.board (study_group);
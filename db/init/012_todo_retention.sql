@@ -0,0 +1,4 @@
+-- Retention policy of the users, applied by a background job
+ALTER TABLE todo.discord_user ADD COLUMN archive_after INTEGER; -- Minutes after which completed items get archived, NULL to keep them
+ALTER TABLE todo.discord_user ADD COLUMN expire_subscriptions BOOLEAN NOT NULL DEFAULT FALSE; -- Whether unfinished subscription items get archived after their semester
+ALTER TABLE todo.discord_user ADD COLUMN retention_set_at TIMESTAMPTZ; -- When the policy was last changed, items completed before the journal existed count as completed then
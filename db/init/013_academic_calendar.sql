@@ -0,0 +1,30 @@
+CREATE SCHEMA academic_calendar;
+
+-- Semesters and breaks, during which subscriptions and clippers restricted to semesters don't run
+CREATE TABLE academic_calendar.period (
+    id SERIAL NOT NULL,
+    period_name VARCHAR(64) NOT NULL,
+    semester CHAR, -- F for spring and H for fall semesters, NULL for breaks such as the Easter week
+    start_date DATE NOT NULL, -- First day of the period
+    end_date DATE NOT NULL, -- Last day of the period
+    CHECK (semester IN ('F', 'H')),
+    CHECK (start_date <= end_date),
+    PRIMARY KEY (id)
+);
+
+INSERT INTO academic_calendar.period (period_name, semester, start_date, end_date) VALUES
+    ('Spring semester 2022', 'F', '2022-02-21', '2022-06-05'),
+    ('Easter break 2022', NULL, '2022-04-15', '2022-04-24'),
+    ('Fall semester 2022', 'H', '2022-09-19', '2022-12-25'),
+    ('Spring semester 2023', 'F', '2023-02-20', '2023-06-04'),
+    ('Easter break 2023', NULL, '2023-04-07', '2023-04-16'),
+    ('Fall semester 2023', 'H', '2023-09-18', '2023-12-24'),
+    ('Spring semester 2024', 'F', '2024-02-19', '2024-06-02'),
+    ('Easter break 2024', NULL, '2024-03-29', '2024-04-07'),
+    ('Fall semester 2024', 'H', '2024-09-16', '2024-12-22'),
+    ('Spring semester 2025', 'F', '2025-02-17', '2025-06-01'),
+    ('Easter break 2025', NULL, '2025-04-18', '2025-04-27'),
+    ('Fall semester 2025', 'H', '2025-09-15', '2025-12-21'),
+    ('Spring semester 2026', 'F', '2026-02-16', '2026-05-31'),
+    ('Easter break 2026', NULL, '2026-04-03', '2026-04-12'),
+    ('Fall semester 2026', 'H', '2026-09-14', '2026-12-20');
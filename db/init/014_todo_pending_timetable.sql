@@ -0,0 +1,8 @@
+-- Courses parsed from a timetable an owner wants to import as subscriptions and lecture clippers, kept until the import is confirmed
+CREATE TABLE todo.pending_timetable (
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) PRIMARY KEY, -- Only the latest import of a user is kept
+    courses JSONB NOT NULL,
+    semester CHAR NOT NULL,
+    parent VARCHAR(20) REFERENCES todo.subscription (id) ON DELETE CASCADE, -- Subscription the courses become children of
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- Firings of subscription schedules which created items, so firings missed while the bot was down can be caught up on once
+CREATE TABLE todo.subscription_run (
+    subscription VARCHAR(20) REFERENCES todo.subscription (id) ON DELETE CASCADE NOT NULL,
+    fired_at TIMESTAMPTZ NOT NULL, -- When the schedule fired, not when the items were created
+    PRIMARY KEY (subscription, fired_at)
+);
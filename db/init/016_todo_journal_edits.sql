@@ -0,0 +1,12 @@
+-- Values of tasks before users edited them, so edits can be undone like list changes
+CREATE TABLE todo.operation_edit (
+    operation INTEGER REFERENCES todo.operation (id) ON DELETE CASCADE NOT NULL,
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL, -- The edited task, a copy if the user didn't create the original
+    previous_task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL, -- The task in the users list before the edit, equal to task unless it was copied
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    due TIMESTAMPTZ,
+    tags TEXT[] NOT NULL,
+    priority SMALLINT NOT NULL,
+    PRIMARY KEY (operation, task)
+);
@@ -0,0 +1,10 @@
+-- Subscription the task was created for, NULL for tasks users created themselves
+-- Copies users edit keep it, so their items still count towards the subscription in their stats
+-- Not a foreign key, so the stats of deleted subscriptions stay
+ALTER TABLE todo.task ADD COLUMN subscription VARCHAR(20);
+
+CREATE INDEX task_subscription_idx ON todo.task (subscription) WHERE subscription IS NOT NULL;
+
+-- Link the existing subscription tasks and the copies which kept their description with their subscription
+UPDATE todo.task SET subscription=SUBSTRING(description FROM 'Automatically created for subscription (.*)')
+WHERE description LIKE 'Automatically created for subscription %';
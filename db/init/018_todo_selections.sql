@@ -0,0 +1,7 @@
+-- Values selected in select messages, kept here as selections spanning several pages don't fit into the messages
+CREATE TABLE todo.selection (
+    message VARCHAR(20) PRIMARY KEY,
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL, -- Owner of the select message
+    selected TEXT[] NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now() -- Selections of expired select messages get deleted
+);
@@ -0,0 +1,8 @@
+-- Pending imports belong to the select message offering their items, so a later import of the same user doesn't replace them
+DROP TABLE todo.pending_import;
+CREATE TABLE todo.pending_import (
+    message VARCHAR(20) PRIMARY KEY, -- Select message offering the items
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    items JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now() -- Pending imports of expired select messages get deleted
+);
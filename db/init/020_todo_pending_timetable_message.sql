@@ -0,0 +1,10 @@
+-- Pending timetables belong to the preview offering their import, so a later import of the same owner doesn't replace them
+DROP TABLE todo.pending_timetable;
+CREATE TABLE todo.pending_timetable (
+    message VARCHAR(20) PRIMARY KEY, -- Preview with the buttons confirming or cancelling the import
+    discord_user VARCHAR(19) REFERENCES todo.discord_user (id) NOT NULL,
+    courses JSONB NOT NULL,
+    semester CHAR NOT NULL,
+    parent VARCHAR(20) REFERENCES todo.subscription (id) ON DELETE CASCADE, -- Subscription the courses become children of
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now() -- Pending timetables of expired previews get deleted
+);
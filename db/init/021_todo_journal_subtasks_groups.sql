@@ -0,0 +1,21 @@
+-- Subtasks checked off by an operation, undoing it checks them off again
+CREATE TABLE todo.operation_subtask (
+    operation INTEGER REFERENCES todo.operation (id) ON DELETE CASCADE NOT NULL,
+    task INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    FOREIGN KEY (task, position) REFERENCES todo.subtask (task, position) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (operation, task, position)
+);
+
+-- Changes of the status of group items, with the values the items had before so deleted ones can be restored
+CREATE TABLE todo.operation_group_change (
+    operation INTEGER REFERENCES todo.operation (id) ON DELETE CASCADE NOT NULL,
+    study_group INTEGER REFERENCES todo.study_group (id) ON DELETE CASCADE NOT NULL,
+    task INTEGER REFERENCES todo.task (id) ON DELETE CASCADE NOT NULL,
+    from_status VARCHAR(9) NOT NULL CHECK (from_status IN ('active', 'completed', 'archived')),
+    to_status VARCHAR(9) CHECK (to_status IN ('active', 'completed', 'archived')), -- NULL if the item was deleted
+    assignee VARCHAR(19) REFERENCES todo.discord_user (id),
+    completed_by VARCHAR(19) REFERENCES todo.discord_user (id),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (operation, study_group, task)
+);
@@ -3,6 +3,7 @@ module github.com/DominicWuest/Alphie/rpc
 go 1.18
 
 require (
+	github.com/DominicWuest/Alphie/calendar v0.0.0
 	github.com/andybons/gogif v0.0.0-20140526152223-16d573594812
 	github.com/fogleman/gg v1.3.0
 	github.com/lib/pq v1.10.6
@@ -21,3 +22,5 @@ require (
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )
+
+replace github.com/DominicWuest/Alphie/calendar => ../calendar
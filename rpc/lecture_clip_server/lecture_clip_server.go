@@ -15,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/DominicWuest/Alphie/calendar"
 	pb "github.com/DominicWuest/Alphie/rpc/lecture_clip_server/lecture_clip_pb"
 
 	"google.golang.org/grpc"
@@ -60,15 +61,8 @@ type postResponse struct {
 const (
 	// Where to post the clips to on our CDN
 	cdnURL string = "/lecture_clips"
-)
-
-// Calendar weeks of semester start / end
-const (
-	springSemesterStart = 8
-	springSemesterEnd   = 22
-
-	fallSemesterStart = 38
-	fallSemesterEnd   = 51
+	// How long to wait for the DB
+	dbTimeout time.Duration = 5 * time.Second
 )
 
 var (
@@ -311,8 +305,6 @@ func checkDBConnection(db *sql.DB) bool {
 
 // Queries the schedules form the DB and inits cronjobs for starting the clippers
 func initLectureClipperSchedules(db *sql.DB) error {
-	const dbTimeout time.Duration = 5 * time.Second
-
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
@@ -355,7 +347,7 @@ func initLectureClipperSchedules(db *sql.DB) error {
 		}
 		aliasRows.Close()
 
-		if err := initSchedule(id, semester, room_url, schedule, aliases, durationMinutes); err != nil {
+		if err := initSchedule(db, id, semester, room_url, schedule, aliases, durationMinutes); err != nil {
 			return err
 		}
 	}
@@ -366,7 +358,7 @@ func initLectureClipperSchedules(db *sql.DB) error {
 }
 
 // Initialises the scheduled clipper using a cronjob
-func initSchedule(id, semester, roomUrl, schedule string, aliases []string, durationMinutes int) error {
+func initSchedule(db *sql.DB, id, semester, roomUrl, schedule string, aliases []string, durationMinutes int) error {
 	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
 	sched, err := parser.Parse(schedule)
 	if err != nil {
@@ -374,19 +366,18 @@ func initSchedule(id, semester, roomUrl, schedule string, aliases []string, dura
 	}
 
 	cronScheduler.Schedule(sched, cron.FuncJob(func() {
-		// Ensure we're in the right semester
-		_, calendarWeek := time.Now().ISOWeek()
-		if semester == "F" &&
-			(calendarWeek < springSemesterStart || calendarWeek > springSemesterEnd) {
+		// Ensure we're in the right semester and not in a break
+		ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+		academicCalendar, err := calendar.Load(ctx, db, time.Local)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to load the academic calendar for clipper %s: %v\n", id, err)
 			return
 		}
-		if semester == "H" &&
-			(calendarWeek < fallSemesterStart || calendarWeek > fallSemesterEnd) {
-			return
+		if semester != calendar.None && semester != "" && !academicCalendar.Covers(time.Now()) {
+			log.Printf("The academic calendar doesn't contain any upcoming semesters, so clipper %s is paused until they get added with todo calendar admin add\n", id)
 		}
-		if semester == "B" &&
-			(calendarWeek < springSemesterStart || calendarWeek > springSemesterEnd) &&
-			(calendarWeek < fallSemesterStart || calendarWeek > fallSemesterEnd) {
+		if !academicCalendar.Active(semester, time.Now()) {
 			return
 		}
 